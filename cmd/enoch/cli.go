@@ -0,0 +1,160 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"enoch/internal/config"
+)
+
+// command is one `enoch <name>` subcommand.
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(args []string) int
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"run", "run", "start the bot (default)", cmdRun},
+		{"doctor", "doctor", "check the setup and report problems", cmdDoctor},
+		{"config", "config check", "validate the config and print the effective settings", cmdConfig},
+		{"send", "send <chat_id> <text|->", "send a message (\"-\" reads stdin)", cmdSend},
+		{"memory", "memory add <text> | search <keyword> | today", "edit and search the memory files", cmdMemory},
+		{"jobs", "jobs [--chat id] [--limit n] [id]", "list recent jobs or show one", cmdJobs},
+	}
+}
+
+// globalFlags are accepted before and after the command name. Overrides
+// are applied as environment variables, so they win over the dotenv files
+// and the config file and survive reloads.
+var globalFlags struct {
+	envFile  string
+	workdir  string
+	logLevel string
+	set      setFlag
+}
+
+// setFlag collects repeated --set KEY=VALUE overrides.
+type setFlag []string
+
+func (s *setFlag) String() string { return strings.Join(*s, ",") }
+
+func (s *setFlag) Set(value string) error {
+	i := strings.IndexByte(value, '=')
+	if i <= 0 {
+		return fmt.Errorf("expected KEY=VALUE")
+	}
+	if !config.IsKey(value[:i]) {
+		return fmt.Errorf("unknown setting %s", value[:i])
+	}
+	*s = append(*s, value)
+	return nil
+}
+
+func registerGlobalFlags(fs *flag.FlagSet) {
+	fs.StringVar(&globalFlags.envFile, "env-file", globalFlags.envFile, "extra dotenv file, overriding .env and .env.local")
+	fs.StringVar(&globalFlags.workdir, "workdir", globalFlags.workdir, "agent working directory (CODEX_WORKDIR)")
+	fs.StringVar(&globalFlags.logLevel, "log-level", globalFlags.logLevel, "debug|info|warn|error (LOG_LEVEL)")
+	fs.Var(&globalFlags.set, "set", "override any setting, e.g. --set CODEX_TIMEOUT=5m (repeatable)")
+}
+
+// newFlagSet returns a flag set for a command with the global flags.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("enoch "+name, flag.ContinueOnError)
+	registerGlobalFlags(fs)
+	for _, c := range commands {
+		if c.name == name {
+			usage := c.usage
+			fs.Usage = func() {
+				fmt.Fprintf(fs.Output(), "usage: enoch %s [flags]\n", usage)
+				fs.PrintDefaults()
+			}
+		}
+	}
+	return fs
+}
+
+// parseArgs parses flags anywhere among args, so `enoch config check
+// --log-level debug` works, and returns the positional arguments. Everything
+// after "--" is positional.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+func runCLI(args []string) int {
+	fs := flag.NewFlagSet("enoch", flag.ContinueOnError)
+	registerGlobalFlags(fs)
+	fs.Usage = func() { printUsage(fs) }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	rest := fs.Args()
+	if len(rest) == 0 {
+		return cmdRun(nil)
+	}
+	for _, c := range commands {
+		if c.name == rest[0] {
+			return c.run(rest[1:])
+		}
+	}
+	if rest[0] == "help" {
+		printUsage(fs)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "enoch: unknown command %q\n\n", rest[0])
+	printUsage(fs)
+	return 2
+}
+
+func printUsage(fs *flag.FlagSet) {
+	out := fs.Output()
+	fmt.Fprintf(out, "usage: %s [flags] <command> [args]\n\ncommands:\n", filepath.Base(os.Args[0]))
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %-46s %s\n", c.usage, c.summary)
+	}
+	fmt.Fprintln(out, "\nflags:")
+	fs.PrintDefaults()
+}
+
+// loadConfig applies the global flags and loads the configuration.
+func loadConfig() (config.Config, error) {
+	config.EnvFile = globalFlags.envFile
+	overrides := map[string]string{}
+	if globalFlags.workdir != "" {
+		overrides["CODEX_WORKDIR"] = globalFlags.workdir
+	}
+	if globalFlags.logLevel != "" {
+		overrides["LOG_LEVEL"] = globalFlags.logLevel
+	}
+	for _, kv := range globalFlags.set {
+		i := strings.IndexByte(kv, '=')
+		overrides[kv[:i]] = kv[i+1:]
+	}
+	for key, value := range overrides {
+		if err := os.Setenv(key, value); err != nil {
+			return config.Config{}, err
+		}
+	}
+	return config.Load()
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"enoch/internal/codex"
+	"enoch/internal/config"
+	"enoch/internal/doctor"
+	"enoch/internal/history"
+	"enoch/internal/memory"
+	"enoch/internal/telegram"
+)
+
+// cmdConfig implements `enoch config check`: it validates the configuration
+// and prints the effective settings with secrets redacted.
+func cmdConfig(args []string) int {
+	fs := newFlagSet("config")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(args) != 1 || args[0] != "check" {
+		fs.Usage()
+		return 2
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config error: %v\n", err)
+		return 1
+	}
+	if cfg.ConfigFile != "" {
+		fmt.Printf("# config file: %s\n", cfg.ConfigFile)
+	} else {
+		fmt.Println("# config file: none (environment and dotenv files only)")
+	}
+	for _, s := range config.Describe(cfg) {
+		fmt.Printf("%s=%s\n", s.Key, s.Value)
+	}
+	for _, warning := range config.UnknownKeys() {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+	return 0
+}
+
+// cmdDoctor checks the configuration, the bot token, the Codex CLI and the
+// directories enoch writes to, and prints a hint for every problem.
+func cmdDoctor(args []string) int {
+	fs := newFlagSet("doctor")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return 2
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("[FAIL] %-16s %v\n", "config", err)
+		fmt.Println("       hint: run `enoch config check` for details")
+		return 1
+	}
+	fmt.Printf("[PASS] %-16s %s\n", "config", "loaded")
+	for _, warning := range config.UnknownKeys() {
+		fmt.Printf("[WARN] %-16s %s\n", "config", warning)
+	}
+	results := doctor.Run(context.Background(), doctor.Options{
+		Config: cfg,
+		Env:    codex.New(cfg, nil).Environment(nil),
+	})
+	if doctor.Print(os.Stdout, results) {
+		return 1
+	}
+	return 0
+}
+
+// cmdSend sends a message to a chat with the bot's token.
+func cmdSend(args []string) int {
+	fs := newFlagSet("send")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(args) < 2 {
+		fs.Usage()
+		return 2
+	}
+	chatID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid chat id %q\n", args[0])
+		return 2
+	}
+	text := strings.Join(args[1:], " ")
+	if text == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "read stdin: %v\n", err)
+			return 1
+		}
+		text = string(data)
+	}
+	if strings.TrimSpace(text) == "" {
+		fmt.Fprintln(os.Stderr, "nothing to send")
+		return 2
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config error: %v\n", err)
+		return 1
+	}
+	if err := telegram.Send(cfg, chatID, text); err != nil {
+		fmt.Fprintf(os.Stderr, "send failed: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// cmdMemory replaces scripts/memory.py: add an entry, search the memory
+// files or print today's file.
+func cmdMemory(args []string) int {
+	fs := newFlagSet("memory")
+	limit := fs.Int("limit", 50, "maximum search results (0 for all)")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(args) < 1 {
+		fs.Usage()
+		return 2
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config error: %v\n", err)
+		return 1
+	}
+	mgr := memory.NewManager(cfg.MemoryRoot)
+	text := strings.TrimSpace(strings.Join(args[1:], " "))
+
+	switch args[0] {
+	case "add":
+		if text == "" {
+			fs.Usage()
+			return 2
+		}
+		path, err := mgr.AddEntry(text)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "memory add failed: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Appended to %s\n", path)
+	case "search":
+		if text == "" {
+			fs.Usage()
+			return 2
+		}
+		matches, err := mgr.Search(text, *limit)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "memory search failed: %v\n", err)
+			return 1
+		}
+		for _, m := range matches {
+			fmt.Printf("%s:%d: %s\n", m.File, m.Line, m.Text)
+		}
+		if len(matches) == 0 {
+			return 1
+		}
+	case "today":
+		path, err := mgr.EnsureTodayFile()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "memory today failed: %v\n", err)
+			return 1
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "memory today failed: %v\n", err)
+			return 1
+		}
+		fmt.Printf("# %s\n%s", path, data)
+	default:
+		fs.Usage()
+		return 2
+	}
+	return 0
+}
+
+// cmdJobs lists the job history, or shows one job with its reply. It only
+// reads the history, so it is safe while the bot runs.
+func cmdJobs(args []string) int {
+	fs := newFlagSet("jobs")
+	chatID := fs.Int64("chat", 0, "only jobs of this chat")
+	limit := fs.Int("limit", 20, "number of jobs to list (0 for all)")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(args) > 1 {
+		fs.Usage()
+		return 2
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config error: %v\n", err)
+		return 1
+	}
+	store, err := history.Read(filepath.Join(cfg.DataDir, "history"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "history: %v\n", err)
+		return 1
+	}
+
+	if len(args) == 1 {
+		id, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid job id %q\n", args[0])
+			return 2
+		}
+		j, ok := store.Get(id)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "job #%d not found\n", id)
+			return 1
+		}
+		printJob(j)
+		if output, err := store.Output(id); err == nil {
+			fmt.Printf("\n%s\n", strings.TrimRight(output, "\n"))
+		}
+		return 0
+	}
+
+	jobs := store.Recent(*chatID, *limit)
+	if len(jobs) == 0 {
+		fmt.Println("no jobs")
+		return 0
+	}
+	for _, j := range jobs {
+		fmt.Printf("#%-5d %-11s %s %8s  chat %d  %s\n", j.ID, j.Status, j.Start.Format("2006-01-02 15:04"),
+			j.Duration().Round(time.Second), j.ChatID, preview(j.Prompt, 50))
+	}
+	return 0
+}
+
+func printJob(j history.Job) {
+	fmt.Printf("job #%d (%s)\n", j.ID, j.Trace)
+	fmt.Printf("status:   %s\n", j.Status)
+	fmt.Printf("chat:     %d\n", j.ChatID)
+	if j.UserID != 0 {
+		fmt.Printf("user:     %d\n", j.UserID)
+	}
+	if j.Backend != "" {
+		fmt.Printf("backend:  %s\n", j.Backend)
+	}
+	fmt.Printf("started:  %s\n", j.Start.Format("2006-01-02 15:04:05"))
+	fmt.Printf("duration: %s\n", j.Duration().Round(time.Second))
+	if j.ExitCode != 0 || j.ErrorClass != "" {
+		fmt.Printf("exit:     %d %s\n", j.ExitCode, j.ErrorClass)
+	}
+	if j.Attempts > 1 {
+		fmt.Printf("attempts: %d\n", j.Attempts)
+	}
+	if j.Note != "" {
+		fmt.Printf("note:     %s\n", j.Note)
+	}
+	fmt.Printf("output:   %d bytes", j.OutputBytes)
+	if j.Truncated {
+		fmt.Print(" (truncated)")
+	}
+	fmt.Printf("\nprompt:\n%s\n", j.Prompt)
+}
+
+// preview returns the first line of text, cut to limit runes.
+func preview(text string, limit int) string {
+	text = strings.TrimSpace(text)
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i] + " …"
+	}
+	if runes := []rune(text); len(runes) > limit {
+		text = string(runes[:limit]) + "…"
+	}
+	return text
+}
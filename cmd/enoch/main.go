@@ -1,37 +1,190 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"time"
 
+	"enoch/internal/admin"
+	"enoch/internal/agent"
 	"enoch/internal/codex"
 	"enoch/internal/config"
+	"enoch/internal/history"
+	"enoch/internal/instance"
 	"enoch/internal/logging"
+	"enoch/internal/scheduler"
+	"enoch/internal/settings"
 	"enoch/internal/telegram"
+	"enoch/internal/usage"
+	"enoch/internal/workspace"
 )
 
 func main() {
-	cfg, err := config.Load()
+	os.Exit(runCLI(os.Args[1:]))
+}
+
+// cmdRun starts the bot. It is the default command.
+func cmdRun(args []string) int {
+	fs := newFlagSet("run")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(args) > 0 {
+		fs.Usage()
+		return 2
+	}
+	cfg, err := loadConfig()
 	if err != nil {
 		fallbackLog("config error: %v", err)
-		os.Exit(1)
+		return 1
 	}
 
 	logger, err := logging.New(cfg)
 	if err != nil {
 		fallbackLog("logger init error: %v", err)
-		os.Exit(1)
+		return 1
 	}
 	defer func() {
 		_ = logger.Close()
 	}()
+	for _, warning := range config.UnknownKeys() {
+		logger.Warnf("config warning: %s", warning)
+	}
+
+	lock, err := instance.Acquire(cfg.CodexWorkdir)
+	if err != nil {
+		logger.Errorf("instance lock error: %v", err)
+		return 1
+	}
+	defer func() {
+		if err := lock.Release(); err != nil {
+			logger.Warnf("instance lock release failed: %v", err)
+		}
+	}()
+
+	sched, err := scheduler.New(filepath.Join(cfg.DataDir, "schedules.json"), logger)
+	if err != nil {
+		logger.Errorf("scheduler init error: %v", err)
+		return 1
+	}
+
+	chats, err := settings.Open(filepath.Join(cfg.DataDir, "chats.json"))
+	if err != nil {
+		logger.Errorf("settings init error: %v", err)
+		return 1
+	}
+
+	clients := buildClients(cfg, logger)
+	agents, err := agent.NewRegistry(cfg.DefaultBackend, buildAgents(cfg, clients, logger)...)
+	if err != nil {
+		logger.Errorf("backend init error: %v", err)
+		return 1
+	}
 
-	codexClient := codex.New(cfg, logger)
-	bot := telegram.New(cfg, codexClient, logger)
+	workspaces := workspace.New(workspace.Options{
+		Mode:       cfg.WorkspaceMode,
+		Root:       cfg.WorkspaceRoot,
+		Repo:       cfg.WorkspaceRepo,
+		SkillsDir:  cfg.WorkspaceSkillsDir,
+		KeepJobs:   cfg.WorkspaceKeepJobs,
+		MaxAge:     cfg.WorkspaceMaxAge,
+		QuotaBytes: int64(cfg.WorkspaceQuotaMB) * 1024 * 1024,
+	}, logger)
+
+	usageStore, err := usage.Open(filepath.Join(cfg.DataDir, "usage.jsonl"))
+	if err != nil {
+		logger.Errorf("usage init error: %v", err)
+		return 1
+	}
+
+	jobs, err := history.Open(filepath.Join(cfg.DataDir, "history"), cfg.JobHistoryLimit)
+	if err != nil {
+		logger.Errorf("history init error: %v", err)
+		return 1
+	}
+
+	bot := telegram.New(cfg, agents, chats, workspaces, usageStore, jobs, sched, logger)
+
+	r := &reloader{current: cfg, logger: logger, clients: clients, bot: bot}
+	bot.SetReloader(func() ([]string, error) { return r.reload("telegram") })
+	go r.watch()
+
+	if cfg.HTTPAddr != "" {
+		server := admin.New(cfg.HTTPAddr, bot, func() string { return r.config().AdminToken }, logger)
+		if err := server.Start(); err != nil {
+			logger.Errorf("http server init error: %v", err)
+			return 1
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = server.Close(ctx)
+		}()
+	}
 
 	logger.Infof("[enoch] Telegram polling started")
-	bot.Run()
+	return runUntilSignal(bot, r, logger)
+}
+
+// buildClients creates the built-in Codex backend plus the configured CLI
+// backends, keyed by name so a reload can update their options.
+func buildClients(cfg config.Config, logger *logging.Logger) map[string]*codex.Client {
+	clients := map[string]*codex.Client{"codex": codex.New(cfg, logger)}
+	for _, backend := range cfg.Backends {
+		if backend.Type == "cli" {
+			clients[backend.Name] = codex.NewCLI(cliOptions(cfg, backend), logger)
+		}
+	}
+	return clients
+}
+
+// cliOptions returns the options of a configured CLI backend; the shared
+// CODEX_* settings apply to it as well.
+func cliOptions(cfg config.Config, backend config.BackendConfig) codex.Options {
+	return codex.Options{
+		Name:         backend.Name,
+		Command:      backend.Command,
+		Args:         backend.Args,
+		PromptMode:   backend.PromptMode,
+		Timeout:      backend.Timeout,
+		Workdir:      cfg.CodexWorkdir,
+		DisableCPR:   cfg.CodexDisableCPR,
+		Progress:     cfg.CodexProgressInterval,
+		KillGrace:    cfg.CodexKillGrace,
+		Retry:        codex.RetryFromConfig(cfg),
+		Env:          codex.EnvFromConfig(cfg),
+		Home:         backend.Home,
+		OutputLimit:  cfg.CodexOutputLimit,
+		OutputDir:    cfg.CodexOutputDir,
+		LogRetention: cfg.CodexLogRetention,
+		UsagePattern: cfg.CodexUsagePattern,
+	}
+}
+
+// buildAgents lists the backends in configuration order: Codex first, then
+// the extras.
+func buildAgents(cfg config.Config, clients map[string]*codex.Client, logger *logging.Logger) []agent.Agent {
+	agents := []agent.Agent{clients["codex"]}
+	for _, backend := range cfg.Backends {
+		switch backend.Type {
+		case "cli":
+			agents = append(agents, clients[backend.Name])
+		case "http":
+			agents = append(agents, agent.NewHTTP(agent.HTTPOptions{
+				Name:         backend.Name,
+				URL:          backend.URL,
+				Model:        backend.Model,
+				APIKey:       backend.APIKey,
+				SystemPrompt: backend.SystemPrompt,
+				Stream:       backend.Stream,
+				Timeout:      backend.Timeout,
+			}, logger))
+		}
+	}
+	return agents
 }
 
 func fallbackLog(format string, args ...interface{}) {
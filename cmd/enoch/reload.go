@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"enoch/internal/codex"
+	"enoch/internal/config"
+	"enoch/internal/logging"
+	"enoch/internal/telegram"
+)
+
+// reloader re-reads the configuration on SIGHUP, /reload or when the config
+// files change, and swaps it into the running components.
+type reloader struct {
+	mu      sync.Mutex
+	current config.Config
+	logger  *logging.Logger
+	clients map[string]*codex.Client
+	bot     *telegram.Bot
+}
+
+// reload loads the configuration and applies it. Nothing is applied when a
+// setting that needs a restart changed; the returned error names them.
+func (r *reloader) reload(reason string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := config.Load()
+	if err != nil {
+		r.logger.Errorf("config reload failed: reason=%s err=%v", reason, err)
+		return nil, err
+	}
+	for _, warning := range config.UnknownKeys() {
+		r.logger.Warnf("config warning: %s", warning)
+	}
+	changed := config.Diff(r.current, next)
+	if blocked := config.RestartRequired(changed); len(blocked) > 0 {
+		r.logger.Warnf("config reload rejected: reason=%s restart_required=%s", reason, strings.Join(blocked, ","))
+		return nil, fmt.Errorf("需要重启才能修改 %s，其它修改也未应用", strings.Join(blocked, ", "))
+	}
+	if len(changed) == 0 {
+		r.logger.Infof("config reload: reason=%s changed=none", reason)
+		return nil, nil
+	}
+
+	if err := r.logger.Update(next); err != nil {
+		r.logger.Errorf("config reload failed: reason=%s err=%v", reason, err)
+		return nil, err
+	}
+	r.clients["codex"].Update(codex.OptionsFromConfig(next))
+	for _, backend := range next.Backends {
+		if client, ok := r.clients[backend.Name]; ok {
+			client.Update(cliOptions(next, backend))
+		}
+	}
+	r.bot.UpdateConfig(next)
+	r.current = next
+	r.logger.Infof("config reloaded: reason=%s changed=%s", reason, strings.Join(changed, ","))
+	return changed, nil
+}
+
+// watch reloads on SIGHUP and whenever the config file or a dotenv file is
+// modified.
+// ENOCH_CONFIG_WATCH sets the polling interval; 0 disables polling.
+func (r *reloader) watch() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	seen := r.modTimes()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	var lastCheck time.Time
+	for {
+		select {
+		case <-hup:
+			_, _ = r.reload("sighup")
+			seen = r.modTimes()
+		case now := <-ticker.C:
+			interval := r.interval()
+			if interval <= 0 || now.Sub(lastCheck) < interval {
+				continue
+			}
+			lastCheck = now
+			current := r.modTimes()
+			if current != seen {
+				seen = current
+				_, _ = r.reload("file")
+			}
+		}
+	}
+}
+
+func (r *reloader) interval() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current.ConfigWatch
+}
+
+// config returns the configuration currently applied.
+func (r *reloader) config() config.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// modTimes fingerprints the files Load reads. Missing files count too, so
+// creating one triggers a reload.
+func (r *reloader) modTimes() string {
+	configFile := config.DefaultConfigFile
+	if path := strings.TrimSpace(os.Getenv("ENOCH_CONFIG")); path != "" {
+		configFile = path
+	}
+	paths := append(config.DotEnvFiles(), configFile)
+	var sb strings.Builder
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			sb.WriteString(path + ":-;")
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%s:%d:%d;", path, info.ModTime().UnixNano(), info.Size()))
+	}
+	return sb.String()
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"enoch/internal/logging"
+	"enoch/internal/telegram"
+)
+
+// runUntilSignal runs the bot until SIGINT or SIGTERM, then drains it: polling
+// stops, the running job gets ENOCH_SHUTDOWN_GRACE to finish and queued jobs
+// are saved for the next start. A second signal cancels the running job
+// right away. The exit code is 0 after a clean drain and 1 when polling hit a
+// lasting 409 Conflict, a job had to be canceled or the queue could not be
+// saved.
+func runUntilSignal(bot *telegram.Bot, r *reloader, logger *logging.Logger) int {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go func() {
+		sig := <-signals
+		logger.Infof("shutdown requested: signal=%s grace=%s", sig, r.config().ShutdownGrace)
+		stop()
+	}()
+	code := 0
+	if err := bot.Run(ctx); err != nil {
+		logger.Errorf("telegram polling stopped: %v; another process is using this bot token (or a webhook is set), stop it before starting enoch", err)
+		code = 1
+	}
+
+	start := time.Now()
+	grace, cancel := context.WithTimeout(context.Background(), r.config().ShutdownGrace)
+	defer cancel()
+	go func() {
+		select {
+		case sig := <-signals:
+			logger.Warnf("shutdown forced: signal=%s", sig)
+			cancel()
+		case <-grace.Done():
+		}
+	}()
+	err := bot.Shutdown(grace)
+	switch {
+	case errors.Is(err, telegram.ErrJobCanceled):
+		logger.Warnf("shutdown done: duration=%s err=%v", time.Since(start).Round(time.Millisecond), err)
+		return 1
+	case err != nil:
+		logger.Errorf("shutdown failed: duration=%s err=%v", time.Since(start).Round(time.Millisecond), err)
+		return 1
+	}
+	logger.Infof("shutdown done: duration=%s", time.Since(start).Round(time.Millisecond))
+	return code
+}
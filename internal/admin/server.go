@@ -0,0 +1,281 @@
+// Package admin serves health checks, the bot status and authenticated
+// admin actions over local HTTP.
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"enoch/internal/logging"
+	"enoch/internal/telegram"
+)
+
+// staleAfter is how old the poll heartbeat may get before /healthz fails.
+// A long poll takes up to 30s and failed polls back off up to 60s.
+const staleAfter = 3 * time.Minute
+
+// Bot is the part of *telegram.Bot the server uses.
+type Bot interface {
+	Health() telegram.Health
+	Status() telegram.Status
+	SetPaused(paused bool)
+	Cancel(trace string) error
+	Enqueue(chatID int64, text string) (string, error)
+}
+
+// Server is the health and admin HTTP server.
+type Server struct {
+	bot    Bot
+	token  func() string
+	logger *logging.Logger
+	now    func() time.Time
+	srv    *http.Server
+}
+
+// New returns a server for addr. token returns the current admin token; the
+// admin endpoints are disabled while it is empty.
+func New(addr string, bot Bot, token func() string, logger *logging.Logger) *Server {
+	s := &Server{bot: bot, token: token, logger: logger, now: time.Now}
+	s.srv = &http.Server{Addr: addr, Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	return s
+}
+
+// Handler returns the routes.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealth)
+	mux.HandleFunc("/readyz", s.handleReady)
+	mux.HandleFunc("/status", s.authorized(s.handleStatus))
+	mux.HandleFunc("/admin/pause", s.admin(s.handlePause))
+	mux.HandleFunc("/admin/resume", s.admin(s.handleResume))
+	mux.HandleFunc("/admin/cancel", s.admin(s.handleCancel))
+	mux.HandleFunc("/admin/enqueue", s.admin(s.handleEnqueue))
+	return mux
+}
+
+// Start listens on the address and serves in the background.
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return err
+	}
+	if s.logger != nil {
+		s.logger.Infof("http server started: addr=%s admin=%t", listener.Addr(), s.token() != "")
+		if !isLoopback(s.srv.Addr) {
+			s.logger.Warnf("http server is reachable from other hosts: addr=%s; bind it to 127.0.0.1 unless that is intended", s.srv.Addr)
+		}
+	}
+	go func() {
+		if err := s.srv.Serve(listener); err != nil && err != http.ErrServerClosed && s.logger != nil {
+			s.logger.Errorf("http server failed: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Close stops the server, letting requests in flight finish until ctx ends.
+func (s *Server) Close(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
+
+type healthResponse struct {
+	Status       string   `json:"status"`
+	Problems     []string `json:"problems,omitempty"`
+	LastPoll     string   `json:"last_poll,omitempty"`
+	LastUpdates  string   `json:"last_updates,omitempty"`
+	WorkerAlive  bool     `json:"worker_alive"`
+	JobRunningMS int64    `json:"job_running_ms,omitempty"`
+	Stopping     bool     `json:"stopping"`
+}
+
+func (s *Server) health() (healthResponse, bool, bool) {
+	h := s.bot.Health()
+	now := s.now()
+	resp := healthResponse{
+		LastPoll:    formatTime(h.LastPoll),
+		LastUpdates: formatTime(h.LastUpdates),
+		WorkerAlive: h.WorkerAlive,
+		Stopping:    h.Stopping,
+	}
+	if !h.JobStarted.IsZero() {
+		resp.JobRunningMS = now.Sub(h.JobStarted).Milliseconds()
+	}
+	if h.LastPoll.IsZero() || now.Sub(h.LastPoll) > staleAfter {
+		resp.Problems = append(resp.Problems, "poll loop heartbeat is stale")
+	}
+	if !h.WorkerAlive && !h.Stopping {
+		resp.Problems = append(resp.Problems, "worker is not running")
+	}
+	healthy := len(resp.Problems) == 0
+	ready := healthy && !h.Stopping && !h.LastUpdates.IsZero() && now.Sub(h.LastUpdates) <= staleAfter
+	return resp, healthy, ready
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	resp, healthy, _ := s.health()
+	resp.Status = "ok"
+	code := http.StatusOK
+	if !healthy {
+		resp.Status = "unhealthy"
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, resp)
+}
+
+// handleReady reports ready once getUpdates has succeeded recently and the
+// bot is not shutting down.
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	resp, _, ready := s.health()
+	resp.Status = "ready"
+	code := http.StatusOK
+	if !ready {
+		resp.Status = "not ready"
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, resp)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.bot.Status())
+}
+
+// authorized wraps a handler with the bearer token check. The endpoints are
+// disabled while no token is set.
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := s.token()
+		if token == "" {
+			writeError(w, http.StatusNotFound, "endpoint is disabled; set ENOCH_ADMIN_TOKEN")
+			return
+		}
+		header := r.Header.Get("Authorization")
+		given := strings.TrimPrefix(header, "Bearer ")
+		if given == header || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			if s.logger != nil {
+				s.logger.Warnf("admin request rejected: path=%s remote=%s reason=unauthorized", r.URL.Path, r.RemoteAddr)
+			}
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// admin wraps a handler with POST-only and bearer token checks.
+func (s *Server) admin(next http.HandlerFunc) http.HandlerFunc {
+	return s.authorized(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, http.StatusMethodNotAllowed, "use POST")
+			return
+		}
+		if s.logger != nil {
+			s.logger.Infof("admin request: path=%s remote=%s", r.URL.Path, r.RemoteAddr)
+		}
+		next(w, r)
+	})
+}
+
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	s.bot.SetPaused(true)
+	writeJSON(w, http.StatusOK, map[string]bool{"paused": true})
+}
+
+func (s *Server) handleResume(w http.ResponseWriter, r *http.Request) {
+	s.bot.SetPaused(false)
+	writeJSON(w, http.StatusOK, map[string]bool{"paused": false})
+}
+
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Trace string `json:"trace"`
+	}
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if err := s.bot.Cancel(strings.TrimSpace(req.Trace)); err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, telegram.ErrJobNotFound) {
+			code = http.StatusNotFound
+		}
+		writeError(w, code, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"canceled": req.Trace})
+}
+
+func (s *Server) handleEnqueue(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ChatID int64  `json:"chat_id"`
+		Text   string `json:"text"`
+	}
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.ChatID == 0 || strings.TrimSpace(req.Text) == "" {
+		writeError(w, http.StatusBadRequest, "chat_id and text are required")
+		return
+	}
+	trace, err := s.bot.Enqueue(req.ChatID, req.Text)
+	if err != nil {
+		code := http.StatusBadRequest
+		switch {
+		case errors.Is(err, telegram.ErrQueueFull):
+			code = http.StatusServiceUnavailable
+		case errors.Is(err, telegram.ErrChatNotAllowed):
+			code = http.StatusForbidden
+		}
+		writeError(w, code, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"trace": trace})
+}
+
+// readJSON decodes an optional JSON body, answering 400 when it is invalid.
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(v)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]string{"error": message})
+}
+
+// isLoopback reports whether addr only listens on the local host. An empty
+// host listens on every interface.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,187 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"enoch/internal/telegram"
+)
+
+type fakeBot struct {
+	health   telegram.Health
+	paused   bool
+	canceled string
+	queued   []string
+}
+
+func (f *fakeBot) Health() telegram.Health { return f.health }
+
+func (f *fakeBot) Status() telegram.Status {
+	return telegram.Status{Paused: f.paused, QueueLength: len(f.queued), Queue: []telegram.QueuedJob{}}
+}
+
+func (f *fakeBot) SetPaused(paused bool) { f.paused = paused }
+
+func (f *fakeBot) Cancel(trace string) error {
+	if trace != "update_id=1" {
+		return telegram.ErrJobNotFound
+	}
+	f.canceled = trace
+	return nil
+}
+
+func (f *fakeBot) Enqueue(chatID int64, text string) (string, error) {
+	f.queued = append(f.queued, text)
+	return "admin_id=1", nil
+}
+
+func newTestServer(bot *fakeBot, token string, now time.Time) *httptest.Server {
+	s := New("", bot, func() string { return token }, nil)
+	s.now = func() time.Time { return now }
+	return httptest.NewServer(s.Handler())
+}
+
+func do(t *testing.T, method, url, token, body string) (*http.Response, map[string]interface{}) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	decoded := map[string]interface{}{}
+	_ = json.NewDecoder(resp.Body).Decode(&decoded)
+	return resp, decoded
+}
+
+func TestHealthAndReadiness(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	bot := &fakeBot{health: telegram.Health{LastPoll: now.Add(-time.Second), WorkerAlive: true}}
+	server := newTestServer(bot, "", now)
+	defer server.Close()
+
+	if resp, _ := do(t, http.MethodGet, server.URL+"/healthz", "", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected healthy, got %d", resp.StatusCode)
+	}
+	if resp, _ := do(t, http.MethodGet, server.URL+"/readyz", "", ""); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected not ready before the first getUpdates, got %d", resp.StatusCode)
+	}
+	bot.health.LastUpdates = now.Add(-time.Second)
+	if resp, _ := do(t, http.MethodGet, server.URL+"/readyz", "", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected ready, got %d", resp.StatusCode)
+	}
+
+	bot.health.LastPoll = now.Add(-time.Hour)
+	bot.health.WorkerAlive = false
+	resp, body := do(t, http.MethodGet, server.URL+"/healthz", "", "")
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected unhealthy, got %d", resp.StatusCode)
+	}
+	if problems, _ := body["problems"].([]interface{}); len(problems) != 2 {
+		t.Fatalf("expected two problems, got %v", body["problems"])
+	}
+}
+
+func TestStatusNeedsToken(t *testing.T) {
+	bot := &fakeBot{paused: true}
+	server := newTestServer(bot, "s3cret-admin-token", time.Now())
+	defer server.Close()
+	if resp, _ := do(t, http.MethodGet, server.URL+"/status", "", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected unauthorized, got %d", resp.StatusCode)
+	}
+	resp, body := do(t, http.MethodGet, server.URL+"/status", "s3cret-admin-token", "")
+	if resp.StatusCode != http.StatusOK || body["paused"] != true {
+		t.Fatalf("unexpected status: %d %v", resp.StatusCode, body)
+	}
+
+	open := newTestServer(bot, "", time.Now())
+	defer open.Close()
+	if resp, _ := do(t, http.MethodGet, open.URL+"/status", "", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected status disabled without a token, got %d", resp.StatusCode)
+	}
+}
+
+func TestTokenNeedsBearerScheme(t *testing.T) {
+	bot := &fakeBot{}
+	server := newTestServer(bot, "s3cret-admin-token", time.Now())
+	defer server.Close()
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/admin/pause", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "s3cret-admin-token")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || bot.paused {
+		t.Fatalf("expected a raw token to be rejected, got %d", resp.StatusCode)
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1:8080": true,
+		"[::1]:8080":     true,
+		"localhost:8080": true,
+		":8080":          false,
+		"0.0.0.0:8080":   false,
+		"10.0.0.5:8080":  false,
+	} {
+		if got := isLoopback(addr); got != want {
+			t.Errorf("isLoopback(%q) = %t, want %t", addr, got, want)
+		}
+	}
+}
+
+func TestAdminEndpoints(t *testing.T) {
+	bot := &fakeBot{}
+	server := newTestServer(bot, "s3cret-admin-token", time.Now())
+	defer server.Close()
+
+	if resp, _ := do(t, http.MethodPost, server.URL+"/admin/pause", "wrong", ""); resp.StatusCode != http.StatusUnauthorized || bot.paused {
+		t.Fatalf("expected unauthorized, got %d", resp.StatusCode)
+	}
+	if resp, _ := do(t, http.MethodGet, server.URL+"/admin/pause", "s3cret-admin-token", ""); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected POST only, got %d", resp.StatusCode)
+	}
+	if resp, _ := do(t, http.MethodPost, server.URL+"/admin/pause", "s3cret-admin-token", ""); resp.StatusCode != http.StatusOK || !bot.paused {
+		t.Fatalf("expected pause, got %d", resp.StatusCode)
+	}
+	if resp, _ := do(t, http.MethodPost, server.URL+"/admin/resume", "s3cret-admin-token", ""); resp.StatusCode != http.StatusOK || bot.paused {
+		t.Fatalf("expected resume, got %d", resp.StatusCode)
+	}
+	if resp, _ := do(t, http.MethodPost, server.URL+"/admin/cancel", "s3cret-admin-token", `{"trace":"update_id=9"}`); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected unknown job, got %d", resp.StatusCode)
+	}
+	if resp, _ := do(t, http.MethodPost, server.URL+"/admin/cancel", "s3cret-admin-token", `{"trace":"update_id=1"}`); resp.StatusCode != http.StatusOK || bot.canceled != "update_id=1" {
+		t.Fatalf("expected cancel, got %d", resp.StatusCode)
+	}
+	if resp, _ := do(t, http.MethodPost, server.URL+"/admin/enqueue", "s3cret-admin-token", `{"chat_id":1}`); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected missing text to be rejected, got %d", resp.StatusCode)
+	}
+	resp, body := do(t, http.MethodPost, server.URL+"/admin/enqueue", "s3cret-admin-token", `{"chat_id":1,"text":"summarize"}`)
+	if resp.StatusCode != http.StatusAccepted || body["trace"] != "admin_id=1" || len(bot.queued) != 1 {
+		t.Fatalf("expected enqueue, got %d %v", resp.StatusCode, body)
+	}
+}
+
+func TestAdminDisabledWithoutToken(t *testing.T) {
+	bot := &fakeBot{}
+	server := newTestServer(bot, "", time.Now())
+	defer server.Close()
+	if resp, _ := do(t, http.MethodPost, server.URL+"/admin/pause", "", ""); resp.StatusCode != http.StatusNotFound || bot.paused {
+		t.Fatalf("expected admin endpoints disabled, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,163 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrCanceled is returned when the caller cancels a run before it finishes.
+var ErrCanceled = errors.New("agent canceled")
+
+// Request describes a single agent run.
+type Request struct {
+	Prompt string
+	// Trace identifies the job in logs (e.g. update_id=123).
+	Trace string
+	// Overrides carries the chat's run settings.
+	Overrides Overrides
+	// Workdir, when set, replaces the backend's configured working directory.
+	Workdir string
+	// Env holds extra per-chat environment variables for CLI backends.
+	Env map[string]string
+}
+
+// Overrides are per-chat run settings. Empty fields keep the backend's
+// configured defaults; backends ignore settings they do not support.
+type Overrides struct {
+	Model           string
+	ReasoningEffort string
+	Sandbox         string
+	Approval        string
+	Profile         string
+	ExtraArgs       []string
+}
+
+// EventKind classifies streamed events.
+type EventKind string
+
+const (
+	// EventOutput carries a chunk of output as it is produced. Chunks are
+	// raw text and may split or join lines.
+	EventOutput EventKind = "output"
+	// EventInfo carries a human-readable progress note.
+	EventInfo EventKind = "info"
+	// EventRetry announces another attempt after a retryable failure. Text
+	// holds the error class of the failed attempt.
+	EventRetry EventKind = "retry"
+)
+
+// Event is emitted while a run is in progress.
+type Event struct {
+	Kind EventKind
+	Text string
+	// Attempt, MaxAttempts and Wait are set for EventRetry.
+	Attempt     int
+	MaxAttempts int
+	Wait        time.Duration
+}
+
+// Result is the outcome of a run. A failed or canceled run only fills in
+// Usage.
+type Result struct {
+	Output string
+	// Truncated is set when Output holds only excerpts of a larger output
+	// whose full text was written to the job log.
+	Truncated bool
+	// Usage is the token usage reported by the backend, if any, summed over
+	// all attempts.
+	Usage Usage
+}
+
+// Usage counts tokens consumed by a run.
+type Usage struct {
+	Input  int64
+	Output int64
+	Total  int64
+}
+
+// Add returns the sum of u and o.
+func (u Usage) Add(o Usage) Usage {
+	return Usage{Input: u.Input + o.Input, Output: u.Output + o.Output, Total: u.Total + o.Total}
+}
+
+// Empty reports whether no usage was reported.
+func (u Usage) Empty() bool {
+	return u.Input == 0 && u.Output == 0 && u.Total == 0
+}
+
+// Agent runs prompts against some backend. Run must stop promptly when ctx
+// is canceled and then return ErrCanceled. events may be nil. The Result of
+// a failed or canceled run still reports the usage consumed.
+type Agent interface {
+	Name() string
+	Run(ctx context.Context, req Request, events func(Event)) (Result, error)
+}
+
+// EnvReporter is implemented by backends that run subprocesses, to show
+// the environment a run would get.
+type EnvReporter interface {
+	Environment(extra map[string]string) []string
+}
+
+// Registry holds the configured backends by name.
+type Registry struct {
+	agents     map[string]Agent
+	defaultKey string
+}
+
+// NewRegistry registers agents under their names. defaultName must be one
+// of them.
+func NewRegistry(defaultName string, agents ...Agent) (*Registry, error) {
+	r := &Registry{agents: map[string]Agent{}, defaultKey: defaultName}
+	for _, a := range agents {
+		name := a.Name()
+		if _, exists := r.agents[name]; exists {
+			return nil, fmt.Errorf("duplicate backend %q", name)
+		}
+		r.agents[name] = a
+	}
+	if _, ok := r.agents[defaultName]; !ok {
+		return nil, fmt.Errorf("default backend %q is not configured (have %s)", defaultName, strings.Join(r.Names(), ", "))
+	}
+	return r, nil
+}
+
+// Get returns the named agent.
+func (r *Registry) Get(name string) (Agent, bool) {
+	a, ok := r.agents[name]
+	return a, ok
+}
+
+// Resolve returns the named agent, falling back to the default when name is
+// empty or no longer configured.
+func (r *Registry) Resolve(name string) Agent {
+	if a, ok := r.agents[name]; ok {
+		return a
+	}
+	return r.agents[r.defaultKey]
+}
+
+// DefaultName returns the name of the default backend.
+func (r *Registry) DefaultName() string {
+	return r.defaultKey
+}
+
+// Names returns the registered backend names in sorted order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.agents))
+	for name := range r.agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func emit(events func(Event), kind EventKind, text string) {
+	if events != nil {
+		events(Event{Kind: kind, Text: text})
+	}
+}
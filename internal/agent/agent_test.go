@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type stubAgent string
+
+func (s stubAgent) Name() string { return string(s) }
+
+func (s stubAgent) Run(ctx context.Context, req Request, events func(Event)) (Result, error) {
+	return Result{Output: string(s)}, nil
+}
+
+func TestRegistryResolveFallsBackToDefault(t *testing.T) {
+	r, err := NewRegistry("codex", stubAgent("codex"), stubAgent("local"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := r.Resolve("local").Name(); got != "local" {
+		t.Fatalf("expected local, got %s", got)
+	}
+	if got := r.Resolve("").Name(); got != "codex" {
+		t.Fatalf("expected default for empty name, got %s", got)
+	}
+	if got := r.Resolve("gone").Name(); got != "codex" {
+		t.Fatalf("expected default for unknown name, got %s", got)
+	}
+	if got := strings.Join(r.Names(), ","); got != "codex,local" {
+		t.Fatalf("names mismatch: %s", got)
+	}
+}
+
+func TestRegistryRejectsMissingDefaultAndDuplicates(t *testing.T) {
+	if _, err := NewRegistry("codex", stubAgent("local")); err == nil {
+		t.Fatalf("expected error for missing default")
+	}
+	if _, err := NewRegistry("codex", stubAgent("codex"), stubAgent("codex")); err == nil {
+		t.Fatalf("expected error for duplicate name")
+	}
+}
+
+func TestHTTPAgentStreamsDeltas(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("unexpected auth header: %q", got)
+		}
+		for _, part := range []string{"Hel", "lo"} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", part)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	a := NewHTTP(HTTPOptions{Name: "local", URL: server.URL + "/v1", APIKey: "secret", Stream: true}, nil)
+	var chunks []string
+	res, err := a.Run(context.Background(), Request{Prompt: "hi"}, func(e Event) {
+		chunks = append(chunks, e.Text)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Output != "Hello" {
+		t.Fatalf("output mismatch: %q", res.Output)
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 streamed chunks, got %#v", chunks)
+	}
+}
+
+func TestHTTPAgentNonStreaming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":" done "}}]}`)
+	}))
+	defer server.Close()
+
+	a := NewHTTP(HTTPOptions{Name: "local", URL: server.URL + "/chat/completions"}, nil)
+	res, err := a.Run(context.Background(), Request{Prompt: "hi"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Output != "done" {
+		t.Fatalf("output mismatch: %q", res.Output)
+	}
+}
+
+func TestHTTPAgentCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	a := NewHTTP(HTTPOptions{Name: "local", URL: "http://127.0.0.1:1"}, nil)
+	if _, err := a.Run(ctx, Request{Prompt: "hi"}, nil); err != ErrCanceled {
+		t.Fatalf("expected ErrCanceled, got %v", err)
+	}
+}
+
+func TestHTTPAgentClassifiesFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch strings.Split(r.URL.Path, "/")[1] {
+		case "auth":
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":{"message":"bad key sk-secret-value"}}`)
+		case "limit":
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"error":{"message":"slow down"}}`)
+		case "server":
+			w.WriteHeader(http.StatusBadGateway)
+		case "body":
+			fmt.Fprint(w, `{"error":{"message":"model not found"}}`)
+		}
+	}))
+	defer server.Close()
+
+	cases := map[string]string{"auth": ClassAuth, "limit": ClassRateLimit, "server": ClassServer, "body": ClassExit}
+	for name, class := range cases {
+		a := NewHTTP(HTTPOptions{Name: "local", URL: server.URL + "/" + name + "/v1", APIKey: "sk-secret-value"}, nil)
+		_, err := a.Run(context.Background(), Request{Prompt: "hi"}, nil)
+		if got := ErrorClass(err); got != class {
+			t.Fatalf("%s: expected class %q, got %q (%v)", name, class, got, err)
+		}
+		if strings.Contains(err.Error(), "sk-secret-value") {
+			t.Fatalf("%s: expected the key redacted, got %v", name, err)
+		}
+	}
+
+	a := NewHTTP(HTTPOptions{Name: "local", URL: server.URL + "/limit/v1"}, nil)
+	_, err := a.Run(context.Background(), Request{Prompt: "hi"}, nil)
+	if !strings.Contains(err.Error(), "slow down") {
+		t.Fatalf("expected the body excerpt in the error, got %v", err)
+	}
+
+	server.Close()
+	if _, err := a.Run(context.Background(), Request{Prompt: "hi"}, nil); ErrorClass(err) != ClassNetwork {
+		t.Fatalf("expected a network error, got %v", err)
+	}
+}
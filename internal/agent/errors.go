@@ -0,0 +1,61 @@
+package agent
+
+import "errors"
+
+// Error classes describe why a run failed. They drive retries and are
+// recorded in job history.
+const (
+	ClassAuth      = "auth"       // 401, invalid or expired credentials
+	ClassRateLimit = "rate_limit" // 429, quota or rate limiting
+	ClassServer    = "server"     // 5xx or overloaded upstream
+	ClassNetwork   = "network"    // connection, DNS or stream failures
+	ClassTimeout   = "timeout"    // the run exceeded its timeout
+	ClassExit      = "exit"       // the command failed for another reason
+	ClassStart     = "start"      // the command could not be started
+)
+
+// RunError is a failed run with its classification.
+type RunError struct {
+	Class string
+	// ExitCode is the process exit code, or -1 when it did not exit normally.
+	ExitCode int
+	// Attempts is the number of attempts made, including retries.
+	Attempts int
+	// SideEffects is set when the failed attempt had already run tools that
+	// may have changed files or state.
+	SideEffects bool
+	// Detail is the tail of the command's error output, for logs only.
+	Detail string
+	Err    error
+}
+
+func (e *RunError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RunError) Unwrap() error {
+	return e.Err
+}
+
+// StatusClass returns the class of a failed HTTP request from its status
+// code.
+func StatusClass(code int) string {
+	switch {
+	case code == 401 || code == 403:
+		return ClassAuth
+	case code == 429:
+		return ClassRateLimit
+	case code >= 500:
+		return ClassServer
+	}
+	return ClassExit
+}
+
+// ErrorClass returns the class of err, or "" when it is not a RunError.
+func ErrorClass(err error) string {
+	var runErr *RunError
+	if errors.As(err, &runErr) {
+		return runErr.Class
+	}
+	return ""
+}
@@ -0,0 +1,263 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"enoch/internal/logging"
+	"enoch/internal/redact"
+)
+
+// HTTPOptions configures an OpenAI-compatible chat completions backend.
+type HTTPOptions struct {
+	Name         string
+	URL          string
+	Model        string
+	APIKey       string
+	SystemPrompt string
+	Stream       bool
+	Timeout      time.Duration
+}
+
+// HTTPAgent talks to an OpenAI-compatible /chat/completions endpoint, such
+// as a local llama.cpp, vLLM or Ollama server.
+type HTTPAgent struct {
+	opts   HTTPOptions
+	client *http.Client
+	logger *logging.Logger
+}
+
+func NewHTTP(opts HTTPOptions, logger *logging.Logger) *HTTPAgent {
+	return &HTTPAgent{
+		opts:   opts,
+		client: &http.Client{},
+		logger: logger,
+	}
+}
+
+func (a *HTTPAgent) Name() string {
+	return a.opts.Name
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model           string         `json:"model,omitempty"`
+	ReasoningEffort string         `json:"reasoning_effort,omitempty"`
+	Messages        []chatMessage  `json:"messages"`
+	Stream          bool           `json:"stream"`
+	StreamOptions   *streamOptions `json:"stream_options,omitempty"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+		Delta   chatMessage `json:"delta"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int64 `json:"prompt_tokens"`
+		CompletionTokens int64 `json:"completion_tokens"`
+		TotalTokens      int64 `json:"total_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (r chatResponse) usage() Usage {
+	if r.Usage == nil {
+		return Usage{}
+	}
+	return Usage{Input: r.Usage.PromptTokens, Output: r.Usage.CompletionTokens, Total: r.Usage.TotalTokens}
+}
+
+func (a *HTTPAgent) Run(ctx context.Context, req Request, events func(Event)) (Result, error) {
+	prompt := strings.TrimSpace(req.Prompt)
+	if prompt == "" {
+		return Result{}, fmt.Errorf("empty prompt")
+	}
+	if a.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.opts.Timeout)
+		defer cancel()
+	}
+
+	messages := []chatMessage{}
+	if a.opts.SystemPrompt != "" {
+		messages = append(messages, chatMessage{Role: "system", Content: a.opts.SystemPrompt})
+	}
+	messages = append(messages, chatMessage{Role: "user", Content: prompt})
+	model := a.opts.Model
+	if req.Overrides.Model != "" {
+		model = req.Overrides.Model
+	}
+	payload := chatRequest{
+		Model:           model,
+		ReasoningEffort: req.Overrides.ReasoningEffort,
+		Messages:        messages,
+		Stream:          a.opts.Stream,
+	}
+	if a.opts.Stream {
+		payload.StreamOptions = &streamOptions{IncludeUsage: true}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Result{}, a.failure(ClassStart, err, "")
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, chatCompletionsURL(a.opts.URL), bytes.NewReader(body))
+	if err != nil {
+		return Result{}, a.failure(ClassStart, err, "")
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if a.opts.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+a.opts.APIKey)
+	}
+
+	if a.logger != nil {
+		a.logger.Debugf("http agent invoke: %s backend=%s model=%s stream=%t", req.Trace, a.opts.Name, model, a.opts.Stream)
+	}
+
+	resp, err := a.client.Do(httpReq)
+	if err != nil {
+		return Result{}, a.contextError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		// The body may echo the request, key included.
+		detail := redact.New([]string{a.opts.APIKey}, "").String(strings.TrimSpace(string(raw)))
+		if a.logger != nil {
+			a.logger.Errorf("http agent status: %s backend=%s status=%s body=%s", req.Trace, a.opts.Name, resp.Status, detail)
+		}
+		err := fmt.Errorf("status %s", resp.Status)
+		if detail != "" {
+			err = fmt.Errorf("status %s: %s", resp.Status, truncateExcerpt(detail, 300))
+		}
+		return Result{}, a.failure(StatusClass(resp.StatusCode), err, detail)
+	}
+
+	var output string
+	var usage Usage
+	if a.opts.Stream {
+		output, usage, err = readStream(resp.Body, events)
+	} else {
+		output, usage, err = readCompletion(resp.Body)
+	}
+	if err != nil {
+		return Result{}, a.contextError(ctx, err)
+	}
+	return Result{Output: strings.TrimSpace(output), Usage: usage}, nil
+}
+
+// contextError classifies a failed request or response read: a cancel, a
+// timeout, a broken connection or a response that could not be used.
+func (a *HTTPAgent) contextError(ctx context.Context, err error) error {
+	switch ctx.Err() {
+	case context.Canceled:
+		return ErrCanceled
+	case context.DeadlineExceeded:
+		return a.failure(ClassTimeout, fmt.Errorf("timeout after %s", a.opts.Timeout), "")
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return a.failure(ClassNetwork, err, "")
+	}
+	return a.failure(ClassExit, err, "")
+}
+
+// failure wraps err in a RunError of the given class. There is no process,
+// so the exit code is always -1.
+func (a *HTTPAgent) failure(class string, err error, detail string) error {
+	return &RunError{Class: class, ExitCode: -1, Detail: detail, Err: fmt.Errorf("%s: %w", a.opts.Name, err)}
+}
+
+// truncateExcerpt shortens s to at most max bytes without splitting a
+// character.
+func truncateExcerpt(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return strings.ToValidUTF8(s[:max], "") + "..."
+}
+
+func readCompletion(body io.Reader) (string, Usage, error) {
+	var decoded chatResponse
+	if err := json.NewDecoder(body).Decode(&decoded); err != nil {
+		return "", Usage{}, err
+	}
+	if decoded.Error != nil {
+		return "", Usage{}, errors.New(decoded.Error.Message)
+	}
+	if len(decoded.Choices) == 0 {
+		return "", Usage{}, fmt.Errorf("response has no choices")
+	}
+	return decoded.Choices[0].Message.Content, decoded.usage(), nil
+}
+
+// readStream consumes a server-sent events body, emitting each content delta.
+// Usage arrives in the final chunk when the server honours include_usage.
+func readStream(body io.Reader, events func(Event)) (string, Usage, error) {
+	var sb strings.Builder
+	var usage Usage
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+		var chunk chatResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return "", Usage{}, fmt.Errorf("invalid stream chunk: %v", err)
+		}
+		if chunk.Error != nil {
+			return "", Usage{}, errors.New(chunk.Error.Message)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.usage()
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			sb.WriteString(choice.Delta.Content)
+			emit(events, EventOutput, choice.Delta.Content)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", Usage{}, err
+	}
+	return sb.String(), usage, nil
+}
+
+// chatCompletionsURL accepts either a base URL (http://host/v1) or the full
+// endpoint.
+func chatCompletionsURL(base string) string {
+	base = strings.TrimRight(base, "/")
+	if strings.HasSuffix(base, "/chat/completions") {
+		return base
+	}
+	return base + "/chat/completions"
+}
@@ -0,0 +1,217 @@
+package codex
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// capture is an io.Writer that keeps at most limit bytes in memory. Once
+// the output grows past the limit, everything is spilled to a log file and
+// only the head and a rolling tail are retained for the reply.
+type capture struct {
+	limit     int
+	spillPath string
+
+	buf   []byte // everything, until the limit is exceeded; then the head
+	tail  []byte
+	total int64
+	file  *os.File
+	err   error
+}
+
+func newCapture(limit int, spillPath string) *capture {
+	return &capture{limit: limit, spillPath: spillPath}
+}
+
+func (c *capture) Write(p []byte) (int, error) {
+	c.total += int64(len(p))
+	if c.limit <= 0 {
+		c.buf = append(c.buf, p...)
+		return len(p), nil
+	}
+	if c.file == nil && len(c.buf)+len(p) <= c.limit {
+		c.buf = append(c.buf, p...)
+		return len(p), nil
+	}
+	if c.file == nil && c.err == nil {
+		c.spill()
+	}
+	if c.file != nil {
+		if _, err := c.file.Write(p); err != nil {
+			c.err = err
+			c.file.Close()
+			c.file = nil
+		}
+	}
+
+	half := c.limit / 2
+	if len(c.buf) > half {
+		// Keep the first half of what was buffered as the head; the rest
+		// seeds the tail.
+		c.tail = append(c.tail, c.buf[half:]...)
+		c.buf = c.buf[:half:half]
+	}
+	c.tail = append(c.tail, p...)
+	if len(c.tail) > half {
+		c.tail = append(c.tail[:0:0], c.tail[len(c.tail)-half:]...)
+	}
+	// Never report a short write: the command must not fail because the
+	// log could not be written.
+	return len(p), nil
+}
+
+// spill opens the log file and writes what has been buffered so far.
+func (c *capture) spill() {
+	if c.spillPath == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.spillPath), 0o755); err != nil {
+		c.err = err
+		return
+	}
+	file, err := os.Create(c.spillPath)
+	if err != nil {
+		c.err = err
+		return
+	}
+	if _, err := file.Write(c.buf); err != nil {
+		file.Close()
+		c.err = err
+		return
+	}
+	c.file = file
+}
+
+func (c *capture) close() error {
+	if c.file != nil {
+		err := c.file.Close()
+		c.file = nil
+		if err != nil {
+			c.err = err
+		}
+	}
+	return c.err
+}
+
+// truncated reports whether output was dropped from memory.
+func (c *capture) truncated() bool {
+	return c.total > int64(len(c.buf)+len(c.tail))
+}
+
+// String returns the full output, or head and tail excerpts around a marker
+// when it was truncated.
+func (c *capture) String() string {
+	if !c.truncated() {
+		return string(c.buf) + string(c.tail)
+	}
+	omitted := c.total - int64(len(c.buf)+len(c.tail))
+	// The cut points may split multi-byte characters.
+	head := strings.ToValidUTF8(string(c.buf), "")
+	tail := strings.ToValidUTF8(string(c.tail), "")
+	return fmt.Sprintf("%s\n\n... [%d bytes omitted] ...\n\n%s", head, omitted, tail)
+}
+
+// logName turns a trace (e.g. update_id=123) into a file-safe log name.
+func logName(trace string) string {
+	trace = strings.TrimSpace(trace)
+	if trace == "" {
+		return "job"
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		}
+		return '-'
+	}, trace)
+}
+
+// LogPath returns the spill file for a job trace and stream ("" for stdout,
+// "stderr" for stderr).
+func LogPath(dir, trace, stream string) string {
+	name := logName(trace)
+	if stream != "" {
+		name += "." + stream
+	}
+	return filepath.Join(dir, name+".log")
+}
+
+// pruneLogs deletes the logs in outputDir older than logRetention, so
+// runaway output cannot fill the disk over time.
+func (c *runner) pruneLogs() {
+	if c.outputDir == "" || c.logRetention <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(c.outputDir)
+	if err != nil {
+		if !os.IsNotExist(err) && c.logger != nil {
+			c.logger.Warnf("%s log prune failed: dir=%s err=%v", c.name, c.outputDir, err)
+		}
+		return
+	}
+	cutoff := time.Now().Add(-c.logRetention)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".log") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		path := filepath.Join(c.outputDir, entry.Name())
+		if err := os.Remove(path); err != nil {
+			if c.logger != nil {
+				c.logger.Warnf("%s log prune failed: path=%s err=%v", c.name, path, err)
+			}
+			continue
+		}
+		if c.logger != nil {
+			c.logger.Infof("%s log pruned: path=%s", c.name, path)
+		}
+	}
+}
+
+// outputPipe hands the child an *os.File to write to and copies what it
+// reads into dst. With any other writer exec copies the output itself and
+// Wait blocks until every process holding the pipe has exited, including
+// background children that outlive the command.
+type outputPipe struct {
+	r, w *os.File
+	done chan struct{}
+}
+
+func newOutputPipe(dst io.Writer) (*outputPipe, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	p := &outputPipe{r: r, w: w, done: make(chan struct{})}
+	go func() {
+		defer close(p.done)
+		_, _ = io.Copy(dst, r)
+	}()
+	return p, nil
+}
+
+// started closes the parent's copy of the write end once the child has it.
+func (p *outputPipe) started() {
+	_ = p.w.Close()
+}
+
+// finish waits up to timeout for the remaining output, then closes the read
+// end so the copy stops even if a process still holds the pipe.
+func (p *outputPipe) finish(timeout time.Duration) {
+	_ = p.w.Close()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-p.done:
+	case <-timer.C:
+	}
+	_ = p.r.Close()
+	<-p.done
+}
@@ -3,130 +3,401 @@ package codex
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"regexp"
 	"runtime"
 	"strings"
+	"sync/atomic"
 	"time"
 	"unicode"
 
+	"enoch/internal/agent"
 	"enoch/internal/config"
 	"enoch/internal/logging"
 )
 
+// ErrCanceled is returned when the caller cancels a run before it finishes.
+var ErrCanceled = agent.ErrCanceled
+
+const (
+	// treeRefresh is how often the descendants of a running command are
+	// looked up.
+	treeRefresh = time.Second
+	// outputDrain bounds the wait for output after the command exited.
+	outputDrain = 2 * time.Second
+)
+
+// Options describes how to invoke a CLI agent.
+type Options struct {
+	// Name is the backend name used for /backend selection and logs.
+	Name    string
+	Command string
+	Args    []string
+	// PromptMode is arg (append or replace {prompt}), stdin, or file (write
+	// the prompt to a temp file and replace {prompt_file} or append its path).
+	PromptMode string
+	Timeout    time.Duration
+	Workdir    string
+	UseTTY     bool
+	DisableCPR bool
+	Progress   time.Duration
+	// KillGrace is how long the process tree gets to exit after SIGTERM on
+	// cancel or timeout before it is killed.
+	KillGrace time.Duration
+	Retry     RetryPolicy
+	// Env filters the inherited environment; Home, when set, is exported
+	// as CODEX_HOME for this backend only.
+	Env  EnvPolicy
+	Home string
+	// OutputLimit caps the output kept in memory per stream (0 is
+	// unlimited). Larger output is spilled to a log file in OutputDir.
+	OutputLimit int
+	OutputDir   string
+	// LogRetention is how long logs in OutputDir are kept (0 keeps them).
+	LogRetention time.Duration
+	// UsagePattern extracts token usage from summary lines (named groups
+	// input, output, total); JSON usage events are always recognised.
+	UsagePattern string
+	// CodexFlags translates per-chat overrides (model, sandbox, ...) into
+	// Codex CLI flags. Other CLIs only receive the chat's extra args.
+	CodexFlags bool
+}
+
+// Client runs Codex or any other prompt-driven CLI as an agent.Agent. Its
+// options can be replaced while it is in use; runs already in progress
+// finish with the options they started with.
 type Client struct {
-	command    string
-	args       []string
-	promptMode string
-	timeout    time.Duration
-	workdir    string
-	useTTY     bool
-	disableCPR bool
-	progress   time.Duration
-	logger     *logging.Logger
+	name    string
+	logger  *logging.Logger
+	current atomic.Value // *runner
+}
+
+// runner is one immutable set of options.
+type runner struct {
+	name         string
+	command      string
+	args         []string
+	promptMode   string
+	timeout      time.Duration
+	workdir      string
+	useTTY       bool
+	disableCPR   bool
+	progress     time.Duration
+	killGrace    time.Duration
+	retry        RetryPolicy
+	env          EnvPolicy
+	home         string
+	outputLimit  int
+	outputDir    string
+	logRetention time.Duration
+	usagePattern *regexp.Regexp
+	codexFlags   bool
+	logger       *logging.Logger
 }
 
+// New returns the Codex backend configured by the CODEX_* settings.
 func New(cfg config.Config, logger *logging.Logger) *Client {
-	return &Client{
-		command:    cfg.CodexCommand,
-		args:       cfg.CodexArgs,
-		promptMode: cfg.CodexPromptMode,
-		timeout:    cfg.CodexTimeout,
-		workdir:    cfg.CodexWorkdir,
-		useTTY:     cfg.CodexUseTTY,
-		disableCPR: cfg.CodexDisableCPR,
-		progress:   cfg.CodexProgressInterval,
-		logger:     logger,
+	return NewCLI(OptionsFromConfig(cfg), logger)
+}
+
+// OptionsFromConfig returns the options of the built-in Codex backend.
+func OptionsFromConfig(cfg config.Config) Options {
+	return Options{
+		Name:         "codex",
+		Command:      cfg.CodexCommand,
+		Args:         cfg.CodexArgs,
+		PromptMode:   cfg.CodexPromptMode,
+		Timeout:      cfg.CodexTimeout,
+		Workdir:      cfg.CodexWorkdir,
+		UseTTY:       cfg.CodexUseTTY,
+		DisableCPR:   cfg.CodexDisableCPR,
+		Progress:     cfg.CodexProgressInterval,
+		KillGrace:    cfg.CodexKillGrace,
+		Retry:        RetryFromConfig(cfg),
+		Env:          EnvFromConfig(cfg),
+		Home:         cfg.CodexHomeOverride,
+		OutputLimit:  cfg.CodexOutputLimit,
+		OutputDir:    cfg.CodexOutputDir,
+		LogRetention: cfg.CodexLogRetention,
+		UsagePattern: cfg.CodexUsagePattern,
+		CodexFlags:   true,
 	}
 }
 
-func (c *Client) Run(prompt string) (string, error) {
-	prompt = strings.TrimSpace(prompt)
+// NewCLI returns a generic CLI backend.
+func NewCLI(opts Options, logger *logging.Logger) *Client {
+	c := &Client{name: opts.Name, logger: logger}
+	r := newRunner(opts, logger)
+	c.current.Store(r)
+	r.pruneLogs()
+	return c
+}
+
+// Update replaces the client's options. The name cannot change.
+func (c *Client) Update(opts Options) {
+	opts.Name = c.name
+	c.current.Store(newRunner(opts, c.logger))
+}
+
+func (c *Client) runner() *runner {
+	return c.current.Load().(*runner)
+}
+
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Run executes the CLI with the prompt and returns its output. Output lines
+// are streamed to events as they arrive.
+func (c *Client) Run(parent context.Context, req agent.Request, events func(agent.Event)) (agent.Result, error) {
+	return c.runner().run(parent, req, events)
+}
+
+// Environment returns the variables a run with the given per-chat extras
+// would see.
+func (c *Client) Environment(extra map[string]string) []string {
+	return c.runner().environment(extra)
+}
+
+func newRunner(opts Options, logger *logging.Logger) *runner {
+	var usagePattern *regexp.Regexp
+	if opts.UsagePattern != "" {
+		compiled, err := regexp.Compile(opts.UsagePattern)
+		if err != nil && logger != nil {
+			logger.Errorf("%s usage pattern invalid, usage extraction disabled: %v", opts.Name, err)
+		}
+		usagePattern = compiled
+	}
+	return &runner{
+		name:         opts.Name,
+		command:      opts.Command,
+		args:         opts.Args,
+		promptMode:   opts.PromptMode,
+		timeout:      opts.Timeout,
+		workdir:      opts.Workdir,
+		useTTY:       opts.UseTTY,
+		disableCPR:   opts.DisableCPR,
+		progress:     opts.Progress,
+		killGrace:    opts.KillGrace,
+		retry:        opts.Retry,
+		env:          opts.Env,
+		home:         opts.Home,
+		outputLimit:  opts.OutputLimit,
+		outputDir:    opts.OutputDir,
+		logRetention: opts.LogRetention,
+		usagePattern: usagePattern,
+		codexFlags:   opts.CodexFlags,
+		logger:       logger,
+	}
+}
+
+func (c *runner) run(parent context.Context, req agent.Request, events func(agent.Event)) (agent.Result, error) {
+	prompt := strings.TrimSpace(req.Prompt)
 	if prompt == "" {
 		if c.logger != nil {
-			c.logger.Errorf("codex prompt is empty")
+			c.logger.Errorf("%s prompt is empty", c.name)
 		}
-		return "", fmt.Errorf("empty prompt")
+		return agent.Result{}, fmt.Errorf("empty prompt")
 	}
 
-	args := make([]string, 0, len(c.args)+1)
-	args = append(args, c.args...)
+	args := mergeArgs(c.args, overrideArgs(req.Overrides, c.codexFlags))
 
 	promptPreview := truncatePrompt(prompt, 160)
 	if c.logger != nil {
-		c.logger.Debugf("codex invoke: cmd=%s args=%q mode=%s tty=%t prompt=%q", c.command, c.args, c.promptMode, c.useTTY, promptPreview)
+		c.logger.Debugf("%s invoke: %s cmd=%s args=%q mode=%s tty=%t prompt=%q", c.name, req.Trace, c.command, args, c.promptMode, c.useTTY, promptPreview)
 	}
 
-	if c.promptMode == "arg" {
+	switch c.promptMode {
+	case "arg":
 		var used bool
 		args, used = replacePromptPlaceholder(args, prompt)
 		if !used {
 			args = append(args, prompt)
 		}
+	case "file":
+		path, err := writePromptFile(prompt)
+		if err != nil {
+			if c.logger != nil {
+				c.logger.Errorf("%s prompt file failed: %s err=%v", c.name, req.Trace, err)
+			}
+			return agent.Result{}, fmt.Errorf("%s error: %v", c.name, err)
+		}
+		defer os.Remove(path)
+		var used bool
+		args, used = replacePlaceholder(args, "{prompt_file}", path)
+		if !used {
+			args = append(args, path)
+		}
+	}
+
+	workdir := c.workdir
+	if req.Workdir != "" {
+		workdir = req.Workdir
 	}
+	run := runSpec{trace: req.Trace, workdir: workdir, preview: promptPreview, env: req.Env, events: events}
 
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	max := c.retry.MaxAttempts
+	if max < 1 {
+		max = 1
+	}
+	// used sums the usage of every attempt, failed ones included.
+	var used agent.Usage
+	for attempt := 1; ; attempt++ {
+		result, err := c.attempt(parent, prompt, args, run)
+		used = used.Add(result.Usage)
+		result.Usage = used
+		if err == nil || errors.Is(err, ErrCanceled) {
+			return result, err
+		}
+		var runErr *agent.RunError
+		if !errors.As(err, &runErr) {
+			return result, err
+		}
+		runErr.Attempts = attempt
+
+		retry := attempt < max && c.retry.retryable(runErr.Class) && !runErr.SideEffects
+		if c.logger != nil {
+			c.logger.Warnf("%s attempt failed: %s attempt=%d/%d class=%s exit_code=%d side_effects=%t retry=%t",
+				c.name, req.Trace, attempt, max, runErr.Class, runErr.ExitCode, runErr.SideEffects, retry)
+		}
+		if !retry {
+			return result, err
+		}
+
+		wait := c.retry.backoff(attempt)
+		emitRetry(events, attempt+1, max, runErr.Class, wait)
+		timer := time.NewTimer(wait)
+		select {
+		case <-parent.Done():
+			timer.Stop()
+			return agent.Result{Usage: used}, ErrCanceled
+		case <-timer.C:
+		}
+		if c.logger != nil {
+			c.logger.Infof("%s retrying: %s attempt=%d/%d", c.name, req.Trace, attempt+1, max)
+		}
+	}
+}
+
+// attempt runs the command once with its own timeout.
+func (c *runner) attempt(parent context.Context, prompt string, args []string, run runSpec) (agent.Result, error) {
+	ctx, cancel := context.WithTimeout(parent, c.timeout)
 	defer cancel()
 
 	if c.useTTY {
-		output, err := c.runWithScript(ctx, prompt, args, promptPreview)
+		result, err := c.runWithScript(ctx, prompt, args, run)
 		if err != nil && isTTYError(err) {
 			if c.logger != nil {
-				c.logger.Warnf("codex tty error, retrying without tty: %v", err)
+				c.logger.Warnf("%s tty error, retrying without tty: %v", c.name, err)
 			}
-			return c.runWithoutTTY(ctx, prompt, args, promptPreview)
+			return c.runWithoutTTY(ctx, prompt, args, run)
 		}
-		return output, err
+		return result, err
 	}
-	return c.runWithoutTTY(ctx, prompt, args, promptPreview)
+	return c.runWithoutTTY(ctx, prompt, args, run)
+}
+
+// runSpec carries the per-run details shared by the run helpers.
+type runSpec struct {
+	trace   string
+	workdir string
+	preview string
+	env     map[string]string
+	events  func(agent.Event)
 }
 
-func (c *Client) runWithScript(ctx context.Context, prompt string, args []string, promptPreview string) (string, error) {
+func (c *runner) runWithScript(ctx context.Context, prompt string, args []string, run runSpec) (agent.Result, error) {
 	scriptPath, err := exec.LookPath("script")
 	if err != nil {
 		if c.logger != nil {
 			c.logger.Errorf("script command not found: %v", err)
 		}
-		return "", fmt.Errorf("script command not found; install util-linux or bsdutils")
+		return agent.Result{}, fmt.Errorf("script command not found; install util-linux or bsdutils")
 	}
 
 	scriptArgs := buildScriptArgs(c.command, args)
-	cmd := exec.CommandContext(ctx, scriptPath, scriptArgs...)
-	cmd.Dir = c.workdir
-	c.applyEnv(cmd)
+	cmd := exec.Command(scriptPath, scriptArgs...)
+	cmd.Dir = run.workdir
+	cmd.Env = c.environment(run.env)
 
 	if c.promptMode == "stdin" {
 		cmd.Stdin = strings.NewReader(prompt + "\n")
 	}
 
-	return c.runCommand(ctx, cmd, promptPreview)
+	return c.runCommand(ctx, cmd, run)
 }
 
-func (c *Client) runWithoutTTY(ctx context.Context, prompt string, args []string, promptPreview string) (string, error) {
-	cmd := exec.CommandContext(ctx, c.command, args...)
-	cmd.Dir = c.workdir
-	c.applyEnv(cmd)
+func (c *runner) runWithoutTTY(ctx context.Context, prompt string, args []string, run runSpec) (agent.Result, error) {
+	cmd := exec.Command(c.command, args...)
+	cmd.Dir = run.workdir
+	cmd.Env = c.environment(run.env)
 
 	if c.promptMode == "stdin" {
 		cmd.Stdin = strings.NewReader(prompt)
 	}
 
-	return c.runCommand(ctx, cmd, promptPreview)
+	return c.runCommand(ctx, cmd, run)
 }
 
-func (c *Client) runCommand(ctx context.Context, cmd *exec.Cmd, promptPreview string) (string, error) {
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+func (c *runner) runCommand(ctx context.Context, cmd *exec.Cmd, run runSpec) (agent.Result, error) {
+	promptPreview := run.preview
+	var stdoutPath, stderrPath string
+	if c.outputDir != "" {
+		stdoutPath = LogPath(c.outputDir, run.trace, "")
+		stderrPath = LogPath(c.outputDir, run.trace, "stderr")
+		// Drop logs of an earlier run with the same trace (scheduled jobs).
+		_ = os.Remove(stdoutPath)
+		_ = os.Remove(stderrPath)
+	}
+	stdout := newCapture(c.outputLimit, stdoutPath)
+	stderr := newCapture(c.outputLimit, stderrPath)
+	defer func() {
+		// Runs after the captures are closed.
+		if stdout.truncated() || stderr.truncated() {
+			c.pruneLogs()
+		}
+	}()
+	defer stdout.close()
+	defer stderr.close()
+	// exec copies stdout and stderr from separate goroutines, so each
+	// stream gets its own watchers.
+	toolsOut, toolsErr := &toolWatcher{}, &toolWatcher{}
+	usageOut := &usageWatcher{pattern: c.usagePattern}
+	usageErr := &usageWatcher{pattern: c.usagePattern}
+	var stdoutDst io.Writer = io.MultiWriter(stdout, toolsOut, usageOut)
+	stderrDst := io.MultiWriter(stderr, toolsErr, usageErr)
+	if run.events != nil {
+		lines := &lineEmitter{emit: run.events}
+		defer lines.flush()
+		stdoutDst = io.MultiWriter(stdout, toolsOut, usageOut, lines)
+	}
+	outPipe, err := newOutputPipe(stdoutDst)
+	if err != nil {
+		return agent.Result{}, &agent.RunError{Class: agent.ClassStart, ExitCode: -1, Err: fmt.Errorf("%s error: %v", c.name, err)}
+	}
+	errPipe, err := newOutputPipe(stderrDst)
+	if err != nil {
+		outPipe.finish(0)
+		return agent.Result{}, &agent.RunError{Class: agent.ClassStart, ExitCode: -1, Err: fmt.Errorf("%s error: %v", c.name, err)}
+	}
+	cmd.Stdout = outPipe.w
+	cmd.Stderr = errPipe.w
+	setProcessGroup(cmd)
 
 	if err := cmd.Start(); err != nil {
+		outPipe.finish(0)
+		errPipe.finish(0)
 		if c.logger != nil {
-			c.logger.Errorf("codex start failed: %v", err)
+			c.logger.Errorf("%s start failed: %v", c.name, err)
 		}
-		return "", fmt.Errorf("codex error: %v", err)
+		return agent.Result{}, &agent.RunError{Class: agent.ClassStart, ExitCode: -1, Err: fmt.Errorf("%s error: %v", c.name, err)}
 	}
+	outPipe.started()
+	errPipe.started()
 
 	errCh := make(chan error, 1)
 	go func() {
@@ -134,30 +405,98 @@ func (c *Client) runCommand(ctx context.Context, cmd *exec.Cmd, promptPreview st
 	}()
 
 	started := time.Now()
-	var ticker *time.Ticker
+	var tick <-chan time.Time
 	if c.progress > 0 {
-		ticker = time.NewTicker(c.progress)
+		ticker := time.NewTicker(c.progress)
 		defer ticker.Stop()
+		tick = ticker.C
 	}
 
-	var err error
+	pid := cmd.Process.Pid
+	tree := newProcessTree(pid)
+	// Record the groups of descendants while they can still be found from
+	// the leader, in case they exit and orphan processes of their own.
+	watch := time.NewTicker(treeRefresh)
+	defer watch.Stop()
+	stop := ctx.Done()
+	var grace <-chan time.Time
 	for {
 		select {
 		case err = <-errCh:
 			goto done
-		case <-ticker.C:
+		case <-stop:
+			// Signal the whole group: codex may have spawned shells, servers
+			// or other tools that would otherwise keep running.
+			stop = nil
+			if c.logger != nil {
+				c.logger.Debugf("%s stopping process group: pgid=%d grace=%s", c.name, pid, c.killGrace)
+			}
+			_ = tree.terminate()
+			timer := time.NewTimer(c.killGrace)
+			defer timer.Stop()
+			grace = timer.C
+		case <-grace:
+			grace = nil
+			if c.logger != nil {
+				c.logger.Warnf("%s did not exit within %s, killing process group: pgid=%d", c.name, c.killGrace, pid)
+			}
+			_ = tree.kill()
+		case <-watch.C:
+			tree.refresh()
+		case <-tick:
 			if c.logger != nil {
-				c.logger.Warnf("codex still running: elapsed=%s prompt=%q", time.Since(started).Truncate(time.Second), promptPreview)
+				c.logger.Warnf("%s still running: elapsed=%s prompt=%q", c.name, time.Since(started).Truncate(time.Second), promptPreview)
 			}
 		}
 	}
 
 done:
+	if tree.alive() {
+		// The leader exited but left descendants behind.
+		if c.logger != nil {
+			c.logger.Warnf("%s left orphaned processes, killing: pgid=%d", c.name, pid)
+		}
+		_ = tree.kill()
+	}
+	// The killed processes close their ends of the pipes; anything that
+	// still holds them is not waited for.
+	outPipe.finish(outputDrain)
+	errPipe.finish(outputDrain)
+	sideEffects := toolsOut.found || toolsErr.found
+	// Failed and canceled runs report their usage too: the tokens were
+	// spent either way.
+	usageOut.flush()
+	usageErr.flush()
+	usage := usageOut.usage
+	if usage.Empty() {
+		usage = usageErr.usage
+	}
+
+	if ctx.Err() == context.Canceled {
+		if c.logger != nil {
+			c.logger.Warnf("%s canceled: prompt=%q", c.name, promptPreview)
+		}
+		return agent.Result{Usage: usage}, ErrCanceled
+	}
 	if ctx.Err() == context.DeadlineExceeded {
 		if c.logger != nil {
-			c.logger.Errorf("codex timeout after %s", c.timeout)
+			c.logger.Errorf("%s timeout after %s", c.name, c.timeout)
+		}
+		return agent.Result{Usage: usage}, &agent.RunError{
+			Class:       agent.ClassTimeout,
+			ExitCode:    -1,
+			SideEffects: sideEffects,
+			Err:         fmt.Errorf("%s timeout after %s", c.name, c.timeout),
 		}
-		return "", fmt.Errorf("codex timeout after %s", c.timeout)
+	}
+
+	for _, stream := range []*capture{stdout, stderr} {
+		if stream.close() != nil && c.logger != nil {
+			c.logger.Warnf("%s output log failed: %s path=%s err=%v", c.name, run.trace, stream.spillPath, stream.err)
+		}
+	}
+	if stdout.truncated() && c.logger != nil {
+		c.logger.Warnf("%s output truncated: %s bytes=%d log=%s", c.name, run.trace, stdout.total, stdoutPath)
 	}
 
 	output := strings.TrimSpace(stdout.String())
@@ -166,28 +505,46 @@ done:
 	if err != nil {
 		if c.logger != nil {
 			if output != "" {
-				c.logger.Errorf("codex stdout: %s", output)
+				c.logger.Errorf("%s stdout: %s", c.name, output)
 			}
 			if errOutput != "" {
-				c.logger.Errorf("codex stderr: %s", errOutput)
+				c.logger.Errorf("%s stderr: %s", c.name, errOutput)
 			}
-			c.logger.Errorf("codex exit error: %v", err)
+			c.logger.Errorf("%s exit error: %v", c.name, err)
+		}
+		exitCode := -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exitCode = exitErr.ExitCode()
+		}
+		detail := errOutput
+		if detail == "" {
+			detail = output
+		}
+		return agent.Result{Usage: usage}, &agent.RunError{
+			Class:       classifyFailure(detail),
+			ExitCode:    exitCode,
+			SideEffects: sideEffects,
+			Detail:      truncateTail(detail, 2000),
+			Err:         fmt.Errorf("%s failed; check logs", c.name),
 		}
-		return "", fmt.Errorf("codex failed; check logs")
 	}
 
 	if output == "" && errOutput != "" {
-		return errOutput, nil
+		return agent.Result{Output: errOutput, Truncated: stderr.truncated(), Usage: usage}, nil
 	}
-	return output, nil
+	return agent.Result{Output: output, Truncated: stdout.truncated(), Usage: usage}, nil
 }
 
-func (c *Client) applyEnv(cmd *exec.Cmd) {
-	env := os.Environ()
+func (c *runner) environment(extra map[string]string) []string {
+	set := map[string]string{}
+	if c.home != "" {
+		set["CODEX_HOME"] = c.home
+	}
 	if c.disableCPR {
-		env = append(env, "PROMPT_TOOLKIT_NO_CPR=1")
+		set["PROMPT_TOOLKIT_NO_CPR"] = "1"
 	}
-	cmd.Env = env
+	return c.env.Build(os.Environ(), extra, set)
 }
 
 func buildScriptArgs(command string, args []string) []string {
@@ -226,12 +583,16 @@ func shellQuote(input string) string {
 }
 
 func replacePromptPlaceholder(args []string, prompt string) ([]string, bool) {
+	return replacePlaceholder(args, "{prompt}", prompt)
+}
+
+func replacePlaceholder(args []string, placeholder, value string) ([]string, bool) {
 	used := false
 	out := make([]string, 0, len(args))
 	for _, arg := range args {
-		if strings.Contains(arg, "{prompt}") {
+		if strings.Contains(arg, placeholder) {
 			used = true
-			out = append(out, strings.ReplaceAll(arg, "{prompt}", prompt))
+			out = append(out, strings.ReplaceAll(arg, placeholder, value))
 			continue
 		}
 		out = append(out, arg)
@@ -239,6 +600,97 @@ func replacePromptPlaceholder(args []string, prompt string) ([]string, bool) {
 	return out, used
 }
 
+func writePromptFile(prompt string) (string, error) {
+	file, err := os.CreateTemp("", "enoch-prompt-*.txt")
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.WriteString(prompt); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// overrideArgs translates per-chat overrides into CLI flags.
+func overrideArgs(o agent.Overrides, codexFlags bool) []string {
+	var out []string
+	if codexFlags {
+		if o.Profile != "" {
+			out = append(out, "--profile", o.Profile)
+		}
+		if o.Model != "" {
+			out = append(out, "--model", o.Model)
+		}
+		if o.ReasoningEffort != "" {
+			out = append(out, "-c", "model_reasoning_effort="+o.ReasoningEffort)
+		}
+		if o.Sandbox != "" {
+			out = append(out, "--sandbox", o.Sandbox)
+		}
+		if o.Approval != "" {
+			out = append(out, "-c", "approval_policy="+o.Approval)
+		}
+	}
+	return append(out, o.ExtraArgs...)
+}
+
+// mergeArgs inserts extra flags before the prompt placeholder so they stay
+// attached to the subcommand ("exec --model x {prompt}"); without a
+// placeholder they are appended.
+func mergeArgs(args, extra []string) []string {
+	out := make([]string, 0, len(args)+len(extra)+1)
+	at := len(args)
+	for i, arg := range args {
+		if strings.Contains(arg, "{prompt}") || strings.Contains(arg, "{prompt_file}") {
+			at = i
+			break
+		}
+	}
+	out = append(out, args[:at]...)
+	out = append(out, extra...)
+	return append(out, args[at:]...)
+}
+
+// lineEmitter turns streamed output into one event per non-empty line, each
+// terminated by a newline.
+type lineEmitter struct {
+	emit    func(agent.Event)
+	pending []byte
+}
+
+func (l *lineEmitter) Write(p []byte) (int, error) {
+	l.pending = append(l.pending, p...)
+	for {
+		idx := bytes.IndexByte(l.pending, '\n')
+		if idx < 0 {
+			break
+		}
+		l.send(l.pending[:idx])
+		l.pending = l.pending[idx+1:]
+	}
+	return len(p), nil
+}
+
+func (l *lineEmitter) flush() {
+	if len(l.pending) > 0 {
+		l.send(l.pending)
+		l.pending = nil
+	}
+}
+
+func (l *lineEmitter) send(line []byte) {
+	text := strings.TrimSpace(string(line))
+	if text != "" {
+		l.emit(agent.Event{Kind: agent.EventOutput, Text: text + "\n"})
+	}
+}
+
 func buildCommandError(output string, errOutput string, err error) error {
 	if errOutput != "" {
 		return fmt.Errorf("codex error: %s", errOutput)
@@ -251,6 +703,10 @@ func buildCommandError(output string, errOutput string, err error) error {
 
 func isTTYError(err error) bool {
 	message := strings.ToLower(err.Error())
+	var runErr *agent.RunError
+	if errors.As(err, &runErr) {
+		message += "\n" + strings.ToLower(runErr.Detail)
+	}
 	if strings.Contains(message, "stdin is not a terminal") {
 		return true
 	}
@@ -2,8 +2,13 @@ package codex
 
 import (
 	"errors"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"enoch/internal/agent"
 )
 
 func TestReplacePromptPlaceholder(t *testing.T) {
@@ -75,3 +80,95 @@ func TestTruncatePrompt(t *testing.T) {
 		t.Fatalf("unexpected: %q", got)
 	}
 }
+
+func TestMergeArgsInsertsBeforePrompt(t *testing.T) {
+	extra := overrideArgs(agent.Overrides{Model: "o3", Sandbox: "read-only", ExtraArgs: []string{"--search"}}, true)
+	out := mergeArgs([]string{"exec", "{prompt}"}, extra)
+	want := "exec --model o3 --sandbox read-only --search {prompt}"
+	if got := strings.Join(out, " "); got != want {
+		t.Fatalf("args mismatch:\n got %q\nwant %q", got, want)
+	}
+
+	out = mergeArgs([]string{"exec"}, overrideArgs(agent.Overrides{Model: "o3"}, false))
+	if got := strings.Join(out, " "); got != "exec" {
+		t.Fatalf("generic CLI should ignore codex flags, got %q", got)
+	}
+}
+
+func TestCaptureSpillsAndKeepsExcerpts(t *testing.T) {
+	path := LogPath(t.TempDir(), "update_id=7", "")
+	c := newCapture(8, path)
+	for _, chunk := range []string{"head", "er-", "middle-", "the-tail"} {
+		if _, err := c.Write([]byte(chunk)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := c.close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	if !c.truncated() {
+		t.Fatalf("expected truncation")
+	}
+	out := c.String()
+	if !strings.HasPrefix(out, "head") || !strings.HasSuffix(out, "tail") || !strings.Contains(out, "bytes omitted") {
+		t.Fatalf("unexpected excerpt: %q", out)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read spill: %v", err)
+	}
+	if string(data) != "header-middle-the-tail" {
+		t.Fatalf("spill mismatch: %q", data)
+	}
+	if filepath.Base(path) != "update_id-7.log" {
+		t.Fatalf("unexpected log name: %s", path)
+	}
+}
+
+func TestCaptureKeepsSmallOutput(t *testing.T) {
+	c := newCapture(64, "")
+	_, _ = c.Write([]byte("hello"))
+	if c.truncated() || c.String() != "hello" {
+		t.Fatalf("unexpected capture: %q", c.String())
+	}
+}
+
+func TestRunnerPrunesOldLogs(t *testing.T) {
+	dir := t.TempDir()
+	old := LogPath(dir, "update_id=1", "")
+	fresh := LogPath(dir, "update_id=2", "stderr")
+	other := filepath.Join(dir, "notes.txt")
+	for _, path := range []string{old, fresh, other} {
+		if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stale := time.Now().Add(-48 * time.Hour)
+	for _, path := range []string{old, other} {
+		if err := os.Chtimes(path, stale, stale); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	NewCLI(Options{Name: "fake", Command: "true", OutputDir: dir, LogRetention: 24 * time.Hour}, nil)
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatalf("expected the old log removed, got %v", err)
+	}
+	for _, path := range []string{fresh, other} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("expected %s kept: %v", path, err)
+		}
+	}
+}
+
+func TestClientUpdateSwapsOptions(t *testing.T) {
+	c := NewCLI(Options{Name: "local", Command: "first"}, nil)
+	c.Update(Options{Name: "renamed", Command: "second"})
+	if c.Name() != "local" {
+		t.Fatalf("expected name to stay, got %q", c.Name())
+	}
+	if got := c.runner().command; got != "second" {
+		t.Fatalf("expected updated command, got %q", got)
+	}
+}
@@ -0,0 +1,139 @@
+package codex
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"enoch/internal/config"
+	"enoch/internal/redact"
+)
+
+// botSecrets are never passed to subprocesses: they belong to the bot, not
+// to the agent or the commands it runs.
+var botSecrets = []string{
+	"TELEGRAM_BOT_TOKEN", "TELEGRAM_BOT_TOKEN_FILE", "BACKEND_HTTP_API_KEY", "BACKEND_HTTP_API_KEY_FILE",
+	"ENOCH_ADMIN_TOKEN", "ENOCH_ADMIN_TOKEN_FILE",
+}
+
+// EnvPolicy decides which variables a subprocess inherits.
+type EnvPolicy struct {
+	// Allow lists name patterns (path.Match syntax, e.g. LC_*) that are
+	// inherited; empty inherits everything not denied.
+	Allow []string
+	// Deny lists name patterns that are never inherited.
+	Deny []string
+	// SecretValues are stripped whatever variable carries them (e.g. the
+	// bot token copied under another name).
+	SecretValues []string
+}
+
+// EnvFromConfig returns the policy configured by CODEX_ENV_*. The values of
+// the bot's own secrets are stripped; other sensitive variables (e.g.
+// OPENAI_API_KEY) are left to CODEX_ENV_DENY, since the agent may need them.
+func EnvFromConfig(cfg config.Config) EnvPolicy {
+	return EnvPolicy{
+		Allow:        cfg.CodexEnvAllow,
+		Deny:         cfg.CodexEnvDeny,
+		SecretValues: cfg.BotSecrets(),
+	}
+}
+
+// Build returns the environment for a subprocess: the filtered base, then
+// extra (per-chat variables), then set (backend overrides such as
+// CODEX_HOME), later entries winning.
+func (p EnvPolicy) Build(base []string, extra, set map[string]string) []string {
+	values := map[string]string{}
+	order := []string{}
+	put := func(name, value string) {
+		if _, ok := values[name]; !ok {
+			order = append(order, name)
+		}
+		values[name] = value
+	}
+	for _, entry := range base {
+		name, value, ok := splitEnv(entry)
+		if !ok || !p.inherits(name, value) {
+			continue
+		}
+		put(name, value)
+	}
+	for _, source := range []map[string]string{extra, set} {
+		names := make([]string, 0, len(source))
+		for name := range source {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if IsBotSecret(name) {
+				continue
+			}
+			put(name, source[name])
+		}
+	}
+
+	env := make([]string, 0, len(order))
+	for _, name := range order {
+		env = append(env, name+"="+values[name])
+	}
+	return env
+}
+
+func (p EnvPolicy) inherits(name, value string) bool {
+	if IsBotSecret(name) {
+		return false
+	}
+	for _, secret := range p.SecretValues {
+		if secret != "" && value == secret {
+			return false
+		}
+	}
+	if matchEnv(p.Deny, name) {
+		return false
+	}
+	return len(p.Allow) == 0 || matchEnv(p.Allow, name)
+}
+
+// IsBotSecret reports whether name is one of the bot's own secrets.
+func IsBotSecret(name string) bool {
+	for _, secret := range botSecrets {
+		if strings.EqualFold(name, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchEnv reports whether name matches any of the patterns.
+func matchEnv(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactEnv masks the values of sensitive-looking variables.
+func RedactEnv(env []string) []string {
+	out := make([]string, 0, len(env))
+	for _, entry := range env {
+		name, value, ok := splitEnv(entry)
+		if !ok {
+			continue
+		}
+		if value != "" && redact.IsSensitive(name) {
+			value = redact.Mask
+		}
+		out = append(out, name+"="+value)
+	}
+	return out
+}
+
+func splitEnv(entry string) (string, string, bool) {
+	idx := strings.Index(entry, "=")
+	if idx <= 0 {
+		return "", "", false
+	}
+	return entry[:idx], entry[idx+1:], true
+}
@@ -0,0 +1,53 @@
+package codex
+
+import (
+	"strings"
+	"testing"
+
+	"enoch/internal/config"
+)
+
+func TestEnvPolicyStripsBotSecrets(t *testing.T) {
+	policy := EnvPolicy{Deny: []string{"AWS_*"}, SecretValues: []string{"123:abc"}}
+	base := []string{
+		"PATH=/usr/bin",
+		"TELEGRAM_BOT_TOKEN=123:abc",
+		"COPIED_TOKEN=123:abc",
+		"AWS_SECRET_ACCESS_KEY=x",
+		"HOME=/root",
+	}
+	env := policy.Build(base, map[string]string{"GIT_AUTHOR_NAME": "bot", "TELEGRAM_BOT_TOKEN": "x"}, map[string]string{"CODEX_HOME": "/srv/codex"})
+	got := strings.Join(env, " ")
+	want := "PATH=/usr/bin HOME=/root GIT_AUTHOR_NAME=bot CODEX_HOME=/srv/codex"
+	if got != want {
+		t.Fatalf("env mismatch:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestEnvFromConfigStripsCopiedBotSecrets(t *testing.T) {
+	cfg := config.Config{
+		TelegramBotToken: "123:abc",
+		AdminToken:       "admin-secret",
+		Backends:         []config.BackendConfig{{Name: "local", APIKey: "sk-backend"}},
+	}
+	base := []string{"PATH=/bin", "A=123:abc", "B=admin-secret", "C=sk-backend", "OPENAI_API_KEY=sk-openai"}
+	env := EnvFromConfig(cfg).Build(base, nil, nil)
+	if got := strings.Join(env, " "); got != "PATH=/bin OPENAI_API_KEY=sk-openai" {
+		t.Fatalf("unexpected env: %q", got)
+	}
+}
+
+func TestEnvPolicyAllowList(t *testing.T) {
+	policy := EnvPolicy{Allow: []string{"PATH", "LC_*"}}
+	env := policy.Build([]string{"PATH=/bin", "LC_ALL=C", "SECRET=x"}, nil, nil)
+	if got := strings.Join(env, " "); got != "PATH=/bin LC_ALL=C" {
+		t.Fatalf("unexpected env: %q", got)
+	}
+}
+
+func TestRedactEnv(t *testing.T) {
+	env := RedactEnv([]string{"OPENAI_API_KEY=sk-1", "PATH=/bin", "GITHUB_TOKEN="})
+	if got := strings.Join(env, " "); got != "OPENAI_API_KEY=*** PATH=/bin GITHUB_TOKEN=" {
+		t.Fatalf("unexpected redaction: %q", got)
+	}
+}
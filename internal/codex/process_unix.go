@@ -0,0 +1,142 @@
+//go:build !windows
+// +build !windows
+
+package codex
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// setProcessGroup starts the command in its own process group so the whole
+// tree it spawns can be signalled at once.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// processTree is the set of process groups a command and its descendants
+// run in. Descendants may leave the leader's group: script(1) runs the
+// command on the pty in a session of its own, so signalling the leader's
+// group alone would miss it. Groups are recorded while their members are
+// still reachable from the leader, so they can be signalled after the
+// processes in between have exited and the rest were reparented.
+type processTree struct {
+	leader int
+	groups map[int]bool
+}
+
+func newProcessTree(pid int) *processTree {
+	return &processTree{leader: pid, groups: map[int]bool{pid: true}}
+}
+
+// refresh records the groups of the leader's current descendants.
+func (t *processTree) refresh() {
+	own := syscall.Getpgrp()
+	for _, pid := range descendants(t.leader) {
+		if pgid, err := syscall.Getpgid(pid); err == nil && pgid != own {
+			t.groups[pgid] = true
+		}
+	}
+}
+
+func (t *processTree) signal(sig syscall.Signal) error {
+	t.refresh()
+	var first error
+	for pgid := range t.groups {
+		if err := syscall.Kill(-pgid, sig); err != nil && err != syscall.ESRCH && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// terminate asks every process of the tree to exit.
+func (t *processTree) terminate() error {
+	return t.signal(syscall.SIGTERM)
+}
+
+// kill force-kills every process of the tree.
+func (t *processTree) kill() error {
+	return t.signal(syscall.SIGKILL)
+}
+
+// alive reports whether any recorded group still has a running process.
+func (t *processTree) alive() bool {
+	t.refresh()
+	for pgid := range t.groups {
+		if syscall.Kill(-pgid, 0) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// descendants returns the pids of every process below pid.
+func descendants(pid int) []int {
+	children := map[int][]int{}
+	for child, parent := range parentPids() {
+		children[parent] = append(children[parent], child)
+	}
+	var out []int
+	queue := []int{pid}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		for _, child := range children[next] {
+			out = append(out, child)
+			queue = append(queue, child)
+		}
+	}
+	return out
+}
+
+// parentPids maps every running pid to its parent, from /proc where there
+// is one and from ps(1) elsewhere.
+func parentPids() map[int]int {
+	parents := map[int]int{}
+	entries, err := os.ReadDir("/proc")
+	if err == nil {
+		for _, entry := range entries {
+			pid, err := strconv.Atoi(entry.Name())
+			if err != nil {
+				continue
+			}
+			data, err := os.ReadFile("/proc/" + entry.Name() + "/stat")
+			if err != nil {
+				continue
+			}
+			// The command name may contain spaces; the fields after it are
+			// "state ppid ...".
+			stat := string(data)
+			fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+			if len(fields) < 2 {
+				continue
+			}
+			if ppid, err := strconv.Atoi(fields[1]); err == nil {
+				parents[pid] = ppid
+			}
+		}
+		if len(parents) > 0 {
+			return parents
+		}
+	}
+	out, err := exec.Command("ps", "-A", "-o", "pid=", "-o", "ppid=").Output()
+	if err != nil {
+		return parents
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		pid, err1 := strconv.Atoi(fields[0])
+		ppid, err2 := strconv.Atoi(fields[1])
+		if err1 == nil && err2 == nil {
+			parents[pid] = ppid
+		}
+	}
+	return parents
+}
@@ -0,0 +1,117 @@
+//go:build !windows
+// +build !windows
+
+package codex
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"enoch/internal/agent"
+)
+
+// processGone reports whether pid has exited (zombies awaiting reaping by
+// init count as gone).
+func processGone(pid int) bool {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return true
+	}
+	fields := strings.Fields(string(data))
+	return len(fields) > 2 && fields[2] == "Z"
+}
+
+func waitGone(t *testing.T, pid int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if processGone(pid) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("grandchild %d still running", pid)
+}
+
+func readPid(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read pid file: %v", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatalf("parse pid: %v", err)
+	}
+	return pid
+}
+
+func runTree(t *testing.T, script string, ctx context.Context, tty bool) (int, error) {
+	t.Helper()
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("procfs not available")
+	}
+	if _, err := exec.LookPath("script"); tty && err != nil {
+		t.Skip("script not available")
+	}
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	c := NewCLI(Options{
+		Name:       "fake",
+		Command:    "sh",
+		Args:       []string{"-c", script, "sh", pidFile},
+		PromptMode: "stdin",
+		Timeout:    300 * time.Millisecond,
+		KillGrace:  200 * time.Millisecond,
+		UseTTY:     tty,
+	}, nil)
+
+	started := time.Now()
+	_, err := c.Run(ctx, agent.Request{Prompt: "hi"}, nil)
+	if elapsed := time.Since(started); elapsed > 3*time.Second {
+		t.Fatalf("run took too long: %s", elapsed)
+	}
+	return readPid(t, pidFile), err
+}
+
+func TestTimeoutKillsGrandchildren(t *testing.T) {
+	pid, err := runTree(t, `sleep 30 & echo $! > "$1"; wait`, context.Background(), false)
+	if err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	waitGone(t, pid)
+}
+
+func TestCancelKillsGrandchildrenIgnoringSIGTERM(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	pid, err := runTree(t, `trap "" TERM; sleep 30 & echo $! > "$1"; wait`, ctx, false)
+	if err != ErrCanceled {
+		t.Fatalf("expected ErrCanceled, got %v", err)
+	}
+	waitGone(t, pid)
+}
+
+func TestTimeoutKillsTTYGrandchildren(t *testing.T) {
+	// script(1) runs the command in a session of its own, outside the
+	// leader's process group. Ignored signals are inherited, so only
+	// SIGKILL sent to the right group stops the grandchild.
+	pid, err := runTree(t, `trap "" TERM HUP; sleep 30 & echo $! > "$1"; wait`, context.Background(), true)
+	if err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	waitGone(t, pid)
+}
+
+func TestExitDoesNotWaitForBackgroundOutput(t *testing.T) {
+	pid, err := runTree(t, `sleep 30 & echo $! > "$1"; echo done`, context.Background(), false)
+	if err != nil {
+		t.Fatalf("expected the run to finish when the leader exits, got %v", err)
+	}
+	waitGone(t, pid)
+}
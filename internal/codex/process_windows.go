@@ -0,0 +1,39 @@
+//go:build windows
+// +build windows
+
+package codex
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Windows has no process groups we can signal portably; only the direct
+// child is stopped.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+type processTree struct {
+	leader int
+}
+
+func newProcessTree(pid int) *processTree {
+	return &processTree{leader: pid}
+}
+
+func (t *processTree) refresh() {}
+
+func (t *processTree) terminate() error {
+	return t.kill()
+}
+
+func (t *processTree) kill() error {
+	p, err := os.FindProcess(t.leader)
+	if err != nil {
+		return err
+	}
+	return p.Kill()
+}
+
+func (t *processTree) alive() bool {
+	return false
+}
@@ -0,0 +1,147 @@
+package codex
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"time"
+
+	"enoch/internal/agent"
+	"enoch/internal/config"
+)
+
+// RetryPolicy controls how failed attempts are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts (1 disables retries).
+	MaxAttempts int
+	// Backoff is the wait before the first retry; it doubles per retry up
+	// to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Classes lists the retryable agent error classes.
+	Classes []string
+}
+
+// RetryFromConfig returns the policy configured by CODEX_RETRY_*.
+func RetryFromConfig(cfg config.Config) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: cfg.CodexRetryAttempts,
+		Backoff:     cfg.CodexRetryBackoff,
+		MaxBackoff:  cfg.CodexRetryMaxBackoff,
+		Classes:     cfg.CodexRetryOn,
+	}
+}
+
+func (p RetryPolicy) retryable(class string) bool {
+	for _, c := range p.Classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the wait after the given failed attempt (1-based).
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	wait := p.Backoff
+	for i := 1; i < attempt; i++ {
+		wait *= 2
+		if p.MaxBackoff > 0 && wait >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return wait
+}
+
+// statusPattern matches an HTTP status code named as such ("status 429",
+// "HTTP/1.1 502", "status_code=401", `"status": 503`), so line numbers and
+// ids in the output are not mistaken for one.
+var statusPattern = regexp.MustCompile(`(?:\bstatus(?:[ _]?code)?|\bhttp(?:/[\d.]+)?|\berror[ _]code)["']?\s*[:=]?\s*(\d{3})\b`)
+
+// classifyFailure guesses why a command failed from its error output.
+func classifyFailure(output string) string {
+	text := strings.ToLower(output)
+	codes := map[string]bool{}
+	for _, m := range statusPattern.FindAllStringSubmatch(text, -1) {
+		codes[m[1]] = true
+	}
+	switch {
+	case codes["401"] || containsAny(text, "unauthorized", "invalid api key", "invalid_api_key", "not logged in", "token expired"):
+		return agent.ClassAuth
+	case codes["429"] || containsAny(text, "too many requests", "rate limit", "rate_limit", "quota exceeded"):
+		return agent.ClassRateLimit
+	case codes["500"] || codes["502"] || codes["503"] || codes["504"] ||
+		containsAny(text, "500 internal", "internal server error", "bad gateway", "service unavailable", "gateway timeout", "overloaded"):
+		return agent.ClassServer
+	case containsAny(text, "connection reset", "connection refused", "no such host", "network is unreachable",
+		"stream disconnected", "tls handshake", "i/o timeout", "unexpected eof", "error sending request"):
+		return agent.ClassNetwork
+	}
+	return agent.ClassExit
+}
+
+func containsAny(text string, needles ...string) bool {
+	for _, needle := range needles {
+		if strings.Contains(text, needle) {
+			return true
+		}
+	}
+	return false
+}
+
+// toolWatcher scans output for signs that Codex ran tools which may have
+// changed files or state. Such attempts are never retried.
+type toolWatcher struct {
+	pending []byte
+	found   bool
+}
+
+func (w *toolWatcher) Write(p []byte) (int, error) {
+	if w.found {
+		return len(p), nil
+	}
+	w.pending = append(w.pending, p...)
+	for {
+		idx := bytes.IndexByte(w.pending, '\n')
+		if idx < 0 {
+			break
+		}
+		if isToolLine(string(w.pending[:idx])) {
+			w.found = true
+			w.pending = nil
+			return len(p), nil
+		}
+		w.pending = w.pending[idx+1:]
+	}
+	if len(w.pending) > 4096 {
+		w.pending = w.pending[len(w.pending)-4096:]
+	}
+	return len(p), nil
+}
+
+// isToolLine matches Codex progress output for command execution and file
+// edits, both in the plain (exec / apply_patch) and --json formats.
+func isToolLine(line string) bool {
+	line = strings.TrimSpace(line)
+	if line == "exec" || strings.HasPrefix(line, "exec ") || strings.Contains(line, "] exec ") {
+		return true
+	}
+	return containsAny(line, "apply_patch", "file update", `"command_execution"`, `"file_change"`)
+}
+
+func emitRetry(events func(agent.Event), attempt, max int, class string, wait time.Duration) {
+	if events != nil {
+		events(agent.Event{Kind: agent.EventRetry, Text: class, Attempt: attempt, MaxAttempts: max, Wait: wait})
+	}
+}
+
+// truncateTail keeps the last limit bytes of text.
+func truncateTail(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	return strings.ToValidUTF8(text[len(text)-limit:], "")
+}
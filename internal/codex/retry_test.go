@@ -0,0 +1,132 @@
+package codex
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"enoch/internal/agent"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	cases := map[string]string{
+		"unexpected status 401 Unauthorized":          agent.ClassAuth,
+		"stream error: 429 Too Many Requests":         agent.ClassRateLimit,
+		"upstream returned 503 Service Unavailable":   agent.ClassServer,
+		"error sending request: connection reset":     agent.ClassNetwork,
+		"thread 'main' panicked at src/main.rs:12:5.": agent.ClassExit,
+		"request failed with status: 429":             agent.ClassRateLimit,
+		"HTTP/1.1 502":                                agent.ClassServer,
+		`{"error":{"status_code":401}}`:               agent.ClassAuth,
+		"error: tests/api.rs:401:7 assertion failed":  agent.ClassExit,
+		"request id req_4290502 failed, see line 503": agent.ClassExit,
+	}
+	for output, want := range cases {
+		if got := classifyFailure(output); got != want {
+			t.Fatalf("classifyFailure(%q) = %s, want %s", output, got, want)
+		}
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{Backoff: time.Second, MaxBackoff: 3 * time.Second}
+	if got := p.backoff(1); got != time.Second {
+		t.Fatalf("first backoff: %s", got)
+	}
+	if got := p.backoff(2); got != 2*time.Second {
+		t.Fatalf("second backoff: %s", got)
+	}
+	if got := p.backoff(5); got != 3*time.Second {
+		t.Fatalf("capped backoff: %s", got)
+	}
+}
+
+func newRetryClient(t *testing.T, script string) (*Client, string) {
+	t.Helper()
+	counter := filepath.Join(t.TempDir(), "attempts")
+	return NewCLI(Options{
+		Name:       "fake",
+		Command:    "sh",
+		Args:       []string{"-c", script, "sh", counter},
+		PromptMode: "stdin",
+		Timeout:    5 * time.Second,
+		KillGrace:  time.Second,
+		Retry: RetryPolicy{
+			MaxAttempts: 3,
+			Backoff:     10 * time.Millisecond,
+			Classes:     []string{agent.ClassRateLimit},
+		},
+	}, nil), counter
+}
+
+func TestRunRetriesRateLimit(t *testing.T) {
+	// Fails with a 429 on the first attempt only.
+	c, _ := newRetryClient(t, `if [ ! -f "$1" ]; then touch "$1"; echo "429 Too Many Requests" >&2; exit 1; fi; echo ok`)
+
+	var retries []agent.Event
+	res, err := c.Run(context.Background(), agent.Request{Prompt: "hi"}, func(e agent.Event) {
+		if e.Kind == agent.EventRetry {
+			retries = append(retries, e)
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Output != "ok" {
+		t.Fatalf("output mismatch: %q", res.Output)
+	}
+	if len(retries) != 1 || retries[0].Attempt != 2 || retries[0].Text != agent.ClassRateLimit {
+		t.Fatalf("unexpected retry events: %+v", retries)
+	}
+}
+
+func TestRunDoesNotRetryAfterToolCalls(t *testing.T) {
+	c, _ := newRetryClient(t, `echo "exec bash -lc 'rm -rf build'" >&2; echo "429 Too Many Requests" >&2; exit 1`)
+
+	_, err := c.Run(context.Background(), agent.Request{Prompt: "hi"}, nil)
+	var runErr *agent.RunError
+	if !errors.As(err, &runErr) {
+		t.Fatalf("expected RunError, got %v", err)
+	}
+	if runErr.Attempts != 1 || !runErr.SideEffects || runErr.Class != agent.ClassRateLimit {
+		t.Fatalf("unexpected error: %+v", runErr)
+	}
+	if runErr.ExitCode != 1 {
+		t.Fatalf("exit code mismatch: %d", runErr.ExitCode)
+	}
+}
+
+func TestRunDoesNotRetryOtherClasses(t *testing.T) {
+	c, _ := newRetryClient(t, `echo "boom" >&2; exit 2`)
+
+	_, err := c.Run(context.Background(), agent.Request{Prompt: "hi"}, nil)
+	var runErr *agent.RunError
+	if !errors.As(err, &runErr) || runErr.Attempts != 1 || runErr.Class != agent.ClassExit {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRunReportsUsageOfEveryAttempt(t *testing.T) {
+	// Each attempt reports 10 tokens; the first one then fails with a 429.
+	usage := `echo '{"usage":{"input_tokens":8,"output_tokens":2}}'; `
+	c, _ := newRetryClient(t, usage+`if [ ! -f "$1" ]; then touch "$1"; echo "429 Too Many Requests" >&2; exit 1; fi; echo ok`)
+
+	res, err := c.Run(context.Background(), agent.Request{Prompt: "hi"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := (agent.Usage{Input: 16, Output: 4, Total: 20}); res.Usage != want {
+		t.Fatalf("expected usage %+v, got %+v", want, res.Usage)
+	}
+
+	c, _ = newRetryClient(t, usage+`echo "429 Too Many Requests" >&2; exit 1`)
+	res, err = c.Run(context.Background(), agent.Request{Prompt: "hi"}, nil)
+	if err == nil {
+		t.Fatal("expected the run to fail")
+	}
+	if want := (agent.Usage{Input: 24, Output: 6, Total: 30}); res.Usage != want {
+		t.Fatalf("expected usage of the failed attempts %+v, got %+v", want, res.Usage)
+	}
+}
@@ -0,0 +1,103 @@
+package codex
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"enoch/internal/agent"
+)
+
+// usageWatcher extracts token usage from output lines: JSON events (codex
+// exec --json) carrying a usage object, or lines matching pattern, whose
+// named groups input, output and total are read. The last match wins.
+type usageWatcher struct {
+	pattern *regexp.Regexp
+	pending []byte
+	usage   agent.Usage
+}
+
+func (w *usageWatcher) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	for {
+		idx := bytes.IndexByte(w.pending, '\n')
+		if idx < 0 {
+			break
+		}
+		w.scan(string(w.pending[:idx]))
+		w.pending = w.pending[idx+1:]
+	}
+	if len(w.pending) > 64*1024 {
+		w.pending = w.pending[len(w.pending)-64*1024:]
+	}
+	return len(p), nil
+}
+
+func (w *usageWatcher) flush() {
+	if len(w.pending) > 0 {
+		w.scan(string(w.pending))
+		w.pending = nil
+	}
+}
+
+type jsonUsage struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+	TotalTokens  int64 `json:"total_tokens"`
+}
+
+func (w *usageWatcher) scan(line string) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "{") && strings.Contains(line, "_tokens") {
+		var event struct {
+			Usage *jsonUsage `json:"usage"`
+			Msg   *struct {
+				Type string `json:"type"`
+				jsonUsage
+			} `json:"msg"`
+		}
+		if json.Unmarshal([]byte(line), &event) == nil {
+			switch {
+			case event.Usage != nil:
+				w.set(*event.Usage)
+				return
+			case event.Msg != nil && event.Msg.Type == "token_count":
+				w.set(event.Msg.jsonUsage)
+				return
+			}
+		}
+	}
+	if w.pattern == nil {
+		return
+	}
+	match := w.pattern.FindStringSubmatch(line)
+	if match == nil {
+		return
+	}
+	var u jsonUsage
+	for i, name := range w.pattern.SubexpNames() {
+		value, err := strconv.ParseInt(strings.ReplaceAll(match[i], ",", ""), 10, 64)
+		if err != nil {
+			continue
+		}
+		switch name {
+		case "input":
+			u.InputTokens = value
+		case "output":
+			u.OutputTokens = value
+		case "total":
+			u.TotalTokens = value
+		}
+	}
+	w.set(u)
+}
+
+func (w *usageWatcher) set(u jsonUsage) {
+	total := u.TotalTokens
+	if total == 0 {
+		total = u.InputTokens + u.OutputTokens
+	}
+	w.usage = agent.Usage{Input: u.InputTokens, Output: u.OutputTokens, Total: total}
+}
@@ -0,0 +1,29 @@
+package codex
+
+import (
+	"regexp"
+	"testing"
+
+	"enoch/internal/agent"
+)
+
+func TestUsageWatcher(t *testing.T) {
+	pattern := regexp.MustCompile(`(?i)tokens used:?\s*(?P<total>[\d,]+)`)
+	cases := map[string]agent.Usage{
+		"working...\ntokens used: 1,234\n":                                                             {Total: 1234},
+		`{"type":"turn.completed","usage":{"input_tokens":120,"output_tokens":30}}` + "\n":             {Input: 120, Output: 30, Total: 150},
+		`{"id":"0","msg":{"type":"token_count","input_tokens":7,"output_tokens":3,"total_tokens":10}}`: {Input: 7, Output: 3, Total: 10},
+		"no usage here\n": {},
+	}
+	for output, want := range cases {
+		w := &usageWatcher{pattern: pattern}
+		// Split writes to make sure lines spanning chunks are handled.
+		mid := len(output) / 2
+		w.Write([]byte(output[:mid]))
+		w.Write([]byte(output[mid:]))
+		w.flush()
+		if w.usage != want {
+			t.Fatalf("output %q: expected %+v, got %+v", output, want, w.usage)
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package config
 
 import (
-	"fmt"
+	"net"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -11,9 +13,13 @@ import (
 type Config struct {
 	TelegramBotToken       string
 	TelegramAllowedChatID  string
+	TelegramAdminIDs       []int64
 	TelegramPollInterval   time.Duration
 	TelegramTypingInterval time.Duration
 	TelegramContextSize    int
+	TelegramRemindTodo     bool
+	TelegramEditPolicy     string
+	TelegramStatusCleanup  string
 	CodexCommand           string
 	CodexArgs              []string
 	CodexPromptMode        string
@@ -22,52 +28,168 @@ type Config struct {
 	CodexDisableCPR        bool
 	CodexUseTTY            bool
 	CodexProgressInterval  time.Duration
+	CodexKillGrace         time.Duration
+	CodexRetryAttempts     int
+	CodexRetryBackoff      time.Duration
+	CodexRetryMaxBackoff   time.Duration
+	CodexRetryOn           []string
+	CodexEnvAllow          []string
+	CodexEnvDeny           []string
+	CodexEnvChatAllow      []string
+	CodexHomeOverride      string
+	CodexUsagePattern      string
+	CodexOutputLimit       int
+	CodexOutputDir         string
+	CodexLogRetention      time.Duration
+	CodexAllowedModels     []string
+	CodexAllowedEfforts    []string
+	CodexAllowedSandboxes  []string
+	CodexAllowedApprovals  []string
+	CodexAllowedProfiles   []string
+	CodexAllowedExtraArgs  []string
 	LogLevel               string
 	LogFile                string
 	LogConsole             bool
 	LogColor               bool
 	LogTimeFormat          string
+	DataDir                string
+	MemoryRoot             string
+	UsageBudgetMode        string
+	UsageChatBudget        int
+	UsageUserBudget        int
+	UsagePriceInput        float64
+	UsagePriceOutput       float64
+	JobHistoryLimit        int
+	WorkspaceMode          string
+	WorkspaceRoot          string
+	WorkspaceRepo          string
+	WorkspaceSkillsDir     string
+	WorkspaceKeepJobs      bool
+	WorkspaceMaxAge        time.Duration
+	WorkspaceQuotaMB       int
+	DefaultBackend         string
+	Backends               []BackendConfig
+	// ConfigFile is the config file that was read, if any.
+	ConfigFile string
+	// ConfigWatch is how often the config files are checked for changes
+	// (0 disables; SIGHUP always reloads).
+	ConfigWatch time.Duration
+	// RedactPattern is masked in logs and replies along with the secrets.
+	RedactPattern string
+	// ShutdownGrace is how long a running job may finish after SIGINT or
+	// SIGTERM before it is canceled.
+	ShutdownGrace time.Duration
+	// HTTPAddr is the listen address of the health and admin server; empty
+	// disables it.
+	HTTPAddr string
+	// AdminToken authorizes the admin endpoints; empty disables them.
+	AdminToken string
 }
 
+// BackendConfig describes an additional agent backend. Type "cli" runs a
+// command like Codex does; type "http" calls an OpenAI-compatible endpoint.
+type BackendConfig struct {
+	Name         string
+	Type         string
+	Command      string
+	Args         []string
+	PromptMode   string
+	Timeout      time.Duration
+	URL          string
+	Model        string
+	APIKey       string
+	SystemPrompt string
+	Stream       bool
+	// Home is exported as CODEX_HOME for CLI backends.
+	Home string
+}
+
+// Load reads the configuration from the environment (including the dotenv
+// files) and the optional config file; environment variables take precedence
+// over the file.
 func Load() (Config, error) {
-	_ = LoadDotEnv(".env")
+	dotenvErr := LoadDotEnv(DotEnvFiles()...)
+
+	path, required := os.Getenv("ENOCH_CONFIG"), true
+	if path == "" {
+		path, required = DefaultConfigFile, false
+	}
+	file, err := LoadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) || required {
+			return Config{}, err
+		}
+		file = nil
+	}
+	src := newSource(file)
+	if errs, ok := dotenvErr.(Errors); ok {
+		*src.errs = append(*src.errs, errs...)
+	}
+	exportSecretFiles(src)
+	cfg := load(src)
+	if err := src.err(); err != nil {
+		return Config{}, err
+	}
+	if file != nil {
+		cfg.ConfigFile = path
+	}
+	return cfg, nil
+}
 
-	token := strings.TrimSpace(os.Getenv("TELEGRAM_BOT_TOKEN"))
+// load reads every setting, recording problems on src instead of stopping at
+// the first one.
+func load(src source) Config {
+	token := src.secret("TELEGRAM_BOT_TOKEN")
 	if token == "" {
-		return Config{}, fmt.Errorf("TELEGRAM_BOT_TOKEN is required")
+		src.failf("TELEGRAM_BOT_TOKEN (or TELEGRAM_BOT_TOKEN_FILE) is required")
 	}
 
-	allowedChat := strings.TrimSpace(os.Getenv("TELEGRAM_ALLOWED_CHAT_ID"))
+	allowedChat := strings.TrimSpace(src.get("TELEGRAM_ALLOWED_CHAT_ID"))
 
-	pollInterval := 2 * time.Second
-	pollRaw := strings.TrimSpace(os.Getenv("TELEGRAM_POLL_INTERVAL"))
-	if pollRaw != "" {
-		seconds, err := strconv.ParseFloat(pollRaw, 64)
-		if err == nil && seconds > 0 {
-			pollInterval = time.Duration(seconds * float64(time.Second))
+	var adminIDs []int64
+	for _, raw := range src.parseList("TELEGRAM_ADMIN_IDS", nil) {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			src.failf("TELEGRAM_ADMIN_IDS: %q is not a user id", raw)
+			continue
 		}
+		adminIDs = append(adminIDs, id)
 	}
-	typingInterval, err := parseDurationSecondsEnv("TELEGRAM_TYPING_INTERVAL", 4*time.Second)
-	if err != nil {
-		return Config{}, err
+
+	pollInterval := src.parsePositiveDuration("TELEGRAM_POLL_INTERVAL", 2*time.Second)
+	typingInterval := src.parseDuration("TELEGRAM_TYPING_INTERVAL", 4*time.Second)
+
+	contextSize := src.parseInt("TELEGRAM_CONTEXT_SIZE", 0)
+
+	remindTodo := src.parseBool("TELEGRAM_REMIND_TODO", false)
+
+	statusCleanup := strings.ToLower(strings.TrimSpace(src.get("TELEGRAM_STATUS_CLEANUP")))
+	if statusCleanup == "" {
+		statusCleanup = "delete"
+	}
+	if statusCleanup != "delete" && statusCleanup != "collapse" {
+		src.failf("TELEGRAM_STATUS_CLEANUP must be delete or collapse, got %q", statusCleanup)
 	}
 
-	contextSize, err := parseIntEnv("TELEGRAM_CONTEXT_SIZE", 0)
-	if err != nil {
-		return Config{}, err
+	editPolicy := strings.ToLower(strings.TrimSpace(src.get("TELEGRAM_EDIT_POLICY")))
+	if editPolicy == "" {
+		editPolicy = "ask"
+	}
+	if editPolicy != "ask" && editPolicy != "auto" && editPolicy != "ignore" && editPolicy != "new" {
+		src.failf("TELEGRAM_EDIT_POLICY must be ask|auto|ignore|new, got %q", editPolicy)
 	}
 
-	codexCommand := strings.TrimSpace(os.Getenv("CODEX_COMMAND"))
+	codexCommand := strings.TrimSpace(src.get("CODEX_COMMAND"))
 	if codexCommand == "" {
 		codexCommand = "codex"
 	}
 
 	codexArgs := []string{}
-	codexArgsRaw := strings.TrimSpace(os.Getenv("CODEX_ARGS"))
+	codexArgsRaw := strings.TrimSpace(src.get("CODEX_ARGS"))
 	if codexArgsRaw != "" {
 		parsed, err := SplitArgs(codexArgsRaw)
 		if err != nil {
-			return Config{}, fmt.Errorf("invalid CODEX_ARGS: %w", err)
+			src.failf("invalid CODEX_ARGS: %w", err)
 		}
 		codexArgs = parsed
 	} else {
@@ -75,57 +197,159 @@ func Load() (Config, error) {
 		codexArgs = []string{"exec", "{prompt}"}
 	}
 
-	codexPromptMode := strings.ToLower(strings.TrimSpace(os.Getenv("CODEX_PROMPT_MODE")))
+	codexPromptMode := strings.ToLower(strings.TrimSpace(src.get("CODEX_PROMPT_MODE")))
 	if codexPromptMode == "" {
 		codexPromptMode = "arg"
 	}
 	if codexPromptMode != "stdin" && codexPromptMode != "arg" {
-		return Config{}, fmt.Errorf("CODEX_PROMPT_MODE must be stdin or arg")
+		src.failf("CODEX_PROMPT_MODE must be stdin or arg, got %q", codexPromptMode)
 	}
 
-	codexTimeout := 120 * time.Second
-	codexTimeoutRaw := strings.TrimSpace(os.Getenv("CODEX_TIMEOUT"))
-	if codexTimeoutRaw != "" {
-		seconds, err := strconv.ParseFloat(codexTimeoutRaw, 64)
-		if err == nil && seconds > 0 {
-			codexTimeout = time.Duration(seconds * float64(time.Second))
-		}
-	}
+	codexTimeout := src.parsePositiveDuration("CODEX_TIMEOUT", 120*time.Second)
 
-	codexWorkdir := strings.TrimSpace(os.Getenv("CODEX_WORKDIR"))
+	codexWorkdir := strings.TrimSpace(src.get("CODEX_WORKDIR"))
 	if codexWorkdir == "" {
 		codexWorkdir = "."
 	}
 
-	codexDisableCPR := parseBoolEnv("CODEX_DISABLE_CPR", true)
-	codexUseTTY := parseBoolEnv("CODEX_USE_TTY", false)
-	codexProgressInterval, err := parseDurationSecondsEnv("CODEX_PROGRESS_INTERVAL", 10*time.Second)
-	if err != nil {
-		return Config{}, err
+	codexDisableCPR := src.parseBool("CODEX_DISABLE_CPR", true)
+	codexUseTTY := src.parseBool("CODEX_USE_TTY", false)
+	codexProgressInterval := src.parseDuration("CODEX_PROGRESS_INTERVAL", 10*time.Second)
+
+	codexKillGrace := src.parseDuration("CODEX_KILL_GRACE", 5*time.Second)
+
+	retryAttempts := src.parseInt("CODEX_RETRY_ATTEMPTS", 3)
+	retryBackoff := src.parseDuration("CODEX_RETRY_BACKOFF", 2*time.Second)
+	retryMaxBackoff := src.parseDuration("CODEX_RETRY_MAX_BACKOFF", 30*time.Second)
+	retryOn := src.parseList("CODEX_RETRY_ON", []string{"rate_limit", "server", "network"})
+	for _, class := range retryOn {
+		switch class {
+		case "auth", "rate_limit", "server", "network", "timeout", "exit":
+		default:
+			src.failf("CODEX_RETRY_ON: unknown class %q (auth|rate_limit|server|network|timeout|exit)", class)
+		}
+	}
+
+	// Environment passthrough for subprocesses. An empty allow list
+	// inherits everything that is not denied.
+	envAllow := src.parseList("CODEX_ENV_ALLOW", nil)
+	envDeny := src.parseList("CODEX_ENV_DENY", nil)
+	envChatAllow := src.parseList("CODEX_ENV_CHAT_ALLOW", nil)
+
+	// The default matches the "tokens used: 1,234" summary of codex exec.
+	usagePattern, ok := src.lookup("CODEX_USAGE_REGEX")
+	if !ok {
+		usagePattern = `(?i)tokens used:?\s*(?P<total>[\d,]+)`
+	}
+	if _, err := regexp.Compile(usagePattern); err != nil {
+		src.failf("invalid CODEX_USAGE_REGEX: %w", err)
+	}
+
+	usageBudgetMode := strings.ToLower(strings.TrimSpace(src.get("USAGE_BUDGET_MODE")))
+	if usageBudgetMode == "" {
+		usageBudgetMode = "warn"
+	}
+	if usageBudgetMode != "warn" && usageBudgetMode != "block" {
+		src.failf("USAGE_BUDGET_MODE must be warn or block, got %q", usageBudgetMode)
+	}
+	usageChatBudget := src.parseInt("USAGE_BUDGET_CHAT_DAILY", 0)
+	usageUserBudget := src.parseInt("USAGE_BUDGET_USER_DAILY", 0)
+	usagePriceInput := src.parseFloat("USAGE_PRICE_INPUT", 0)
+	usagePriceOutput := src.parseFloat("USAGE_PRICE_OUTPUT", 0)
+
+	jobHistoryLimit := src.parseInt("JOB_HISTORY_LIMIT", 200)
+
+	// Allowlists for per-chat overrides. An empty list disables the setting.
+	allowedModels := src.parseList("CODEX_ALLOWED_MODELS", nil)
+	allowedEfforts := src.parseList("CODEX_ALLOWED_EFFORTS", []string{"low", "medium", "high"})
+	allowedSandboxes := src.parseList("CODEX_ALLOWED_SANDBOXES", []string{"read-only", "workspace-write"})
+	allowedApprovals := src.parseList("CODEX_ALLOWED_APPROVALS", nil)
+	allowedProfiles := src.parseList("CODEX_ALLOWED_PROFILES", nil)
+	allowedExtraArgs := src.parseList("CODEX_ALLOWED_EXTRA_ARGS", nil)
+	for _, flag := range allowedExtraArgs {
+		if !strings.HasPrefix(flag, "-") || strings.Contains(strings.TrimSuffix(flag, "="), "=") {
+			src.failf("CODEX_ALLOWED_EXTRA_ARGS entries must be flags, with a trailing = for flags that take a value, got %q", flag)
+		}
 	}
 
-	logLevel := strings.ToLower(strings.TrimSpace(os.Getenv("LOG_LEVEL")))
+	logLevel := strings.ToLower(strings.TrimSpace(src.get("LOG_LEVEL")))
 	if logLevel == "" {
 		logLevel = "info"
 	}
 	if logLevel != "debug" && logLevel != "info" && logLevel != "warn" && logLevel != "error" {
-		return Config{}, fmt.Errorf("LOG_LEVEL must be debug|info|warn|error")
+		src.failf("LOG_LEVEL must be debug|info|warn|error, got %q", logLevel)
 	}
 
-	logFile := strings.TrimSpace(os.Getenv("LOG_FILE"))
-	logConsole := parseBoolEnv("LOG_CONSOLE", true)
-	logColor := parseBoolEnv("LOG_COLOR", true)
-	logTimeFormat := strings.TrimSpace(os.Getenv("LOG_TIME_FORMAT"))
+	logFile := strings.TrimSpace(src.get("LOG_FILE"))
+	logConsole := src.parseBool("LOG_CONSOLE", true)
+	logColor := src.parseBool("LOG_COLOR", true)
+	logTimeFormat := strings.TrimSpace(src.get("LOG_TIME_FORMAT"))
 	if logTimeFormat == "" {
 		logTimeFormat = "2006-01-02 15:04:05"
 	}
 
+	configWatch := src.parseDuration("ENOCH_CONFIG_WATCH", 5*time.Second)
+	shutdownGrace := src.parseDuration("ENOCH_SHUTDOWN_GRACE", 60*time.Second)
+	httpAddr := strings.TrimSpace(src.get("ENOCH_HTTP_ADDR"))
+	if httpAddr != "" {
+		if _, _, err := net.SplitHostPort(httpAddr); err != nil {
+			src.failf("invalid ENOCH_HTTP_ADDR %q: %v", httpAddr, err)
+		}
+	}
+	redactPattern := strings.TrimSpace(src.get("ENOCH_REDACT_PATTERN"))
+	if _, err := regexp.Compile(redactPattern); err != nil {
+		src.failf("invalid ENOCH_REDACT_PATTERN: %w", err)
+	}
+
+	dataDir := strings.TrimSpace(src.get("ENOCH_DATA_DIR"))
+	if dataDir == "" {
+		dataDir = "data"
+	}
+
+	outputLimitKB := src.parseInt("CODEX_OUTPUT_LIMIT_KB", 512)
+	outputDir := strings.TrimSpace(src.get("CODEX_OUTPUT_DIR"))
+	if outputDir == "" {
+		outputDir = filepath.Join(dataDir, "joblogs")
+	}
+	logRetention := src.parseDuration("CODEX_LOG_RETENTION", 7*24*time.Hour)
+
+	workspaceMode := strings.ToLower(strings.TrimSpace(src.get("WORKSPACE_MODE")))
+	if workspaceMode == "" {
+		workspaceMode = "shared"
+	}
+	if workspaceMode != "shared" && workspaceMode != "chat" && workspaceMode != "job" {
+		src.failf("WORKSPACE_MODE must be shared|chat|job, got %q", workspaceMode)
+	}
+	workspaceRoot := strings.TrimSpace(src.get("WORKSPACE_ROOT"))
+	if workspaceRoot == "" {
+		workspaceRoot = filepath.Join(dataDir, "workspaces")
+	}
+	workspaceSkills := strings.TrimSpace(src.get("WORKSPACE_SKILLS_DIR"))
+	if workspaceSkills == "" {
+		workspaceSkills = filepath.Join(codexWorkdir, "skills")
+	}
+	workspaceMaxAge := src.parseDuration("WORKSPACE_MAX_AGE", 0)
+	workspaceQuota := src.parseInt("WORKSPACE_QUOTA_MB", 0)
+
+	backends := loadBackends(src, codexTimeout)
+	defaultBackend := strings.TrimSpace(src.get("BACKEND_DEFAULT"))
+	if defaultBackend == "" {
+		defaultBackend = "codex"
+	}
+	if !hasBackend(backends, defaultBackend) {
+		src.failf("BACKEND_DEFAULT %q is not a configured backend", defaultBackend)
+	}
+
 	return Config{
 		TelegramBotToken:       token,
 		TelegramAllowedChatID:  allowedChat,
+		TelegramAdminIDs:       adminIDs,
 		TelegramPollInterval:   pollInterval,
 		TelegramTypingInterval: typingInterval,
 		TelegramContextSize:    contextSize,
+		TelegramRemindTodo:     remindTodo,
+		TelegramEditPolicy:     editPolicy,
+		TelegramStatusCleanup:  statusCleanup,
 		CodexCommand:           codexCommand,
 		CodexArgs:              codexArgs,
 		CodexPromptMode:        codexPromptMode,
@@ -134,56 +358,235 @@ func Load() (Config, error) {
 		CodexDisableCPR:        codexDisableCPR,
 		CodexUseTTY:            codexUseTTY,
 		CodexProgressInterval:  codexProgressInterval,
+		CodexKillGrace:         codexKillGrace,
+		CodexRetryAttempts:     retryAttempts,
+		CodexRetryBackoff:      retryBackoff,
+		CodexRetryMaxBackoff:   retryMaxBackoff,
+		CodexRetryOn:           retryOn,
+		CodexEnvAllow:          envAllow,
+		CodexEnvDeny:           envDeny,
+		CodexEnvChatAllow:      envChatAllow,
+		CodexHomeOverride:      strings.TrimSpace(src.get("CODEX_HOME_OVERRIDE")),
+		CodexUsagePattern:      usagePattern,
+		CodexOutputLimit:       outputLimitKB * 1024,
+		CodexOutputDir:         outputDir,
+		CodexLogRetention:      logRetention,
+		CodexAllowedModels:     allowedModels,
+		CodexAllowedEfforts:    allowedEfforts,
+		CodexAllowedSandboxes:  allowedSandboxes,
+		CodexAllowedApprovals:  allowedApprovals,
+		CodexAllowedProfiles:   allowedProfiles,
+		CodexAllowedExtraArgs:  allowedExtraArgs,
 		LogLevel:               logLevel,
 		LogFile:                logFile,
 		LogConsole:             logConsole,
 		LogColor:               logColor,
 		LogTimeFormat:          logTimeFormat,
-	}, nil
+		DataDir:                dataDir,
+		MemoryRoot:             strings.TrimSpace(src.get("MEMORY_ROOT")),
+		UsageBudgetMode:        usageBudgetMode,
+		UsageChatBudget:        usageChatBudget,
+		UsageUserBudget:        usageUserBudget,
+		UsagePriceInput:        usagePriceInput,
+		UsagePriceOutput:       usagePriceOutput,
+		JobHistoryLimit:        jobHistoryLimit,
+		WorkspaceMode:          workspaceMode,
+		WorkspaceRoot:          workspaceRoot,
+		WorkspaceRepo:          strings.TrimSpace(src.get("WORKSPACE_REPO")),
+		WorkspaceSkillsDir:     workspaceSkills,
+		WorkspaceKeepJobs:      src.parseBool("WORKSPACE_KEEP_JOBS", false),
+		WorkspaceMaxAge:        workspaceMaxAge,
+		WorkspaceQuotaMB:       workspaceQuota,
+		DefaultBackend:         defaultBackend,
+		Backends:               backends,
+		ConfigWatch:            configWatch,
+		RedactPattern:          redactPattern,
+		ShutdownGrace:          shutdownGrace,
+		HTTPAddr:               httpAddr,
+		AdminToken:             src.secret("ENOCH_ADMIN_TOKEN"),
+	}
 }
 
-func parseBoolEnv(key string, defaultValue bool) bool {
-	value := strings.TrimSpace(os.Getenv(key))
+// loadBackends reads the optional generic CLI (BACKEND_CLI_*) and HTTP
+// (BACKEND_HTTP_*) backends. Each is enabled by setting its command or URL.
+func loadBackends(src source, defaultTimeout time.Duration) []BackendConfig {
+	backends := []BackendConfig{}
+
+	if command := strings.TrimSpace(src.get("BACKEND_CLI_COMMAND")); command != "" {
+		args := []string{}
+		if raw := strings.TrimSpace(src.get("BACKEND_CLI_ARGS")); raw != "" {
+			parsed, err := SplitArgs(raw)
+			if err != nil {
+				src.failf("invalid BACKEND_CLI_ARGS: %w", err)
+			}
+			args = parsed
+		}
+		mode := strings.ToLower(strings.TrimSpace(src.get("BACKEND_CLI_PROMPT_MODE")))
+		if mode == "" {
+			mode = "arg"
+		}
+		if mode != "arg" && mode != "stdin" && mode != "file" {
+			src.failf("BACKEND_CLI_PROMPT_MODE must be arg|stdin|file, got %q", mode)
+		}
+		timeout := src.parseDuration("BACKEND_CLI_TIMEOUT", defaultTimeout)
+		if timeout <= 0 {
+			timeout = defaultTimeout
+		}
+		name := strings.TrimSpace(src.get("BACKEND_CLI_NAME"))
+		if name == "" {
+			name = "cli"
+		}
+		backends = append(backends, BackendConfig{
+			Name:       name,
+			Type:       "cli",
+			Home:       strings.TrimSpace(src.get("BACKEND_CLI_CODEX_HOME")),
+			Command:    command,
+			Args:       args,
+			PromptMode: mode,
+			Timeout:    timeout,
+		})
+	}
+
+	if url := strings.TrimSpace(src.get("BACKEND_HTTP_URL")); url != "" {
+		timeout := src.parseDuration("BACKEND_HTTP_TIMEOUT", defaultTimeout)
+		if timeout <= 0 {
+			timeout = defaultTimeout
+		}
+		name := strings.TrimSpace(src.get("BACKEND_HTTP_NAME"))
+		if name == "" {
+			name = "http"
+		}
+		backends = append(backends, BackendConfig{
+			Name:         name,
+			Type:         "http",
+			URL:          url,
+			Model:        strings.TrimSpace(src.get("BACKEND_HTTP_MODEL")),
+			APIKey:       src.secret("BACKEND_HTTP_API_KEY"),
+			SystemPrompt: strings.TrimSpace(src.get("BACKEND_HTTP_SYSTEM_PROMPT")),
+			Stream:       src.parseBool("BACKEND_HTTP_STREAM", true),
+			Timeout:      timeout,
+		})
+	}
+
+	seen := map[string]bool{"codex": true}
+	for _, backend := range backends {
+		if seen[backend.Name] {
+			src.failf("duplicate backend name %q", backend.Name)
+		}
+		seen[backend.Name] = true
+	}
+	return backends
+}
+
+func hasBackend(backends []BackendConfig, name string) bool {
+	if name == "codex" {
+		return true
+	}
+	for _, backend := range backends {
+		if backend.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (src source) parseBool(key string, defaultValue bool) bool {
+	value := strings.TrimSpace(src.get(key))
 	if value == "" {
 		return defaultValue
 	}
-	value = strings.ToLower(value)
-	switch value {
+	switch strings.ToLower(value) {
 	case "1", "true", "yes", "y", "on":
 		return true
 	case "0", "false", "no", "n", "off":
 		return false
 	default:
+		src.failf("%s must be a boolean (true|false), got %q", key, value)
+		return defaultValue
+	}
+}
+
+// parseList splits a comma-separated value. An unset key yields the
+// default; a key set to an empty value yields an empty list.
+func (src source) parseList(key string, defaultValue []string) []string {
+	raw, ok := src.lookup(key)
+	if !ok {
 		return defaultValue
 	}
+	values := []string{}
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
 }
 
-func parseDurationSecondsEnv(key string, defaultValue time.Duration) (time.Duration, error) {
-	value := strings.TrimSpace(os.Getenv(key))
+// parseDuration accepts a number of seconds ("90", "1.5") or a Go duration
+// ("90s", "2m"). Zero disables the setting; negative values are an error.
+func (src source) parseDuration(key string, defaultValue time.Duration) time.Duration {
+	value := strings.TrimSpace(src.get(key))
 	if value == "" {
-		return defaultValue, nil
+		return defaultValue
+	}
+	var parsed time.Duration
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		parsed = time.Duration(seconds * float64(time.Second))
+	} else if d, err := time.ParseDuration(value); err == nil {
+		parsed = d
+	} else {
+		src.failf("%s must be a number of seconds or a duration like 90s or 2m, got %q", key, value)
+		return defaultValue
 	}
-	seconds, err := strconv.ParseFloat(value, 64)
+	if parsed < 0 {
+		src.failf("%s must be >= 0, got %q", key, value)
+		return defaultValue
+	}
+	return parsed
+}
+
+// parsePositiveDuration is parseDuration for settings that cannot be
+// disabled.
+func (src source) parsePositiveDuration(key string, defaultValue time.Duration) time.Duration {
+	value := strings.TrimSpace(src.get(key))
+	parsed := src.parseDuration(key, defaultValue)
+	if value != "" && parsed == 0 {
+		src.failf("%s must be > 0, got %q", key, value)
+		return defaultValue
+	}
+	return parsed
+}
+
+func (src source) parseFloat(key string, defaultValue float64) float64 {
+	value := strings.TrimSpace(src.get(key))
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
 	if err != nil {
-		return 0, fmt.Errorf("%s must be a number (seconds)", key)
+		src.failf("%s must be a number, got %q", key, value)
+		return defaultValue
 	}
-	if seconds <= 0 {
-		return 0, nil
+	if parsed < 0 {
+		src.failf("%s must be >= 0, got %q", key, value)
+		return defaultValue
 	}
-	return time.Duration(seconds * float64(time.Second)), nil
+	return parsed
 }
 
-func parseIntEnv(key string, defaultValue int) (int, error) {
-	value := strings.TrimSpace(os.Getenv(key))
+func (src source) parseInt(key string, defaultValue int) int {
+	value := strings.TrimSpace(src.get(key))
 	if value == "" {
-		return defaultValue, nil
+		return defaultValue
 	}
 	parsed, err := strconv.Atoi(value)
 	if err != nil {
-		return 0, fmt.Errorf("%s must be an integer", key)
+		src.failf("%s must be an integer, got %q", key, value)
+		return defaultValue
 	}
 	if parsed < 0 {
-		return 0, fmt.Errorf("%s must be >= 0", key)
+		src.failf("%s must be >= 0, got %q", key, value)
+		return defaultValue
 	}
-	return parsed, nil
+	return parsed
 }
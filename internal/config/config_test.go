@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -69,3 +70,57 @@ func setTestEnv(values map[string]string) func() {
 		}
 	}
 }
+
+func TestLoadConfigBackends(t *testing.T) {
+	resetEnv := setTestEnv(map[string]string{
+		"TELEGRAM_BOT_TOKEN":      "token",
+		"BACKEND_CLI_COMMAND":     "aider",
+		"BACKEND_CLI_ARGS":        "--message {prompt}",
+		"BACKEND_CLI_PROMPT_MODE": "arg",
+		"BACKEND_HTTP_URL":        "http://localhost:8080/v1",
+		"BACKEND_HTTP_NAME":       "local",
+		"BACKEND_DEFAULT":         "local",
+	})
+	defer resetEnv()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.DefaultBackend != "local" {
+		t.Fatalf("DefaultBackend mismatch: %s", cfg.DefaultBackend)
+	}
+	if len(cfg.Backends) != 2 {
+		t.Fatalf("expected 2 backends, got %d", len(cfg.Backends))
+	}
+	if cfg.Backends[0].Name != "cli" || cfg.Backends[0].Command != "aider" {
+		t.Fatalf("cli backend unexpected: %#v", cfg.Backends[0])
+	}
+	if cfg.Backends[1].Name != "local" || !cfg.Backends[1].Stream {
+		t.Fatalf("http backend unexpected: %#v", cfg.Backends[1])
+	}
+}
+
+func TestLoadConfigRejectsUnknownDefaultBackend(t *testing.T) {
+	resetEnv := setTestEnv(map[string]string{
+		"TELEGRAM_BOT_TOKEN": "token",
+		"BACKEND_DEFAULT":    "missing",
+	})
+	defer resetEnv()
+
+	if _, err := Load(); err == nil {
+		t.Fatalf("expected error for unknown BACKEND_DEFAULT")
+	}
+}
+
+func TestLoadConfigRejectsMalformedExtraArgs(t *testing.T) {
+	resetEnv := setTestEnv(map[string]string{
+		"TELEGRAM_BOT_TOKEN":       "token",
+		"CODEX_ALLOWED_EXTRA_ARGS": "--search,--color=never",
+	})
+	defer resetEnv()
+
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "CODEX_ALLOWED_EXTRA_ARGS") {
+		t.Fatalf("expected error for a flag with a fixed value, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"enoch/internal/redact"
+)
+
+// Setting is one effective setting, as shown by `enoch config check`.
+type Setting struct {
+	Key   string
+	Value string
+}
+
+// Describe lists the effective settings in Config order with secrets
+// redacted. Backends are expanded into their BACKEND_* settings.
+func Describe(cfg Config) []Setting {
+	var out []Setting
+	v := reflect.ValueOf(cfg)
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		switch name {
+		case "Backends":
+			for _, backend := range cfg.Backends {
+				out = append(out, describeBackend(backend)...)
+			}
+			continue
+		case "CodexOutputLimit":
+			out = append(out, Setting{Key: fieldKeys[name], Value: strconv.Itoa(cfg.CodexOutputLimit / 1024)})
+			continue
+		}
+		out = append(out, setting(fieldKeys[name], v.Field(i).Interface()))
+	}
+	return out
+}
+
+func describeBackend(b BackendConfig) []Setting {
+	prefix := "BACKEND_" + strings.ToUpper(b.Type) + "_"
+	out := []Setting{setting(prefix+"NAME", b.Name)}
+	if b.Type == "cli" {
+		return append(out,
+			setting(prefix+"COMMAND", b.Command),
+			setting(prefix+"ARGS", b.Args),
+			setting(prefix+"PROMPT_MODE", b.PromptMode),
+			setting(prefix+"TIMEOUT", b.Timeout),
+			setting(prefix+"CODEX_HOME", b.Home),
+		)
+	}
+	return append(out,
+		setting(prefix+"URL", b.URL),
+		setting(prefix+"MODEL", b.Model),
+		setting(prefix+"API_KEY", b.APIKey),
+		setting(prefix+"SYSTEM_PROMPT", b.SystemPrompt),
+		setting(prefix+"STREAM", b.Stream),
+		setting(prefix+"TIMEOUT", b.Timeout),
+	)
+}
+
+func setting(key string, value interface{}) Setting {
+	var text string
+	switch v := value.(type) {
+	case []string:
+		if argKeys[key] {
+			quoted := make([]string, len(v))
+			for i, arg := range v {
+				quoted[i] = quoteArg(arg)
+			}
+			text = strings.Join(quoted, " ")
+		} else {
+			text = strings.Join(v, ",")
+		}
+	case []int64:
+		ids := make([]string, len(v))
+		for i, id := range v {
+			ids[i] = strconv.FormatInt(id, 10)
+		}
+		text = strings.Join(ids, ",")
+	case time.Duration:
+		text = v.String()
+	default:
+		text = fmt.Sprint(v)
+	}
+	if text != "" && redact.IsSensitive(key) {
+		text = redact.Mask
+	}
+	return Setting{Key: key, Value: text}
+}
@@ -0,0 +1,115 @@
+package config
+
+import "reflect"
+
+// fieldKeys maps Config fields to the settings that set them.
+var fieldKeys = map[string]string{
+	"TelegramBotToken":       "TELEGRAM_BOT_TOKEN",
+	"TelegramAllowedChatID":  "TELEGRAM_ALLOWED_CHAT_ID",
+	"TelegramAdminIDs":       "TELEGRAM_ADMIN_IDS",
+	"TelegramPollInterval":   "TELEGRAM_POLL_INTERVAL",
+	"TelegramTypingInterval": "TELEGRAM_TYPING_INTERVAL",
+	"TelegramContextSize":    "TELEGRAM_CONTEXT_SIZE",
+	"TelegramRemindTodo":     "TELEGRAM_REMIND_TODO",
+	"TelegramEditPolicy":     "TELEGRAM_EDIT_POLICY",
+	"TelegramStatusCleanup":  "TELEGRAM_STATUS_CLEANUP",
+	"CodexCommand":           "CODEX_COMMAND",
+	"CodexArgs":              "CODEX_ARGS",
+	"CodexPromptMode":        "CODEX_PROMPT_MODE",
+	"CodexTimeout":           "CODEX_TIMEOUT",
+	"CodexWorkdir":           "CODEX_WORKDIR",
+	"CodexDisableCPR":        "CODEX_DISABLE_CPR",
+	"CodexUseTTY":            "CODEX_USE_TTY",
+	"CodexProgressInterval":  "CODEX_PROGRESS_INTERVAL",
+	"CodexKillGrace":         "CODEX_KILL_GRACE",
+	"CodexRetryAttempts":     "CODEX_RETRY_ATTEMPTS",
+	"CodexRetryBackoff":      "CODEX_RETRY_BACKOFF",
+	"CodexRetryMaxBackoff":   "CODEX_RETRY_MAX_BACKOFF",
+	"CodexRetryOn":           "CODEX_RETRY_ON",
+	"CodexEnvAllow":          "CODEX_ENV_ALLOW",
+	"CodexEnvDeny":           "CODEX_ENV_DENY",
+	"CodexEnvChatAllow":      "CODEX_ENV_CHAT_ALLOW",
+	"CodexHomeOverride":      "CODEX_HOME_OVERRIDE",
+	"CodexUsagePattern":      "CODEX_USAGE_REGEX",
+	"CodexOutputLimit":       "CODEX_OUTPUT_LIMIT_KB",
+	"CodexOutputDir":         "CODEX_OUTPUT_DIR",
+	"CodexLogRetention":      "CODEX_LOG_RETENTION",
+	"CodexAllowedModels":     "CODEX_ALLOWED_MODELS",
+	"CodexAllowedEfforts":    "CODEX_ALLOWED_EFFORTS",
+	"CodexAllowedSandboxes":  "CODEX_ALLOWED_SANDBOXES",
+	"CodexAllowedApprovals":  "CODEX_ALLOWED_APPROVALS",
+	"CodexAllowedProfiles":   "CODEX_ALLOWED_PROFILES",
+	"CodexAllowedExtraArgs":  "CODEX_ALLOWED_EXTRA_ARGS",
+	"LogLevel":               "LOG_LEVEL",
+	"LogFile":                "LOG_FILE",
+	"LogConsole":             "LOG_CONSOLE",
+	"LogColor":               "LOG_COLOR",
+	"LogTimeFormat":          "LOG_TIME_FORMAT",
+	"DataDir":                "ENOCH_DATA_DIR",
+	"MemoryRoot":             "MEMORY_ROOT",
+	"UsageBudgetMode":        "USAGE_BUDGET_MODE",
+	"UsageChatBudget":        "USAGE_BUDGET_CHAT_DAILY",
+	"UsageUserBudget":        "USAGE_BUDGET_USER_DAILY",
+	"UsagePriceInput":        "USAGE_PRICE_INPUT",
+	"UsagePriceOutput":       "USAGE_PRICE_OUTPUT",
+	"JobHistoryLimit":        "JOB_HISTORY_LIMIT",
+	"WorkspaceMode":          "WORKSPACE_MODE",
+	"WorkspaceRoot":          "WORKSPACE_ROOT",
+	"WorkspaceRepo":          "WORKSPACE_REPO",
+	"WorkspaceSkillsDir":     "WORKSPACE_SKILLS_DIR",
+	"WorkspaceKeepJobs":      "WORKSPACE_KEEP_JOBS",
+	"WorkspaceMaxAge":        "WORKSPACE_MAX_AGE",
+	"WorkspaceQuotaMB":       "WORKSPACE_QUOTA_MB",
+	"DefaultBackend":         "BACKEND_DEFAULT",
+	"Backends":               "BACKEND_*",
+	"ConfigFile":             "ENOCH_CONFIG",
+	"ConfigWatch":            "ENOCH_CONFIG_WATCH",
+	"RedactPattern":          "ENOCH_REDACT_PATTERN",
+	"ShutdownGrace":          "ENOCH_SHUTDOWN_GRACE",
+	"HTTPAddr":               "ENOCH_HTTP_ADDR",
+	"AdminToken":             "ENOCH_ADMIN_TOKEN",
+}
+
+// restartKeys cannot change while the bot runs: they are baked into the
+// Telegram client, the stores under the data directory, the backend
+// registry, the workspace manager or the HTTP listener.
+var restartKeys = map[string]bool{
+	"TELEGRAM_BOT_TOKEN":   true,
+	"ENOCH_DATA_DIR":       true,
+	"ENOCH_HTTP_ADDR":      true,
+	"MEMORY_ROOT":          true,
+	"JOB_HISTORY_LIMIT":    true,
+	"WORKSPACE_MODE":       true,
+	"WORKSPACE_ROOT":       true,
+	"WORKSPACE_REPO":       true,
+	"WORKSPACE_SKILLS_DIR": true,
+	"WORKSPACE_KEEP_JOBS":  true,
+	"WORKSPACE_MAX_AGE":    true,
+	"WORKSPACE_QUOTA_MB":   true,
+	"BACKEND_DEFAULT":      true,
+	"BACKEND_*":            true,
+}
+
+// Diff returns the settings whose effective values differ.
+func Diff(old, new Config) []string {
+	var changed []string
+	ov, nv := reflect.ValueOf(old), reflect.ValueOf(new)
+	for i := 0; i < ov.NumField(); i++ {
+		if !reflect.DeepEqual(ov.Field(i).Interface(), nv.Field(i).Interface()) {
+			changed = append(changed, fieldKeys[ov.Type().Field(i).Name])
+		}
+	}
+	return changed
+}
+
+// RestartRequired returns the changed settings that only take effect after a
+// restart.
+func RestartRequired(changed []string) []string {
+	var out []string
+	for _, key := range changed {
+		if restartKeys[key] {
+			out = append(out, key)
+		}
+	}
+	return out
+}
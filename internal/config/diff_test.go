@@ -0,0 +1,70 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffReportsChangedFields(t *testing.T) {
+	old := Config{TelegramBotToken: "a", CodexArgs: []string{"--x"}, CodexTimeout: time.Minute}
+	next := old
+	next.CodexArgs = []string{"--y"}
+	next.CodexTimeout = 2 * time.Minute
+
+	changed := Diff(old, next)
+	if len(changed) != 2 || changed[0] != "CODEX_ARGS" || changed[1] != "CODEX_TIMEOUT" {
+		t.Fatalf("unexpected diff: %v", changed)
+	}
+	if blocked := RestartRequired(changed); len(blocked) != 0 {
+		t.Fatalf("expected no restart, got %v", blocked)
+	}
+
+	next.TelegramBotToken = "b"
+	blocked := RestartRequired(Diff(old, next))
+	if len(blocked) != 1 || blocked[0] != "TELEGRAM_BOT_TOKEN" {
+		t.Fatalf("expected token to need a restart, got %v", blocked)
+	}
+}
+
+func TestLoadRereadsDotEnv(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	resetEnv := setTestEnv(map[string]string{"TELEGRAM_BOT_TOKEN": "token", "ENOCH_CONFIG": ""})
+	defer resetEnv()
+	defer os.Unsetenv("CODEX_COMMAND")
+
+	envPath := filepath.Join(dir, ".env")
+	if err := os.WriteFile(envPath, []byte("CODEX_COMMAND=first\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load()
+	if err != nil || cfg.CodexCommand != "first" {
+		t.Fatalf("unexpected first load: %q %v", cfg.CodexCommand, err)
+	}
+	if err := os.WriteFile(envPath, []byte("CODEX_COMMAND=second\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = Load()
+	if err != nil || cfg.CodexCommand != "second" {
+		t.Fatalf("expected reload to pick up .env edit, got %q %v", cfg.CodexCommand, err)
+	}
+}
+
+func TestEveryFieldHasAKey(t *testing.T) {
+	typ := reflect.TypeOf(Config{})
+	for i := 0; i < typ.NumField(); i++ {
+		if fieldKeys[typ.Field(i).Name] == "" {
+			t.Fatalf("field %s has no entry in fieldKeys", typ.Field(i).Name)
+		}
+	}
+}
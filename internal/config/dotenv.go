@@ -1,47 +1,277 @@
 package config
 
 import (
-	"bufio"
+	"fmt"
 	"os"
+	"regexp"
 	"strings"
+	"sync"
 )
 
-// LoadDotEnv loads simple KEY=VALUE pairs from the provided file.
-// Existing environment variables are not overridden.
-func LoadDotEnv(path string) error {
-	file, err := os.Open(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
+// EnvFile is an extra dotenv file, set by --env-file. It takes precedence
+// over .env.local, which takes precedence over .env. Real environment
+// variables always win.
+var EnvFile string
+
+// dotenvSet remembers the values LoadDotEnv put into the environment and
+// secretSet those exportSecretFiles put there, so a reload sees edits to the
+// files instead of the values it set last time.
+var (
+	dotenvMu  sync.Mutex
+	dotenvSet = map[string]string{}
+	secretSet = map[string]string{}
+)
+
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// DotEnvFiles lists the dotenv files Load reads, lowest precedence first.
+func DotEnvFiles() []string {
+	files := []string{".env", ".env.local"}
+	if EnvFile != "" {
+		files = append(files, EnvFile)
+	}
+	return files
+}
+
+// forgetDotEnv removes the variables set by earlier LoadDotEnv and
+// exportSecretFiles calls unless something else has changed them since.
+func forgetDotEnv() {
+	dotenvMu.Lock()
+	defer dotenvMu.Unlock()
+	applyEnv(&dotenvSet, map[string]string{})
+	applyEnv(&secretSet, map[string]string{})
+}
+
+// owned reports whether key still holds the value recorded for it in set.
+// The caller holds dotenvMu.
+func owned(set map[string]string, key string) bool {
+	value, ok := set[key]
+	if !ok {
+		return false
+	}
+	current, exists := os.LookupEnv(key)
+	return exists && current == value
+}
+
+// applyEnv moves the environment from the values recorded in set to next.
+// Only changed keys are written and only dropped keys are unset, so a
+// reload never leaves an unchanged variable briefly missing for a job that
+// builds its environment meanwhile. The caller holds dotenvMu.
+func applyEnv(set *map[string]string, next map[string]string) {
+	for key, value := range next {
+		if current, ok := os.LookupEnv(key); !ok || current != value {
+			_ = os.Setenv(key, value)
+		}
+	}
+	for key := range *set {
+		if _, keep := next[key]; !keep && owned(*set, key) {
+			_ = os.Unsetenv(key)
 		}
-		return err
 	}
-	defer file.Close()
+	*set = next
+}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
+// realEnv looks key up in the environment, ignoring the values enoch set
+// there itself from the dotenv and secret files.
+func realEnv(key string) (string, bool) {
+	dotenvMu.Lock()
+	defer dotenvMu.Unlock()
+	if owned(dotenvSet, key) || owned(secretSet, key) {
+		return "", false
+	}
+	return os.LookupEnv(key)
+}
+
+// LoadDotEnv loads the given dotenv files into the environment. Later files
+// override earlier ones; existing environment variables are not overridden.
+// Missing files are skipped, except EnvFile which must exist. Malformed
+// entries are reported with their file and line.
+func LoadDotEnv(paths ...string) error {
+	merged := map[string]string{}
+	var order []string
+	var errs Errors
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) && path != EnvFile {
+				continue
+			}
+			errs = append(errs, err)
 			continue
 		}
+		entries, fileErrs := ParseDotEnv(path, string(data), merged)
+		errs = append(errs, fileErrs...)
+		for _, entry := range entries {
+			if !contains(order, entry.Key) {
+				order = append(order, entry.Key)
+			}
+		}
+	}
+
+	dotenvMu.Lock()
+	next := map[string]string{}
+	for _, key := range order {
+		if _, exists := os.LookupEnv(key); !exists || owned(dotenvSet, key) || owned(secretSet, key) {
+			next[key] = merged[key]
+		}
+	}
+	applyEnv(&dotenvSet, next)
+	dotenvMu.Unlock()
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// EnvEntry is one KEY=VALUE assignment from a dotenv file.
+type EnvEntry struct {
+	Key   string
+	Value string
+	Line  int
+}
+
+// ParseDotEnv parses dotenv content. It understands `export KEY=...`, full
+// line and inline comments, single quotes (literal), double quotes (with
+// \n, \t, \r, \", \\ and \$ escapes) spanning several lines, and ${VAR}
+// references. vars holds the values defined so far and receives the parsed
+// entries; references resolve against the environment first, then vars.
+// Values enoch itself set from the dotenv files do not count as the
+// environment, so a reload resolves them afresh.
+func ParseDotEnv(path, content string, vars map[string]string) ([]EnvEntry, Errors) {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	resolve := func(name string) string {
+		if value, ok := realEnv(name); ok {
+			return value
+		}
+		return vars[name]
+	}
+
+	var entries []EnvEntry
+	var errs Errors
+	fail := func(line int, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s:%d: %s", path, line, fmt.Sprintf(format, args...)))
+	}
+
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		// Only leading space is trimmed: a quoted value may end the line.
+		line := strings.TrimLeft(lines[i], " \t")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "export ") || strings.HasPrefix(line, "export\t") {
+			line = strings.TrimLeft(line[len("export"):], " \t")
+		}
 		idx := strings.Index(line, "=")
 		if idx == -1 {
+			fail(lineNo, "expected KEY=VALUE, got %q", strings.TrimSpace(line))
 			continue
 		}
 		key := strings.TrimSpace(line[:idx])
-		value := strings.TrimSpace(line[idx+1:])
-		if key == "" {
+		if !envKeyPattern.MatchString(key) {
+			fail(lineNo, "invalid variable name %q", key)
 			continue
 		}
-		if len(value) >= 2 {
-			if (value[0] == '"' && value[len(value)-1] == '"') || (value[0] == '\'' && value[len(value)-1] == '\'') {
-				value = value[1 : len(value)-1]
+		rest := strings.TrimLeft(line[idx+1:], " \t")
+
+		var value string
+		switch {
+		case strings.HasPrefix(rest, `"`) || strings.HasPrefix(rest, "'"):
+			quote := rest[0]
+			body := rest[1:]
+			end := closingQuote(body, quote)
+			// Quoted values may continue on the following lines.
+			for end == -1 && i+1 < len(lines) {
+				i++
+				body += "\n" + lines[i]
+				end = closingQuote(body, quote)
+			}
+			if end == -1 {
+				fail(lineNo, "unterminated %c quote in %s", quote, key)
+				continue
+			}
+			trailing := strings.TrimSpace(body[end+1:])
+			if trailing != "" && !strings.HasPrefix(trailing, "#") {
+				fail(i+1, "unexpected text after closing quote of %s: %q", key, trailing)
+				continue
 			}
+			if quote == '\'' {
+				value = body[:end]
+			} else {
+				value = expand(body[:end], true, resolve)
+			}
+		default:
+			// An unquoted value ends at a " #" comment.
+			for j := 0; j < len(rest); j++ {
+				if rest[j] == '#' && (j == 0 || rest[j-1] == ' ' || rest[j-1] == '\t') {
+					rest = rest[:j]
+					break
+				}
+			}
+			value = expand(strings.TrimSpace(rest), false, resolve)
 		}
-		if _, exists := os.LookupEnv(key); !exists {
-			_ = os.Setenv(key, value)
+		vars[key] = value
+		entries = append(entries, EnvEntry{Key: key, Value: value, Line: lineNo})
+	}
+	return entries, errs
+}
+
+// closingQuote finds the unescaped closing quote in s, or -1.
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if quote == '"' {
+				i++
+			}
+		case quote:
+			return i
 		}
 	}
+	return -1
+}
 
-	return scanner.Err()
+// expand resolves ${VAR} references and, for double-quoted values, escape
+// sequences.
+func expand(s string, escapes bool, resolve func(string) string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if escapes && c == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case '"', '\\', '$':
+				sb.WriteByte(s[i])
+			default:
+				sb.WriteByte('\\')
+				sb.WriteByte(s[i])
+			}
+			continue
+		}
+		if c == '$' && i+1 < len(s) && s[i+1] == '{' {
+			if end := strings.IndexByte(s[i+2:], '}'); end >= 0 {
+				sb.WriteString(resolve(s[i+2 : i+2+end]))
+				i += 2 + end
+				continue
+			}
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
 }
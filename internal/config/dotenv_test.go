@@ -0,0 +1,155 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDotEnvSyntax(t *testing.T) {
+	content := strings.Join([]string{
+		"# comment",
+		"export PLAIN=value # trailing comment",
+		"HASH=a#b",
+		`DOUBLE="line\none \"quoted\" \${PLAIN}"`,
+		"SINGLE='${PLAIN} \\n stays'",
+		`MULTI="first`,
+		`second"`,
+		"REF=${PLAIN}-x",
+		"EMPTY=",
+	}, "\n")
+	vars := map[string]string{}
+	entries, errs := ParseDotEnv(".env", content, vars)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	want := map[string]string{
+		"PLAIN":  "value",
+		"HASH":   "a#b",
+		"DOUBLE": "line\none \"quoted\" ${PLAIN}",
+		"SINGLE": `${PLAIN} \n stays`,
+		"MULTI":  "first\nsecond",
+		"REF":    "value-x",
+		"EMPTY":  "",
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d entries, got %d: %v", len(want), len(entries), entries)
+	}
+	for key, value := range want {
+		if vars[key] != value {
+			t.Fatalf("%s: expected %q, got %q", key, value, vars[key])
+		}
+	}
+	if entries[len(entries)-2].Line != 8 {
+		t.Fatalf("expected REF on line 8, got %d", entries[len(entries)-2].Line)
+	}
+}
+
+func TestParseDotEnvReportsLines(t *testing.T) {
+	content := "GOOD=1\nnot an assignment\n1BAD=x\nOPEN=\"never closed\n"
+	_, errs := ParseDotEnv("x.env", content, map[string]string{})
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %v", errs)
+	}
+	for i, prefix := range []string{"x.env:2:", "x.env:3:", "x.env:4:"} {
+		if !strings.HasPrefix(errs[i].Error(), prefix) {
+			t.Fatalf("expected %q prefix, got %q", prefix, errs[i])
+		}
+	}
+}
+
+func TestLoadDotEnvPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, ".env")
+	local := filepath.Join(dir, ".env.local")
+	extra := filepath.Join(dir, "extra.env")
+	files := map[string]string{
+		base:  "ENOCH_TEST_A=base\nENOCH_TEST_B=base\nENOCH_TEST_C=base\nENOCH_TEST_D=base\n",
+		local: "ENOCH_TEST_B=local\nENOCH_TEST_C=local\n",
+		extra: "ENOCH_TEST_C=extra\nENOCH_TEST_E=${ENOCH_TEST_B}\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resetEnv := setTestEnv(map[string]string{"ENOCH_TEST_D": "env"})
+	defer resetEnv()
+	defer forgetDotEnv()
+
+	if err := LoadDotEnv(base, local, extra); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"ENOCH_TEST_A": "base",
+		"ENOCH_TEST_B": "local",
+		"ENOCH_TEST_C": "extra",
+		"ENOCH_TEST_D": "env",
+		"ENOCH_TEST_E": "local",
+	}
+	for key, value := range want {
+		if got := os.Getenv(key); got != value {
+			t.Fatalf("%s: expected %q, got %q", key, value, got)
+		}
+	}
+}
+
+func TestLoadDotEnvReloadAppliesOnlyChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer forgetDotEnv()
+
+	write("ENOCH_TEST_KEEP=same\nENOCH_TEST_EDIT=old\nENOCH_TEST_DROP=gone\nENOCH_TEST_REF=${ENOCH_TEST_EDIT}\n")
+	if err := LoadDotEnv(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stop := make(chan struct{})
+	missed := make(chan bool, 1)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				missed <- false
+				return
+			default:
+			}
+			if os.Getenv("ENOCH_TEST_KEEP") != "same" {
+				missed <- true
+				return
+			}
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		if err := LoadDotEnv(path); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	close(stop)
+	if <-missed {
+		t.Fatal("an unchanged variable was missing during a reload")
+	}
+
+	write("ENOCH_TEST_KEEP=same\nENOCH_TEST_EDIT=new\nENOCH_TEST_REF=${ENOCH_TEST_EDIT}\n")
+	if err := LoadDotEnv(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"ENOCH_TEST_KEEP": "same",
+		"ENOCH_TEST_EDIT": "new",
+		"ENOCH_TEST_REF":  "new",
+	}
+	for key, value := range want {
+		if got := os.Getenv(key); got != value {
+			t.Fatalf("%s: expected %q, got %q", key, value, got)
+		}
+	}
+	if _, ok := os.LookupEnv("ENOCH_TEST_DROP"); ok {
+		t.Fatal("expected a removed entry to be unset")
+	}
+}
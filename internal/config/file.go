@@ -0,0 +1,428 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultConfigFile is read when ENOCH_CONFIG is not set. It is optional.
+const DefaultConfigFile = "enoch.toml"
+
+// sectionPrefixes maps the first segment of a config file section to the
+// environment variable prefix its keys stand for. Nested sections append
+// their name, so [codex.retry] attempts is CODEX_RETRY_ATTEMPTS and
+// [backends.http] url is BACKEND_HTTP_URL.
+var sectionPrefixes = map[string]string{
+	"":          "ENOCH_",
+	"telegram":  "TELEGRAM_",
+	"access":    "TELEGRAM_",
+	"codex":     "CODEX_",
+	"backends":  "BACKEND_",
+	"logging":   "LOG_",
+	"memory":    "MEMORY_",
+	"workspace": "WORKSPACE_",
+	"usage":     "USAGE_",
+	"jobs":      "JOB_",
+}
+
+// accessKeys are the keys allowed in [access].
+var accessKeys = map[string]bool{
+	"TELEGRAM_ALLOWED_CHAT_ID": true,
+	"TELEGRAM_ADMIN_IDS":       true,
+}
+
+// argKeys are command lines; arrays are joined so SplitArgs restores them.
+var argKeys = map[string]bool{
+	"CODEX_ARGS":       true,
+	"BACKEND_CLI_ARGS": true,
+}
+
+// Keys lists every setting Load understands, by environment variable name.
+var Keys = []string{
+	"BACKEND_CLI_ARGS", "BACKEND_CLI_CODEX_HOME", "BACKEND_CLI_COMMAND", "BACKEND_CLI_NAME",
+	"BACKEND_CLI_PROMPT_MODE", "BACKEND_CLI_TIMEOUT", "BACKEND_DEFAULT",
+	"BACKEND_HTTP_API_KEY", "BACKEND_HTTP_API_KEY_FILE", "BACKEND_HTTP_MODEL", "BACKEND_HTTP_NAME", "BACKEND_HTTP_STREAM",
+	"BACKEND_HTTP_SYSTEM_PROMPT", "BACKEND_HTTP_TIMEOUT", "BACKEND_HTTP_URL",
+	"CODEX_ALLOWED_APPROVALS", "CODEX_ALLOWED_EFFORTS", "CODEX_ALLOWED_EXTRA_ARGS",
+	"CODEX_ALLOWED_MODELS", "CODEX_ALLOWED_PROFILES", "CODEX_ALLOWED_SANDBOXES",
+	"CODEX_API_KEY_FILE", "CODEX_ARGS", "CODEX_COMMAND", "CODEX_DISABLE_CPR", "CODEX_ENV_ALLOW",
+	"CODEX_ENV_CHAT_ALLOW", "CODEX_ENV_DENY", "CODEX_HOME_OVERRIDE", "CODEX_KILL_GRACE",
+	"CODEX_LOG_RETENTION", "CODEX_OUTPUT_DIR", "CODEX_OUTPUT_LIMIT_KB", "CODEX_PROGRESS_INTERVAL",
+	"CODEX_PROMPT_MODE", "CODEX_RETRY_ATTEMPTS", "CODEX_RETRY_BACKOFF",
+	"CODEX_RETRY_MAX_BACKOFF", "CODEX_RETRY_ON", "CODEX_TIMEOUT", "CODEX_USAGE_REGEX",
+	"CODEX_USE_TTY", "CODEX_WORKDIR",
+	"ENOCH_ADMIN_TOKEN", "ENOCH_ADMIN_TOKEN_FILE", "ENOCH_CONFIG_WATCH", "ENOCH_DATA_DIR",
+	"ENOCH_HTTP_ADDR", "ENOCH_REDACT_PATTERN", "ENOCH_SHUTDOWN_GRACE",
+	"JOB_HISTORY_LIMIT",
+	"LOG_COLOR", "LOG_CONSOLE", "LOG_FILE", "LOG_LEVEL", "LOG_TIME_FORMAT",
+	"MEMORY_ROOT",
+	"TELEGRAM_ADMIN_IDS", "TELEGRAM_ALLOWED_CHAT_ID", "TELEGRAM_BOT_TOKEN", "TELEGRAM_BOT_TOKEN_FILE",
+	"TELEGRAM_CONTEXT_SIZE", "TELEGRAM_EDIT_POLICY", "TELEGRAM_POLL_INTERVAL",
+	"TELEGRAM_REMIND_TODO", "TELEGRAM_STATUS_CLEANUP", "TELEGRAM_TYPING_INTERVAL",
+	"USAGE_BUDGET_CHAT_DAILY", "USAGE_BUDGET_MODE", "USAGE_BUDGET_USER_DAILY",
+	"USAGE_PRICE_INPUT", "USAGE_PRICE_OUTPUT",
+	"WORKSPACE_KEEP_JOBS", "WORKSPACE_MAX_AGE", "WORKSPACE_MODE", "WORKSPACE_QUOTA_MB",
+	"WORKSPACE_REPO", "WORKSPACE_ROOT", "WORKSPACE_SKILLS_DIR",
+}
+
+// IsKey reports whether name is a setting Load understands.
+func IsKey(name string) bool {
+	i := sort.SearchStrings(Keys, name)
+	return i < len(Keys) && Keys[i] == name
+}
+
+// FileValue is a setting read from the config file.
+type FileValue struct {
+	// Key is the dotted name in the file, e.g. codex.timeout.
+	Key   string
+	Value string
+	Path  string
+	Line  int
+}
+
+// LoadFile parses a TOML config file into settings keyed by environment
+// variable name. Supported are [sections], key = value pairs with strings,
+// numbers, booleans and arrays (which may span lines), and # comments.
+func LoadFile(path string) (map[string]FileValue, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := map[string]FileValue{}
+	section := ""
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fail := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%s:%d: %s", path, lineNo, fmt.Sprintf(format, args...))
+		}
+
+		if strings.HasPrefix(line, "[") {
+			end := strings.Index(line, "]")
+			if end < 0 || !isComment(line[end+1:]) {
+				return nil, fail("invalid section header %q", line)
+			}
+			name := strings.TrimSpace(line[1:end])
+			first := strings.SplitN(name, ".", 2)[0]
+			if _, ok := sectionPrefixes[first]; !ok || name == "" || !validName(name, true) {
+				return nil, fail("unknown section [%s]", name)
+			}
+			section = name
+			continue
+		}
+
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return nil, fail("expected key = value")
+		}
+		key := strings.TrimSpace(line[:eq])
+		if !validName(key, false) {
+			return nil, fail("invalid key %q", key)
+		}
+		dotted := key
+		if section != "" {
+			dotted = section + "." + key
+		}
+		env := envName(section, key)
+		if !IsKey(env) || (strings.HasPrefix(section, "access") && !accessKeys[env]) {
+			return nil, fail("unknown key %s", dotted)
+		}
+		if prev, ok := values[env]; ok {
+			return nil, fail("%s is already set on line %d", dotted, prev.Line)
+		}
+
+		raw := strings.TrimSpace(line[eq+1:])
+		start := lineNo
+		// Arrays may continue over several lines.
+		for strings.HasPrefix(raw, "[") && !arrayClosed(raw) && scanner.Scan() {
+			lineNo++
+			raw += "\n" + strings.TrimSpace(scanner.Text())
+		}
+		value, err := parseFileValue(raw, argKeys[env])
+		if err != nil {
+			lineNo = start
+			return nil, fail("%s: %v", dotted, err)
+		}
+		values[env] = FileValue{Key: dotted, Value: value, Path: path, Line: start}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// envName turns a section and key into the environment variable name.
+func envName(section, key string) string {
+	parts := strings.Split(section, ".")
+	name := sectionPrefixes[parts[0]]
+	for _, part := range parts[1:] {
+		name += strings.ToUpper(part) + "_"
+	}
+	return name + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+func validName(name string, dotted bool) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+		case r == '.' && dotted:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func isComment(rest string) bool {
+	rest = strings.TrimSpace(rest)
+	return rest == "" || strings.HasPrefix(rest, "#")
+}
+
+// arrayClosed reports whether the brackets of an array value balance,
+// ignoring brackets inside strings.
+func arrayClosed(raw string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			// Skip a comment up to the end of the line.
+			for i < len(raw) && raw[i] != '\n' {
+				i++
+			}
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+			if depth == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseFileValue converts a TOML value to the string form Load expects.
+// Arrays become comma-separated lists, or quoted command lines for args.
+func parseFileValue(raw string, args bool) (string, error) {
+	value, rest, err := scanValue(raw)
+	if err != nil {
+		return "", err
+	}
+	if !isComment(rest) {
+		return "", fmt.Errorf("unexpected %q after value", strings.TrimSpace(rest))
+	}
+	if list, ok := value.([]string); ok {
+		if args {
+			quoted := make([]string, len(list))
+			for i, arg := range list {
+				quoted[i] = quoteArg(arg)
+			}
+			return strings.Join(quoted, " "), nil
+		}
+		for _, item := range list {
+			if strings.Contains(item, ",") {
+				return "", fmt.Errorf("list item %q must not contain a comma", item)
+			}
+		}
+		return strings.Join(list, ","), nil
+	}
+	return value.(string), nil
+}
+
+// scanValue reads one value (string or []string) from the start of raw and
+// returns the remaining text.
+func scanValue(raw string) (interface{}, string, error) {
+	raw = strings.TrimLeft(raw, " \t")
+	if raw == "" {
+		return nil, "", fmt.Errorf("missing value")
+	}
+	switch raw[0] {
+	case '"':
+		if strings.HasPrefix(raw, `"""`) {
+			return nil, "", fmt.Errorf("multi-line strings are not supported")
+		}
+		return scanBasicString(raw)
+	case '\'':
+		end := strings.IndexByte(raw[1:], '\'')
+		if end < 0 {
+			return nil, "", fmt.Errorf("unterminated string")
+		}
+		return raw[1 : end+1], raw[end+2:], nil
+	case '[':
+		return scanArray(raw)
+	}
+	end := strings.IndexAny(raw, " \t#,]\n")
+	if end < 0 {
+		end = len(raw)
+	}
+	word := raw[:end]
+	switch word {
+	case "true", "false":
+		return word, raw[end:], nil
+	}
+	number := strings.ReplaceAll(word, "_", "")
+	if _, err := strconv.ParseFloat(number, 64); err != nil {
+		return nil, "", fmt.Errorf("invalid value %q (strings must be quoted)", word)
+	}
+	return number, raw[end:], nil
+}
+
+func scanBasicString(raw string) (interface{}, string, error) {
+	var sb strings.Builder
+	for i := 1; i < len(raw); i++ {
+		c := raw[i]
+		switch c {
+		case '"':
+			return sb.String(), raw[i+1:], nil
+		case '\n':
+			return nil, "", fmt.Errorf("unterminated string")
+		case '\\':
+			i++
+			if i >= len(raw) {
+				return nil, "", fmt.Errorf("unterminated string")
+			}
+			switch raw[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case '"', '\\':
+				sb.WriteByte(raw[i])
+			case 'u':
+				if i+4 >= len(raw) {
+					return nil, "", fmt.Errorf("invalid \\u escape")
+				}
+				code, err := strconv.ParseUint(raw[i+1:i+5], 16, 32)
+				if err != nil {
+					return nil, "", fmt.Errorf("invalid \\u escape")
+				}
+				sb.WriteRune(rune(code))
+				i += 4
+			default:
+				return nil, "", fmt.Errorf("invalid escape \\%c", raw[i])
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return nil, "", fmt.Errorf("unterminated string")
+}
+
+func scanArray(raw string) (interface{}, string, error) {
+	items := []string{}
+	rest := raw[1:]
+	for {
+		rest = skipSpaceAndComments(rest)
+		if rest == "" {
+			return nil, "", fmt.Errorf("unterminated array")
+		}
+		if rest[0] == ']' {
+			return items, rest[1:], nil
+		}
+		value, next, err := scanValue(rest)
+		if err != nil {
+			return nil, "", err
+		}
+		item, ok := value.(string)
+		if !ok {
+			return nil, "", fmt.Errorf("nested arrays are not supported")
+		}
+		items = append(items, item)
+		rest = skipSpaceAndComments(next)
+		if strings.HasPrefix(rest, ",") {
+			rest = rest[1:]
+		} else if !strings.HasPrefix(rest, "]") {
+			return nil, "", fmt.Errorf("expected , or ] in array")
+		}
+	}
+}
+
+func skipSpaceAndComments(s string) string {
+	for {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if !strings.HasPrefix(s, "#") {
+			return s
+		}
+		if nl := strings.IndexByte(s, '\n'); nl >= 0 {
+			s = s[nl+1:]
+		} else {
+			return ""
+		}
+	}
+}
+
+// quoteArg quotes an argument for SplitArgs when needed.
+func quoteArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'"'"'`) + "'"
+}
+
+// source resolves setting values: the environment (including .env) first,
+// then the config file.
+type source struct {
+	file map[string]FileValue
+	errs *Errors
+}
+
+func (s source) lookup(key string) (string, bool) {
+	if value, ok := os.LookupEnv(key); ok {
+		return value, true
+	}
+	if value, ok := s.file[key]; ok {
+		return value.Value, true
+	}
+	return "", false
+}
+
+func (s source) get(key string) string {
+	value, _ := s.lookup(key)
+	return value
+}
+
+// annotate points an error about a setting that came from the config file
+// at the file, line and key that set it.
+func (s source) annotate(err error) error {
+	msg := err.Error()
+	best := ""
+	for env := range s.file {
+		if _, fromEnv := os.LookupEnv(env); fromEnv {
+			continue
+		}
+		if strings.Contains(msg, env) && len(env) > len(best) {
+			best = env
+		}
+	}
+	if best == "" {
+		return err
+	}
+	value := s.file[best]
+	return fmt.Errorf("%s:%d: %s: %w", value.Path, value.Line, value.Key, err)
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "enoch.toml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	path := writeConfigFile(t, `# enoch settings
+data_dir = "state"
+
+[telegram]
+bot_token = "file-token"
+context_size = 6 # turns
+
+[access]
+admin_ids = [1, 2]
+
+[codex]
+args = ["exec", "--model", "o3 mini", "{prompt}"]
+allowed_efforts = [
+  "low",  # cheap
+  "high",
+]
+
+[codex.retry]
+attempts = 5
+
+[backends.http]
+url = 'http://localhost:11434/v1'
+stream = false
+`)
+	resetEnv := setTestEnv(map[string]string{
+		"ENOCH_CONFIG":          path,
+		"TELEGRAM_BOT_TOKEN":    "",
+		"TELEGRAM_CONTEXT_SIZE": "3",
+	})
+	defer resetEnv()
+	os.Unsetenv("TELEGRAM_BOT_TOKEN")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ConfigFile != path || cfg.TelegramBotToken != "file-token" || cfg.DataDir != "state" {
+		t.Fatalf("file values not applied: %+v", cfg)
+	}
+	if cfg.TelegramContextSize != 3 {
+		t.Fatalf("environment should override the file, got %d", cfg.TelegramContextSize)
+	}
+	if len(cfg.TelegramAdminIDs) != 2 || cfg.CodexRetryAttempts != 5 {
+		t.Fatalf("unexpected admins/retries: %v %d", cfg.TelegramAdminIDs, cfg.CodexRetryAttempts)
+	}
+	if strings.Join(cfg.CodexArgs, "|") != "exec|--model|o3 mini|{prompt}" {
+		t.Fatalf("unexpected args: %#v", cfg.CodexArgs)
+	}
+	if strings.Join(cfg.CodexAllowedEfforts, ",") != "low,high" {
+		t.Fatalf("unexpected efforts: %#v", cfg.CodexAllowedEfforts)
+	}
+	if len(cfg.Backends) != 1 || cfg.Backends[0].URL != "http://localhost:11434/v1" || cfg.Backends[0].Stream {
+		t.Fatalf("unexpected backends: %#v", cfg.Backends)
+	}
+}
+
+func TestLoadConfigFileErrorsNameKeyAndLine(t *testing.T) {
+	cases := map[string]string{
+		"[codex]\ntimout = 5\n":                       ":2: unknown key codex.timout",
+		"[codex]\ntimeout = five\n":                   ":2: codex.timeout: invalid value",
+		"[telegram]\nedit_policy = \"x\"\n":           ":2: telegram.edit_policy: TELEGRAM_EDIT_POLICY must be",
+		"[access]\npoll_interval = 1\n":               ":2: unknown key access.poll_interval",
+		"\n[chat]\n":                                  ":2: unknown section [chat]",
+		"[codex]\ncommand = \"a\"\ncommand = \"b\"\n": ":3: codex.command is already set on line 2",
+	}
+	for content, want := range cases {
+		path := writeConfigFile(t, content)
+		resetEnv := setTestEnv(map[string]string{"ENOCH_CONFIG": path, "TELEGRAM_BOT_TOKEN": "token"})
+		_, err := Load()
+		resetEnv()
+		if err == nil || !strings.Contains(err.Error(), path+want) {
+			t.Fatalf("%q: expected %q in error, got %v", content, want, err)
+		}
+	}
+}
+
+func TestKeysSorted(t *testing.T) {
+	if !sort.StringsAreSorted(Keys) {
+		t.Fatalf("Keys must stay sorted for IsKey")
+	}
+}
+
+func TestExampleConfigFileParses(t *testing.T) {
+	if _, err := LoadFile(filepath.Join("..", "..", "enoch.example.toml")); err != nil {
+		t.Fatalf("example config: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"os"
+	"strings"
+
+	"enoch/internal/redact"
+)
+
+// exportedSecrets are read by the agent CLIs rather than by enoch. When only
+// their *_FILE variant is set, Load exports the file's content under the
+// plain name so subprocesses inherit it.
+var exportedSecrets = []string{"CODEX_API_KEY", "OPENAI_API_KEY"}
+
+// secret returns the value of key, or the content of the file named by
+// key_FILE. Setting both is an error.
+func (s source) secret(key string) string {
+	value := strings.TrimSpace(s.get(key))
+	path := strings.TrimSpace(s.get(key + "_FILE"))
+	if path == "" {
+		return value
+	}
+	if value != "" {
+		s.failf("set only one of %s and %s_FILE", key, key)
+		return value
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		s.failf("%s_FILE: %v", key, err)
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// exportSecretFiles sets the exportedSecrets from their *_FILE variants.
+// The values are tracked like dotenv values so a reload re-reads them.
+func exportSecretFiles(src source) {
+	dotenvMu.Lock()
+	defer dotenvMu.Unlock()
+	next := map[string]string{}
+	for _, key := range exportedSecrets {
+		path := strings.TrimSpace(src.get(key + "_FILE"))
+		if _, ok := os.LookupEnv(key); ok && !owned(secretSet, key) {
+			if path != "" {
+				src.failf("set only one of %s and %s_FILE", key, key)
+			}
+			continue
+		}
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			src.failf("%s_FILE: %v", key, err)
+			continue
+		}
+		next[key] = strings.TrimSpace(string(data))
+	}
+	applyEnv(&secretSet, next)
+}
+
+// BotSecrets returns the credentials of the bot itself: the bot token, the
+// admin token and the backend API keys.
+func (c Config) BotSecrets() []string {
+	secrets := []string{c.TelegramBotToken, c.AdminToken}
+	for _, backend := range c.Backends {
+		secrets = append(secrets, backend.APIKey)
+	}
+	return secrets
+}
+
+// Secrets returns the values the redaction layer masks: the bot's own
+// secrets and every sensitive-looking environment variable.
+func (c Config) Secrets() []string {
+	secrets := c.BotSecrets()
+	for _, entry := range os.Environ() {
+		if i := strings.IndexByte(entry, '='); i > 0 && redact.IsSensitive(entry[:i]) {
+			secrets = append(secrets, entry[i+1:])
+		}
+	}
+	return secrets
+}
+
+// Redactor returns a redactor for the configured secrets and
+// ENOCH_REDACT_PATTERN.
+func (c Config) Redactor() *redact.Redactor {
+	return redact.New(c.Secrets(), c.RedactPattern)
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Errors lists every problem found while loading the configuration.
+type Errors []error
+
+func (e Errors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = "  - " + err.Error()
+	}
+	return fmt.Sprintf("%d config problems:\n%s", len(e), strings.Join(lines, "\n"))
+}
+
+func newSource(file map[string]FileValue) source {
+	return source{file: file, errs: &Errors{}}
+}
+
+// failf records a problem, pointing at the config file line when the value
+// came from there.
+func (s source) failf(format string, args ...interface{}) {
+	*s.errs = append(*s.errs, s.annotate(fmt.Errorf(format, args...)))
+}
+
+// err returns the recorded problems, or nil.
+func (s source) err() error {
+	if len(*s.errs) == 0 {
+		return nil
+	}
+	return *s.errs
+}
+
+// checkedPrefixes are the environment prefixes scanned for typos.
+var checkedPrefixes = []string{"TELEGRAM_", "CODEX_", "LOG_"}
+
+// externalKeys share a checked prefix but belong to other programs.
+var externalKeys = map[string]bool{
+	"CODEX_HOME":    true, // read by the codex CLI itself
+	"CODEX_API_KEY": true,
+}
+
+// UnknownKeys warns about TELEGRAM_*, CODEX_* and LOG_* environment
+// variables that Load does not understand, which are usually typos.
+func UnknownKeys() []string {
+	var warnings []string
+	for _, kv := range os.Environ() {
+		key := kv
+		if i := strings.IndexByte(kv, '='); i >= 0 {
+			key = kv[:i]
+		}
+		if IsKey(key) || externalKeys[key] || !hasCheckedPrefix(key) {
+			continue
+		}
+		warning := fmt.Sprintf("unknown setting %s", key)
+		if guess := closestKey(key); guess != "" {
+			warning += fmt.Sprintf(" (did you mean %s?)", guess)
+		}
+		warnings = append(warnings, warning)
+	}
+	sort.Strings(warnings)
+	return warnings
+}
+
+func hasCheckedPrefix(key string) bool {
+	for _, prefix := range checkedPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// closestKey returns the known key within two edits of key, if any.
+func closestKey(key string) string {
+	best, bestDistance := "", 3
+	for _, known := range Keys {
+		if d := editDistance(key, known); d < bestDistance {
+			best, bestDistance = known, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, minInt(cur[j-1]+1, prev[j-1]+cost))
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadCollectsAllProblems(t *testing.T) {
+	resetEnv := setTestEnv(map[string]string{
+		"TELEGRAM_BOT_TOKEN":     "token",
+		"TELEGRAM_POLL_INTERVAL": "soon",
+		"CODEX_TIMEOUT":          "0",
+		"LOG_COLOR":              "ture",
+		"LOG_LEVEL":              "loud",
+	})
+	defer resetEnv()
+
+	_, err := Load()
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("expected Errors, got %T %v", err, err)
+	}
+	if len(errs) != 4 {
+		t.Fatalf("expected 4 problems, got %d: %v", len(errs), err)
+	}
+	for _, key := range []string{"TELEGRAM_POLL_INTERVAL", "CODEX_TIMEOUT", "LOG_COLOR", "LOG_LEVEL"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Fatalf("expected %s in %q", key, err)
+		}
+	}
+}
+
+func TestParseDurationAcceptsSecondsAndDurations(t *testing.T) {
+	resetEnv := setTestEnv(map[string]string{
+		"TELEGRAM_BOT_TOKEN":     "token",
+		"TELEGRAM_POLL_INTERVAL": "1.5",
+		"CODEX_TIMEOUT":          "2m",
+		"CODEX_KILL_GRACE":       "90s",
+	})
+	defer resetEnv()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.TelegramPollInterval != 1500*time.Millisecond {
+		t.Fatalf("unexpected poll interval: %s", cfg.TelegramPollInterval)
+	}
+	if cfg.CodexTimeout != 2*time.Minute || cfg.CodexKillGrace != 90*time.Second {
+		t.Fatalf("unexpected durations: %s %s", cfg.CodexTimeout, cfg.CodexKillGrace)
+	}
+}
+
+func TestUnknownKeysSuggestsCloseMatch(t *testing.T) {
+	resetEnv := setTestEnv(map[string]string{
+		"CODEX_TIMOUT": "30",
+		"CODEX_HOME":   "/tmp/codex",
+	})
+	defer resetEnv()
+
+	warnings := UnknownKeys()
+	found := false
+	for _, w := range warnings {
+		if strings.Contains(w, "CODEX_HOME") {
+			t.Fatalf("CODEX_HOME should not be reported: %v", warnings)
+		}
+		if strings.Contains(w, "CODEX_TIMOUT") && strings.Contains(w, "did you mean CODEX_TIMEOUT") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected a warning for CODEX_TIMOUT, got %v", warnings)
+	}
+}
+
+func TestDescribeRedactsSecrets(t *testing.T) {
+	cfg := Config{
+		TelegramBotToken: "123:secret",
+		CodexArgs:        []string{"exec", "hello world"},
+		Backends:         []BackendConfig{{Name: "local", Type: "http", URL: "http://x", APIKey: "sk-1"}},
+	}
+	values := map[string]string{}
+	for _, s := range Describe(cfg) {
+		values[s.Key] = s.Value
+	}
+	if values["TELEGRAM_BOT_TOKEN"] != "***" || values["BACKEND_HTTP_API_KEY"] != "***" {
+		t.Fatalf("expected secrets redacted: %v", values)
+	}
+	if values["CODEX_ARGS"] != `exec 'hello world'` {
+		t.Fatalf("unexpected CODEX_ARGS: %q", values["CODEX_ARGS"])
+	}
+	if values["BACKEND_HTTP_URL"] != "http://x" {
+		t.Fatalf("expected backend expanded: %v", values)
+	}
+}
+
+func TestSecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	keyFile := filepath.Join(dir, "codex_key")
+	if err := os.WriteFile(tokenFile, []byte("from-file-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, []byte("sk-from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	resetEnv := setTestEnv(map[string]string{
+		"TELEGRAM_BOT_TOKEN":      "",
+		"TELEGRAM_BOT_TOKEN_FILE": tokenFile,
+		"CODEX_API_KEY":           "",
+		"CODEX_API_KEY_FILE":      keyFile,
+	})
+	defer resetEnv()
+	os.Unsetenv("TELEGRAM_BOT_TOKEN")
+	os.Unsetenv("CODEX_API_KEY")
+	defer forgetDotEnv()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.TelegramBotToken != "from-file-token" {
+		t.Fatalf("unexpected token: %q", cfg.TelegramBotToken)
+	}
+	if got := os.Getenv("CODEX_API_KEY"); got != "sk-from-file" {
+		t.Fatalf("expected CODEX_API_KEY exported, got %q", got)
+	}
+	if got := cfg.Redactor().String("token from-file-token key sk-from-file"); got != "token *** key ***" {
+		t.Fatalf("expected secrets redacted, got %q", got)
+	}
+
+	os.Setenv("TELEGRAM_BOT_TOKEN", "also-set")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "set only one of TELEGRAM_BOT_TOKEN") {
+		t.Fatalf("expected a conflict error, got %v", err)
+	}
+}
@@ -0,0 +1,383 @@
+// Package doctor diagnoses common setup problems for `enoch doctor`.
+package doctor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"enoch/internal/config"
+	"enoch/internal/redact"
+)
+
+// Status is the outcome of a check.
+type Status int
+
+const (
+	Pass Status = iota
+	Warn
+	Fail
+	Skip
+)
+
+func (s Status) String() string {
+	switch s {
+	case Pass:
+		return "PASS"
+	case Warn:
+		return "WARN"
+	case Fail:
+		return "FAIL"
+	default:
+		return "SKIP"
+	}
+}
+
+// Result is one check with a hint on how to fix it.
+type Result struct {
+	Name   string
+	Status Status
+	Detail string
+	Hint   string
+}
+
+// Options configures the checks. Zero values use the real network, PATH and
+// processes.
+type Options struct {
+	Config config.Config
+	// Env is the environment the agent runs with.
+	Env      []string
+	Client   *http.Client
+	APIBase  string
+	LookPath func(string) (string, error)
+	// Command runs name with args and env and returns its combined output.
+	Command func(ctx context.Context, env []string, name string, args ...string) (string, error)
+}
+
+func (o *Options) defaults() {
+	if o.Client == nil {
+		o.Client = &http.Client{Timeout: 15 * time.Second}
+	}
+	if o.APIBase == "" {
+		o.APIBase = "https://api.telegram.org"
+	}
+	if o.LookPath == nil {
+		o.LookPath = exec.LookPath
+	}
+	if o.Command == nil {
+		o.Command = runCommand
+	}
+	if o.Env == nil {
+		o.Env = os.Environ()
+	}
+}
+
+// Run performs every check in order.
+func Run(ctx context.Context, opts Options) []Result {
+	opts.defaults()
+	cfg := opts.Config
+	redactor := cfg.Redactor()
+
+	var results []Result
+	results = append(results, checkTelegram(ctx, opts, redactor)...)
+	codexPath, binary := checkCodexBinary(ctx, opts)
+	results = append(results, binary)
+	results = append(results, checkCodexAuth(ctx, opts, codexPath))
+	results = append(results, checkScript(opts))
+	results = append(results, checkWorkdir(cfg))
+	results = append(results, checkWritable("data dir", cfg.DataDir, "ENOCH_DATA_DIR"))
+
+	memoryRoot := cfg.MemoryRoot
+	if memoryRoot == "" {
+		memoryRoot = "."
+	}
+	results = append(results, checkWritable("memory dir", filepath.Join(memoryRoot, "memory"), "MEMORY_ROOT"))
+	if cfg.LogFile != "" {
+		results = append(results, checkWritable("log dir", filepath.Dir(cfg.LogFile), "LOG_FILE"))
+	}
+	if cfg.CodexOutputDir != "" {
+		results = append(results, checkWritable("output dir", cfg.CodexOutputDir, "CODEX_OUTPUT_DIR"))
+	}
+	results = append(results, checkSkills(cfg)...)
+
+	for i := range results {
+		results[i].Detail = redactor.String(results[i].Detail)
+	}
+	return results
+}
+
+// Print writes the results and reports whether any check failed.
+func Print(w io.Writer, results []Result) bool {
+	failed := false
+	for _, r := range results {
+		fmt.Fprintf(w, "[%s] %-16s %s\n", r.Status, r.Name, r.Detail)
+		if r.Hint != "" && (r.Status == Fail || r.Status == Warn) {
+			fmt.Fprintf(w, "       hint: %s\n", r.Hint)
+		}
+		if r.Status == Fail {
+			failed = true
+		}
+	}
+	return failed
+}
+
+type apiResponse struct {
+	Ok          bool            `json:"ok"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+}
+
+func callAPI(ctx context.Context, opts Options, method string, out interface{}) (int, error) {
+	url := opts.APIBase + "/bot" + opts.Config.TelegramBotToken + "/" + method
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := opts.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	var decoded apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return resp.StatusCode, fmt.Errorf("%s: %s", method, resp.Status)
+	}
+	if !decoded.Ok {
+		return resp.StatusCode, fmt.Errorf("%s: %s", method, decoded.Description)
+	}
+	return resp.StatusCode, json.Unmarshal(decoded.Result, out)
+}
+
+func checkTelegram(ctx context.Context, opts Options, redactor *redact.Redactor) []Result {
+	var me struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+	}
+	status, err := callAPI(ctx, opts, "getMe", &me)
+	if err != nil {
+		hint := "check network access to api.telegram.org (or a proxy via HTTPS_PROXY)"
+		if status == http.StatusUnauthorized || status == http.StatusNotFound {
+			hint = "TELEGRAM_BOT_TOKEN is wrong or revoked; copy it again from @BotFather"
+		}
+		return []Result{
+			{Name: "telegram token", Status: Fail, Detail: redactor.String(err.Error()), Hint: hint},
+			{Name: "telegram webhook", Status: Skip, Detail: "needs a working token"},
+		}
+	}
+	results := []Result{{Name: "telegram token", Status: Pass, Detail: fmt.Sprintf("@%s (id %d)", me.Username, me.ID)}}
+
+	var webhook struct {
+		URL                string `json:"url"`
+		PendingUpdateCount int    `json:"pending_update_count"`
+	}
+	if _, err := callAPI(ctx, opts, "getWebhookInfo", &webhook); err != nil {
+		return append(results, Result{Name: "telegram webhook", Status: Warn, Detail: err.Error()})
+	}
+	if webhook.URL != "" {
+		return append(results, Result{
+			Name:   "telegram webhook",
+			Status: Fail,
+			Detail: fmt.Sprintf("a webhook is set (%s); polling with getUpdates fails with 409 Conflict", webhook.URL),
+			Hint:   "remove it with https://api.telegram.org/bot<token>/deleteWebhook, or stop the other service using this bot",
+		})
+	}
+	return append(results, Result{Name: "telegram webhook", Status: Pass, Detail: fmt.Sprintf("none (%d pending updates)", webhook.PendingUpdateCount)})
+}
+
+func checkCodexBinary(ctx context.Context, opts Options) (string, Result) {
+	command := opts.Config.CodexCommand
+	path, err := opts.LookPath(command)
+	if err != nil {
+		return "", Result{
+			Name:   "codex binary",
+			Status: Fail,
+			Detail: fmt.Sprintf("%s not found in PATH", command),
+			Hint:   "install it with `npm install -g @openai/codex`, or point CODEX_COMMAND at the binary",
+		}
+	}
+	out, err := opts.Command(ctx, opts.Env, path, "--version")
+	if err != nil {
+		return path, Result{Name: "codex binary", Status: Warn, Detail: fmt.Sprintf("%s (--version failed: %v)", path, err)}
+	}
+	return path, Result{Name: "codex binary", Status: Pass, Detail: fmt.Sprintf("%s (%s)", path, firstLine(out))}
+}
+
+func checkCodexAuth(ctx context.Context, opts Options, path string) Result {
+	if path == "" {
+		return Result{Name: "codex auth", Status: Skip, Detail: "needs the codex binary"}
+	}
+	if envValue(opts.Env, "CODEX_API_KEY") != "" {
+		return Result{Name: "codex auth", Status: Pass, Detail: "CODEX_API_KEY is set"}
+	}
+	out, err := opts.Command(ctx, opts.Env, path, "login", "status")
+	if err != nil {
+		hint := "run `codex login` (`codex login --device-auth` without a browser) or set CODEX_API_KEY"
+		if home := envValue(opts.Env, "CODEX_HOME"); home != "" {
+			hint += fmt.Sprintf("; CODEX_HOME is %s, so a login cached in ~/.codex/auth.json is not used", home)
+		}
+		detail := firstLine(out)
+		if detail == "" {
+			detail = err.Error()
+		}
+		return Result{Name: "codex auth", Status: Fail, Detail: "not logged in: " + detail, Hint: hint}
+	}
+	return Result{Name: "codex auth", Status: Pass, Detail: firstLine(out)}
+}
+
+func checkScript(opts Options) Result {
+	if !opts.Config.CodexUseTTY {
+		return Result{Name: "script", Status: Skip, Detail: "CODEX_USE_TTY is off"}
+	}
+	path, err := opts.LookPath("script")
+	if err != nil {
+		return Result{
+			Name:   "script",
+			Status: Fail,
+			Detail: "CODEX_USE_TTY is on but `script` is not in PATH",
+			Hint:   "install util-linux (Linux) or set CODEX_USE_TTY=false",
+		}
+	}
+	return Result{Name: "script", Status: Pass, Detail: path}
+}
+
+func checkWorkdir(cfg config.Config) Result {
+	info, err := os.Stat(cfg.CodexWorkdir)
+	if err != nil || !info.IsDir() {
+		return Result{
+			Name:   "workdir",
+			Status: Fail,
+			Detail: fmt.Sprintf("%s is not a directory", cfg.CodexWorkdir),
+			Hint:   "set CODEX_WORKDIR (or --workdir) to the project checkout",
+		}
+	}
+	abs, _ := filepath.Abs(cfg.CodexWorkdir)
+	return Result{Name: "workdir", Status: Pass, Detail: abs}
+}
+
+// checkWritable creates dir if needed and writes a probe file into it.
+func checkWritable(name, dir, key string) Result {
+	fail := func(err error) Result {
+		return Result{Name: name, Status: Fail, Detail: err.Error(), Hint: fmt.Sprintf("fix the permissions of %s or change %s", dir, key)}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fail(err)
+	}
+	probe, err := os.CreateTemp(dir, ".enoch-doctor-*")
+	if err != nil {
+		return fail(err)
+	}
+	probe.Close()
+	_ = os.Remove(probe.Name())
+	return Result{Name: name, Status: Pass, Detail: dir + " is writable"}
+}
+
+// checkSkills verifies the skills directory: every skill needs a SKILL.md
+// with name and description in its YAML frontmatter, and Codex finds the
+// project skills through .codex/skills in the workdir.
+func checkSkills(cfg config.Config) []Result {
+	dir := cfg.WorkspaceSkillsDir
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return []Result{{
+			Name:   "skills",
+			Status: Warn,
+			Detail: fmt.Sprintf("%s not found", dir),
+			Hint:   "run enoch from the project checkout or set WORKSPACE_SKILLS_DIR",
+		}}
+	}
+	var problems []string
+	count := 0
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		count++
+		if problem := checkSkill(filepath.Join(dir, entry.Name(), "SKILL.md")); problem != "" {
+			problems = append(problems, entry.Name()+": "+problem)
+		}
+	}
+	results := []Result{{Name: "skills", Status: Pass, Detail: fmt.Sprintf("%d skills in %s", count, dir)}}
+	if len(problems) > 0 {
+		results[0] = Result{
+			Name:   "skills",
+			Status: Fail,
+			Detail: strings.Join(problems, "; "),
+			Hint:   "start every SKILL.md with ---, name: and description: lines and a closing ---; Codex rejects skills without them",
+		}
+	}
+
+	link := filepath.Join(cfg.CodexWorkdir, ".codex", "skills")
+	if _, err := os.Stat(link); err != nil {
+		results = append(results, Result{
+			Name:   "codex skills",
+			Status: Warn,
+			Detail: fmt.Sprintf("%s not found, so Codex does not load the project skills", link),
+			Hint:   "mkdir -p .codex && ln -s ../skills .codex/skills",
+		})
+	} else {
+		results = append(results, Result{Name: "codex skills", Status: Pass, Detail: link})
+	}
+	return results
+}
+
+func checkSkill(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return "missing SKILL.md"
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "---" {
+		return "SKILL.md has no YAML frontmatter"
+	}
+	hasName, hasDescription := false, false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "---" {
+			switch {
+			case !hasName:
+				return "frontmatter has no name"
+			case !hasDescription:
+				return "frontmatter has no description"
+			}
+			return ""
+		}
+		hasName = hasName || strings.HasPrefix(line, "name:")
+		hasDescription = hasDescription || strings.HasPrefix(line, "description:")
+	}
+	return "frontmatter is not closed"
+}
+
+func runCommand(ctx context.Context, env []string, name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = env
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func firstLine(text string) string {
+	text = strings.TrimSpace(text)
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	return text
+}
+
+func envValue(env []string, key string) string {
+	for _, kv := range env {
+		if strings.HasPrefix(kv, key+"=") {
+			return kv[len(key)+1:]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,174 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"enoch/internal/config"
+)
+
+const testToken = "123456:secret-token-value"
+
+func telegramServer(t *testing.T, webhook string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bot" + testToken + "/getMe":
+			fmt.Fprint(w, `{"ok":true,"result":{"id":42,"username":"enoch_bot"}}`)
+		case "/bot" + testToken + "/getWebhookInfo":
+			fmt.Fprintf(w, `{"ok":true,"result":{"url":%q,"pending_update_count":3}}`, webhook)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"ok":false,"error_code":401,"description":"Unauthorized"}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func testOptions(t *testing.T, apiBase string) Options {
+	t.Helper()
+	dir := t.TempDir()
+	skill := filepath.Join(dir, "skills", "notes")
+	if err := os.MkdirAll(skill, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(skill, "SKILL.md"), []byte("---\nname: notes\ndescription: Take notes.\n---\nbody\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, ".codex", "skills"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return Options{
+		Config: config.Config{
+			TelegramBotToken:   testToken,
+			CodexCommand:       "codex",
+			CodexWorkdir:       dir,
+			DataDir:            filepath.Join(dir, "data"),
+			MemoryRoot:         dir,
+			LogFile:            filepath.Join(dir, "logs", "enoch.log"),
+			WorkspaceSkillsDir: filepath.Join(dir, "skills"),
+		},
+		Env:      []string{"PATH=/usr/bin"},
+		APIBase:  apiBase,
+		LookPath: func(name string) (string, error) { return "/usr/bin/" + name, nil },
+		Command: func(ctx context.Context, env []string, name string, args ...string) (string, error) {
+			if args[0] == "--version" {
+				return "codex-cli 0.50.0\n", nil
+			}
+			return "Logged in using ChatGPT\n", nil
+		},
+	}
+}
+
+func statuses(results []Result) map[string]Status {
+	out := map[string]Status{}
+	for _, r := range results {
+		out[r.Name] = r.Status
+	}
+	return out
+}
+
+func TestRunAllPass(t *testing.T) {
+	server := telegramServer(t, "")
+	results := Run(context.Background(), testOptions(t, server.URL))
+	for _, r := range results {
+		if r.Status != Pass && r.Status != Skip {
+			t.Errorf("%s: %s %s", r.Name, r.Status, r.Detail)
+		}
+	}
+	var out bytes.Buffer
+	if Print(&out, results) {
+		t.Fatalf("expected no failures:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "@enoch_bot") {
+		t.Fatalf("expected the bot username in the report:\n%s", out.String())
+	}
+}
+
+func TestRunReportsProblemsWithHints(t *testing.T) {
+	server := telegramServer(t, "https://example.com/hook")
+	opts := testOptions(t, server.URL)
+	opts.Config.CodexUseTTY = true
+	opts.LookPath = func(name string) (string, error) {
+		if name == "script" {
+			return "", errors.New("not found")
+		}
+		return "/usr/bin/" + name, nil
+	}
+	opts.Command = func(ctx context.Context, env []string, name string, args ...string) (string, error) {
+		if args[0] == "--version" {
+			return "codex-cli 0.50.0", nil
+		}
+		return "Not logged in", errors.New("exit status 1")
+	}
+	broken := filepath.Join(opts.Config.WorkspaceSkillsDir, "broken")
+	if err := os.MkdirAll(broken, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(broken, "SKILL.md"), []byte("---\nname: broken\n---\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	results := Run(context.Background(), opts)
+	got := statuses(results)
+	for _, name := range []string{"telegram webhook", "codex auth", "script", "skills"} {
+		if got[name] != Fail {
+			t.Errorf("%s: expected FAIL, got %s", name, got[name])
+		}
+	}
+	var out bytes.Buffer
+	if !Print(&out, results) {
+		t.Fatalf("expected failures")
+	}
+	for _, want := range []string{"deleteWebhook", "codex login", "CODEX_USE_TTY=false", "broken: frontmatter has no description"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report is missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRunBadTokenIsRedacted(t *testing.T) {
+	server := telegramServer(t, "")
+	opts := testOptions(t, server.URL)
+	opts.Config.TelegramBotToken = "999999:wrong-token-value"
+	opts.APIBase = "http://127.0.0.1:1"
+
+	results := Run(context.Background(), opts)
+	got := statuses(results)
+	if got["telegram token"] != Fail || got["telegram webhook"] != Skip {
+		t.Fatalf("unexpected statuses: %v", got)
+	}
+	var out bytes.Buffer
+	Print(&out, results)
+	if strings.Contains(out.String(), "wrong-token-value") {
+		t.Fatalf("token leaked into the report:\n%s", out.String())
+	}
+
+	opts.APIBase = server.URL
+	results = Run(context.Background(), opts)
+	for _, r := range results {
+		if r.Name == "telegram token" && !strings.Contains(r.Hint, "@BotFather") {
+			t.Fatalf("expected a token hint, got %q", r.Hint)
+		}
+	}
+}
+
+func TestCheckSkillsWarnsWithoutCodexLink(t *testing.T) {
+	opts := testOptions(t, "")
+	if err := os.RemoveAll(filepath.Join(opts.Config.CodexWorkdir, ".codex")); err != nil {
+		t.Fatal(err)
+	}
+	results := checkSkills(opts.Config)
+	if got := statuses(results); got["skills"] != Pass || got["codex skills"] != Warn {
+		t.Fatalf("unexpected statuses: %v", got)
+	}
+}
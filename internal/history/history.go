@@ -0,0 +1,221 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	StatusRunning  = "running"
+	StatusOK       = "ok"
+	StatusFailed   = "failed"
+	StatusCanceled = "canceled"
+	// StatusRejected marks jobs that never ran (budget, workspace quota).
+	StatusRejected = "rejected"
+	// StatusInterrupted marks jobs still running when the bot stopped.
+	StatusInterrupted = "interrupted"
+)
+
+// Job is one entry of the job history.
+type Job struct {
+	ID          int       `json:"id"`
+	ChatID      int64     `json:"chat_id"`
+	UserID      int64     `json:"user_id,omitempty"`
+	Trace       string    `json:"trace"`
+	Backend     string    `json:"backend,omitempty"`
+	Prompt      string    `json:"prompt"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Status      string    `json:"status"`
+	ExitCode    int       `json:"exit_code"`
+	ErrorClass  string    `json:"error_class,omitempty"`
+	Attempts    int       `json:"attempts,omitempty"`
+	OutputBytes int       `json:"output_bytes"`
+	Truncated   bool      `json:"truncated,omitempty"`
+	// Note explains rejections and cancellations.
+	Note string `json:"note,omitempty"`
+}
+
+// Duration returns how long the job ran (so far, while running).
+func (j Job) Duration() time.Duration {
+	if j.End.IsZero() {
+		if j.Status != StatusRunning {
+			return 0
+		}
+		return time.Since(j.Start)
+	}
+	return j.End.Sub(j.Start)
+}
+
+// Store keeps the most recent jobs in dir/jobs.json and their replies in
+// dir/<id>.out.
+type Store struct {
+	Now func() time.Time
+
+	dir   string
+	limit int
+	mu    sync.Mutex
+	next  int
+	jobs  []Job
+}
+
+// Open loads the history in dir, keeping at most limit jobs (0 keeps all).
+// Jobs left running by a previous process are marked interrupted.
+func Open(dir string, limit int) (*Store, error) {
+	s, err := Read(dir)
+	if err != nil {
+		return nil, err
+	}
+	s.limit = limit
+	interrupted := false
+	for i := range s.jobs {
+		if s.jobs[i].Status == StatusRunning {
+			s.jobs[i].Status = StatusInterrupted
+			interrupted = true
+		}
+	}
+	if interrupted {
+		if err := s.saveLocked(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Read loads the history in dir as it is, for inspecting it while the bot
+// may be running. Jobs must not be recorded through the returned store.
+func Read(dir string) (*Store, error) {
+	s := &Store{Now: time.Now, dir: dir, next: 1}
+	data, err := os.ReadFile(s.indexPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.jobs); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", s.indexPath(), err)
+	}
+	for i := range s.jobs {
+		if s.jobs[i].ID >= s.next {
+			s.next = s.jobs[i].ID + 1
+		}
+	}
+	return s, nil
+}
+
+// Start records a new running job and returns it with its id assigned.
+func (s *Store) Start(j Job) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.ID = s.next
+	s.next++
+	if j.Start.IsZero() {
+		j.Start = s.Now()
+	}
+	j.Status = StatusRunning
+	s.jobs = append(s.jobs, j)
+	s.pruneLocked()
+	return j, s.saveLocked()
+}
+
+// Finish stores the final state of a job and its reply text.
+func (s *Store) Finish(j Job, output string) error {
+	if j.End.IsZero() {
+		j.End = s.Now()
+	}
+	j.OutputBytes = len(output)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found := false
+	for i := range s.jobs {
+		if s.jobs[i].ID == j.ID {
+			s.jobs[i] = j
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("job %d not found", j.ID)
+	}
+	if output != "" {
+		if err := os.WriteFile(s.outputPath(j.ID), []byte(output), 0o644); err != nil {
+			return err
+		}
+	}
+	return s.saveLocked()
+}
+
+// Get returns the job with id.
+func (s *Store) Get(id int) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.ID == id {
+			return j, true
+		}
+	}
+	return Job{}, false
+}
+
+// Output returns the stored reply of a job.
+func (s *Store) Output(id int) (string, error) {
+	data, err := os.ReadFile(s.outputPath(id))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Recent returns up to n jobs of chatID (0 for all chats), newest first.
+func (s *Store) Recent(chatID int64, n int) []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Job
+	for i := len(s.jobs) - 1; i >= 0 && (n <= 0 || len(out) < n); i-- {
+		if chatID != 0 && s.jobs[i].ChatID != chatID {
+			continue
+		}
+		out = append(out, s.jobs[i])
+	}
+	return out
+}
+
+func (s *Store) pruneLocked() {
+	if s.limit <= 0 || len(s.jobs) <= s.limit {
+		return
+	}
+	drop := len(s.jobs) - s.limit
+	for _, j := range s.jobs[:drop] {
+		_ = os.Remove(s.outputPath(j.ID))
+	}
+	s.jobs = append([]Job(nil), s.jobs[drop:]...)
+}
+
+func (s *Store) saveLocked() error {
+	data, err := json.MarshalIndent(s.jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	tmp := s.indexPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.indexPath())
+}
+
+func (s *Store) indexPath() string {
+	return filepath.Join(s.dir, "jobs.json")
+}
+
+func (s *Store) outputPath(id int) string {
+	return filepath.Join(s.dir, strconv.Itoa(id)+".out")
+}
@@ -0,0 +1,64 @@
+package history
+
+import (
+	"testing"
+)
+
+func TestStoreRecordsAndPrunes(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir, 2)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	first, err := store.Start(Job{ChatID: 1, Trace: "update_id=1", Prompt: "hello"})
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	first.Status = StatusOK
+	if err := store.Finish(first, "hi there"); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+	got, ok := store.Get(first.ID)
+	if !ok || got.Status != StatusOK || got.OutputBytes != len("hi there") || got.End.IsZero() {
+		t.Fatalf("unexpected job: %+v", got)
+	}
+	if output, err := store.Output(first.ID); err != nil || output != "hi there" {
+		t.Fatalf("unexpected output %q err=%v", output, err)
+	}
+
+	// Left running, then two more jobs push the first one out.
+	running, _ := store.Start(Job{ChatID: 2, Trace: "update_id=2"})
+	if _, err := store.Start(Job{ChatID: 1, Trace: "update_id=3"}); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if _, ok := store.Get(first.ID); ok {
+		t.Fatalf("expected oldest job to be pruned")
+	}
+	if _, err := store.Output(first.ID); err == nil {
+		t.Fatalf("expected pruned output to be removed")
+	}
+	if recent := store.Recent(1, 0); len(recent) != 1 || recent[0].Trace != "update_id=3" {
+		t.Fatalf("unexpected recent jobs: %+v", recent)
+	}
+
+	inspected, err := Read(dir)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if got, _ := inspected.Get(running.ID); got.Status != StatusRunning {
+		t.Fatalf("expected Read to leave running jobs alone, got %q", got.Status)
+	}
+
+	reopened, err := Open(dir, 2)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if got, _ := reopened.Get(running.ID); got.Status != StatusInterrupted {
+		t.Fatalf("expected running job to be marked interrupted, got %q", got.Status)
+	}
+	next, _ := reopened.Start(Job{ChatID: 1})
+	if next.ID != 4 {
+		t.Fatalf("expected ids to continue after reopen, got %d", next.ID)
+	}
+}
@@ -0,0 +1,95 @@
+// Package instance keeps two enoch processes from running in the same
+// working directory.
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// FileName is the lock file created in the working directory.
+const FileName = ".enoch.lock"
+
+// errLocked is returned by lockFile when another process holds the lock.
+var errLocked = errors.New("instance lock is held")
+
+// LockedError reports a lock held by a running process.
+type LockedError struct {
+	Path string
+	// PID is the owner's pid, 0 when the lock file could not be read.
+	PID int
+}
+
+func (e *LockedError) Error() string {
+	if e.PID == 0 {
+		return fmt.Sprintf("another enoch is running (lock %s); stop it first", e.Path)
+	}
+	return fmt.Sprintf("another enoch is running (pid %d, lock %s); stop it first", e.PID, e.Path)
+}
+
+// Lock is a held instance lock.
+type Lock struct {
+	path string
+	file *os.File
+}
+
+// Acquire locks the lock file in dir and writes the current PID to it. The
+// lock is held by the open file, so the kernel releases it when the process
+// exits, even on a crash; a file left behind is not a held lock.
+func Acquire(dir string) (*Lock, error) {
+	path := filepath.Join(dir, FileName)
+	file, err := lockFile(path)
+	if err == errLocked {
+		owner, _ := readPID(path)
+		return nil, &LockedError{Path: path, PID: owner}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := writePID(file); err != nil {
+		_ = unlockFile(file, path)
+		return nil, err
+	}
+	return &Lock{path: path, file: file}, nil
+}
+
+// Path returns the lock file path.
+func (l *Lock) Path() string {
+	return l.path
+}
+
+// Release removes the lock file and releases the lock.
+func (l *Lock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	err := unlockFile(l.file, l.path)
+	l.file = nil
+	return err
+}
+
+func writePID(file *os.File) error {
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+func readPID(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("instance lock %s: invalid pid %q", path, strings.TrimSpace(string(data)))
+	}
+	return pid, nil
+}
@@ -0,0 +1,78 @@
+package instance
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestAcquireAndRelease(t *testing.T) {
+	dir := t.TempDir()
+	lock, err := Acquire(dir)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil || string(data) != strconv.Itoa(os.Getpid())+"\n" {
+		t.Fatalf("unexpected lock content %q err=%v", data, err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if _, err := os.Stat(lock.Path()); !os.IsNotExist(err) {
+		t.Fatalf("expected lock file removed, got %v", err)
+	}
+}
+
+func TestAcquireRejectsHeldLock(t *testing.T) {
+	dir := t.TempDir()
+	lock, err := Acquire(dir)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	defer lock.Release()
+
+	_, err = Acquire(dir)
+	var locked *LockedError
+	if !errors.As(err, &locked) || locked.PID != os.Getpid() {
+		t.Fatalf("expected LockedError for pid %d, got %v", os.Getpid(), err)
+	}
+}
+
+func TestAcquireIgnoresLeftoverFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+	for _, content := range []string{"1", "garbage", ""} {
+		// A file nobody holds, as left by a crashed process.
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		lock, err := Acquire(dir)
+		if err != nil {
+			t.Fatalf("expected leftover lock %q to be taken over: %v", content, err)
+		}
+		if err := lock.Release(); err != nil {
+			t.Fatalf("release: %v", err)
+		}
+	}
+}
+
+func TestAcquireAfterRelease(t *testing.T) {
+	dir := t.TempDir()
+	first, err := Acquire(dir)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if err := first.Release(); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	second, err := Acquire(dir)
+	if err != nil {
+		t.Fatalf("expected the lock to be free after release: %v", err)
+	}
+	if err := second.Release(); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+//go:build !windows
+// +build !windows
+
+package instance
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockFile opens path and takes an exclusive flock on it.
+func lockFile(path string) (*os.File, error) {
+	for attempt := 0; attempt < 3; attempt++ {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			_ = file.Close()
+			if err == syscall.EWOULDBLOCK {
+				return nil, errLocked
+			}
+			return nil, err
+		}
+		// The previous owner may have removed the file between our open
+		// and flock; a lock on a removed file guards nothing.
+		var held, current syscall.Stat_t
+		if syscall.Fstat(int(file.Fd()), &held) == nil && syscall.Stat(path, &current) == nil &&
+			held.Dev == current.Dev && held.Ino == current.Ino {
+			return file, nil
+		}
+		_ = file.Close()
+	}
+	return nil, fmt.Errorf("instance lock: %s keeps being replaced", path)
+}
+
+// unlockFile removes the file while still holding the lock, so nobody
+// locks it in between, then closes it.
+func unlockFile(file *os.File, path string) error {
+	err := os.Remove(path)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
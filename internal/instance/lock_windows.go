@@ -0,0 +1,39 @@
+//go:build windows
+// +build windows
+
+package instance
+
+import (
+	"os"
+	"syscall"
+)
+
+// errorSharingViolation is ERROR_SHARING_VIOLATION.
+const errorSharingViolation syscall.Errno = 32
+
+// lockFile opens path without write sharing, so no other process can open
+// it for writing while it is held. Others may still read the PID.
+func lockFile(path string) (*os.File, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE, syscall.FILE_SHARE_READ, nil, syscall.OPEN_ALWAYS, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err == errorSharingViolation {
+		return nil, errLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(handle), path), nil
+}
+
+// unlockFile closes the file, then removes it. Another process that locked
+// it in between keeps it open, so the removal fails and is ignored.
+func unlockFile(file *os.File, path string) error {
+	if err := file.Close(); err != nil {
+		return err
+	}
+	_ = os.Remove(path)
+	return nil
+}
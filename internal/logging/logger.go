@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"enoch/internal/config"
+	"enoch/internal/redact"
 )
 
 type Level int
@@ -27,6 +28,8 @@ type Logger struct {
 	consoleOut io.Writer
 	fileOut    io.Writer
 	file       *os.File
+	filePath   string
+	redactor   *redact.Redactor
 	mu         sync.Mutex
 }
 
@@ -55,12 +58,54 @@ func New(cfg config.Config) (*Logger, error) {
 		consoleOut: os.Stdout,
 		fileOut:    fileOut,
 		file:       file,
+		filePath:   cfg.LogFile,
+		redactor:   cfg.Redactor(),
 	}
 	return logger, nil
 }
 
+// Update applies new logging settings in place, reopening the log file
+// when its path changed.
+func (l *Logger) Update(cfg config.Config) error {
+	lvl, err := parseLevel(cfg.LogLevel)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if cfg.LogFile != l.filePath {
+		var file *os.File
+		if cfg.LogFile != "" {
+			file, err = os.OpenFile(cfg.LogFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+			if err != nil {
+				return err
+			}
+		}
+		if l.file != nil {
+			_ = l.file.Close()
+		}
+		l.file = file
+		l.fileOut = nil
+		if file != nil {
+			l.fileOut = file
+		}
+		l.filePath = cfg.LogFile
+	}
+	l.level = lvl
+	l.console = cfg.LogConsole
+	l.color = cfg.LogColor
+	l.timeFormat = cfg.LogTimeFormat
+	l.redactor.Update(cfg.Secrets(), cfg.RedactPattern)
+	return nil
+}
+
 func (l *Logger) Close() error {
-	if l == nil || l.file == nil {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
 		return nil
 	}
 	return l.file.Close()
@@ -86,19 +131,19 @@ func (l *Logger) log(level Level, format string, args ...interface{}) {
 	if l == nil {
 		return
 	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	if level < l.level {
 		return
 	}
 
 	timestamp := time.Now().Format(l.timeFormat)
-	message := fmt.Sprintf(format, args...)
+	message := l.redactor.String(fmt.Sprintf(format, args...))
 	levelText := level.String()
 
 	line := fmt.Sprintf("%s [%s] %s", timestamp, levelText, message)
 
-	l.mu.Lock()
-	defer l.mu.Unlock()
-
 	if l.console {
 		if l.color {
 			colored := fmt.Sprintf("%s [%s%s%s] %s", timestamp, level.colorCode(), levelText, colorReset, message)
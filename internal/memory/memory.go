@@ -45,14 +45,24 @@ func (m *Manager) TodayDate() string {
 }
 
 func (m *Manager) TodayFilePath() string {
-	return filepath.Join(m.MemoryDir, m.TodayDate()+".md")
+	return m.filePath(m.TodayDate())
+}
+
+func (m *Manager) filePath(date string) string {
+	return filepath.Join(m.MemoryDir, date+".md")
 }
 
 func (m *Manager) EnsureTodayFile() (string, error) {
+	return m.ensureFile(m.TodayDate())
+}
+
+// ensureFile creates the memory file of a date (2006-01-02) from the
+// template if it does not exist yet.
+func (m *Manager) ensureFile(date string) (string, error) {
 	if err := os.MkdirAll(m.MemoryDir, 0o755); err != nil {
 		return "", err
 	}
-	path := m.TodayFilePath()
+	path := m.filePath(date)
 	if _, err := os.Stat(path); err == nil {
 		return path, nil
 	} else if !os.IsNotExist(err) {
@@ -62,7 +72,7 @@ func (m *Manager) EnsureTodayFile() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	content := strings.ReplaceAll(template, "{{date}}", m.TodayDate())
+	content := strings.ReplaceAll(template, "{{date}}", date)
 	content = strings.TrimRight(content, "\n") + "\n"
 	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
 		return "", err
@@ -85,7 +95,35 @@ func (m *Manager) AddEntry(message string) (string, error) {
 	}
 	timestamp := m.Now().Format("15:04")
 	entry := fmt.Sprintf("- [%s] %s", timestamp, message)
-	updated := insertIntoContext(string(content), entry)
+	updated := insertIntoSection(string(content), "## Context", entry)
+	if err := os.WriteFile(path, []byte(updated), 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// AddTodo inserts an unchecked item at the top of today's TODOs section.
+func (m *Manager) AddTodo(message string) (string, error) {
+	return m.AddTodoOn(m.Now(), message)
+}
+
+// AddTodoOn inserts an unchecked item at the top of the TODOs section of
+// the memory file for day.
+func (m *Manager) AddTodoOn(day time.Time, message string) (string, error) {
+	message = strings.TrimSpace(message)
+	if message == "" {
+		return "", fmt.Errorf("message is empty")
+	}
+	path, err := m.ensureFile(day.Format("2006-01-02"))
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	entry := fmt.Sprintf("- [ ] %s", message)
+	updated := insertIntoSection(string(content), "## TODOs", entry)
 	if err := os.WriteFile(path, []byte(updated), 0o644); err != nil {
 		return "", err
 	}
@@ -196,9 +234,8 @@ func parseTemplate(text string) string {
 	return strings.Trim(template, "\n")
 }
 
-func insertIntoContext(text, entry string) string {
+func insertIntoSection(text, header, entry string) string {
 	lines := strings.Split(text, "\n")
-	header := "## Context"
 	for i, line := range lines {
 		if strings.TrimSpace(line) == header {
 			insertAt := i + 1
@@ -86,3 +86,46 @@ func TestTodaySummaryLines(t *testing.T) {
 		t.Fatalf("unexpected lines: %+v", lines)
 	}
 }
+
+func TestAddTodoInsertsIntoTodos(t *testing.T) {
+	root := t.TempDir()
+	manager := NewManager(root)
+	manager.Now = func() time.Time { return time.Date(2026, 2, 3, 9, 0, 0, 0, time.UTC) }
+
+	path, err := manager.AddTodo("call the bank (2026-02-04 09:00)")
+	if err != nil {
+		t.Fatalf("add todo: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+	content := string(data)
+	todos := strings.Index(content, "## TODOs")
+	entry := strings.Index(content, "- [ ] call the bank (2026-02-04 09:00)")
+	next := strings.Index(content, "## Context")
+	if todos < 0 || entry < todos || entry > next {
+		t.Fatalf("todo not inside TODOs section: %s", content)
+	}
+}
+
+func TestAddTodoOnUsesTheDayFile(t *testing.T) {
+	root := t.TempDir()
+	manager := NewManager(root)
+	manager.Now = func() time.Time { return time.Date(2026, 2, 3, 9, 0, 0, 0, time.UTC) }
+
+	path, err := manager.AddTodoOn(time.Date(2026, 2, 4, 9, 0, 0, 0, time.UTC), "call the bank")
+	if err != nil {
+		t.Fatalf("add todo: %v", err)
+	}
+	if filepath.Base(path) != "2026-02-04.md" {
+		t.Fatalf("expected the reminder day's file, got %s", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "# 2026-02-04") || !strings.Contains(string(data), "- [ ] call the bank") {
+		t.Fatalf("unexpected content: %s", data)
+	}
+}
@@ -0,0 +1,105 @@
+// Package redact masks secrets in text before it is logged or sent to a
+// chat.
+package redact
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask replaces every redacted value.
+const Mask = "***"
+
+// minSecretLen keeps short values, which would match ordinary text, out of
+// the secret list.
+const minSecretLen = 8
+
+// sensitiveSegments are the "_"-separated name parts that mark a variable
+// as holding a secret, e.g. CODEX_API_KEY or AUTH_TOKEN. Whole segments are
+// compared so that GIT_AUTHOR_NAME or KEYBOARD_LAYOUT do not match.
+var sensitiveSegments = map[string]bool{
+	"TOKEN": true, "KEY": true, "APIKEY": true, "SECRET": true, "AUTH": true,
+	"PASSWD": true, "PASSPHRASE": true, "CREDENTIAL": true, "CREDENTIALS": true,
+}
+
+// locationSegments end the names of variables that point at a secret, such
+// as SSH_AUTH_SOCK or CODEX_API_KEY_FILE, rather than holding one.
+var locationSegments = map[string]bool{
+	"SOCK": true, "SOCKET": true, "FILE": true, "PATH": true, "DIR": true, "HOME": true,
+}
+
+// IsSensitive reports whether a variable name looks like it holds a secret.
+func IsSensitive(name string) bool {
+	upper := strings.ToUpper(name)
+	segments := strings.FieldsFunc(upper, func(r rune) bool { return r == '_' || r == '.' || r == '-' })
+	if len(segments) == 0 || locationSegments[segments[len(segments)-1]] {
+		return false
+	}
+	if strings.Contains(upper, "PASSWORD") {
+		return true
+	}
+	for _, segment := range segments {
+		if sensitiveSegments[segment] {
+			return true
+		}
+	}
+	return false
+}
+
+// Redactor masks known secret values and text matching a pattern. The zero
+// value and a nil *Redactor mask nothing.
+type Redactor struct {
+	mu      sync.RWMutex
+	secrets []string
+	pattern *regexp.Regexp
+}
+
+// New returns a Redactor for the given secrets and optional pattern. An
+// invalid pattern is ignored; config.Load has already rejected it.
+func New(secrets []string, pattern string) *Redactor {
+	r := &Redactor{}
+	r.Update(secrets, pattern)
+	return r
+}
+
+// Update replaces the secrets and pattern.
+func (r *Redactor) Update(secrets []string, pattern string) {
+	var kept []string
+	seen := map[string]bool{}
+	for _, secret := range secrets {
+		if len(secret) >= minSecretLen && !seen[secret] {
+			seen[secret] = true
+			kept = append(kept, secret)
+		}
+	}
+	// Longer secrets first, so one containing another is masked whole.
+	sort.Slice(kept, func(i, j int) bool { return len(kept[i]) > len(kept[j]) })
+	var compiled *regexp.Regexp
+	if pattern != "" {
+		compiled, _ = regexp.Compile(pattern)
+	}
+	r.mu.Lock()
+	r.secrets = kept
+	r.pattern = compiled
+	r.mu.Unlock()
+}
+
+// String returns s with secrets masked.
+func (r *Redactor) String(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, secret := range r.secrets {
+		if strings.Contains(s, secret) {
+			s = strings.ReplaceAll(s, secret, Mask)
+		}
+	}
+	if r.pattern != nil {
+		s = r.pattern.ReplaceAllString(s, Mask)
+	}
+	return s
+}
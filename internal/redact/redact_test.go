@@ -0,0 +1,45 @@
+package redact
+
+import "testing"
+
+func TestRedactorMasksSecretsAndPattern(t *testing.T) {
+	r := New([]string{"123456:ABCDEFGH", "short", "sk-abcdefgh"}, `ghp_[A-Za-z0-9]+`)
+	in := "post https://api.telegram.org/bot123456:ABCDEFGH/getUpdates key=sk-abcdefgh gh=ghp_xyz short"
+	want := "post https://api.telegram.org/bot***/getUpdates key=*** gh=*** short"
+	if got := r.String(in); got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	r.Update(nil, "")
+	if got := r.String(in); got != in {
+		t.Fatalf("expected no masking after update, got %q", got)
+	}
+	var nilRedactor *Redactor
+	if got := nilRedactor.String(in); got != in {
+		t.Fatalf("expected nil redactor to pass text through")
+	}
+}
+
+func TestIsSensitive(t *testing.T) {
+	for name, want := range map[string]bool{
+		"CODEX_API_KEY":      true,
+		"TELEGRAM_BOT_TOKEN": true,
+		"db_password":        true,
+		"PATH":               false,
+		"CODEX_HOME":         false,
+		"AUTH_TOKEN":         true,
+		"aws.secret":         true,
+		"MYSQLPASSWORD":      true,
+		"GIT_AUTHOR_NAME":    false,
+		"GIT_AUTHOR_EMAIL":   false,
+		"SSH_AUTH_SOCK":      false,
+		"XAUTHORITY":         false,
+		"KEYBOARD_LAYOUT":    false,
+		"CODEX_API_KEY_FILE": false,
+		"MAX_TOKENS":         false,
+	} {
+		if got := IsSensitive(name); got != want {
+			t.Fatalf("%s: expected %t", name, want)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Spec computes the next activation time after a given instant.
+// A zero time means the spec will never fire again.
+type Spec interface {
+	Next(after time.Time) time.Time
+}
+
+type cronSpec struct {
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	// domStar/dowStar follow the classic cron rule: when both day fields are
+	// restricted, a day matches if either of them matches.
+	domStar bool
+	dowStar bool
+}
+
+type onceSpec struct {
+	at time.Time
+}
+
+type fieldRange struct {
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	minuteRange = fieldRange{min: 0, max: 59}
+	hourRange   = fieldRange{min: 0, max: 23}
+	domRange    = fieldRange{min: 1, max: 31}
+	monthRange  = fieldRange{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowRange = fieldRange{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseSpec parses a schedule specification: a five-field cron expression,
+// a cron macro such as @daily, or a one-shot time ("2006-01-02 15:04" or
+// "15:04", meaning the next occurrence of that time of day).
+func ParseSpec(spec string, now time.Time) (Spec, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("empty schedule")
+	}
+	if at, ok := parseOnce(spec, now); ok {
+		return onceSpec{at: at}, nil
+	}
+	return ParseCron(spec)
+}
+
+// ParseCron parses a standard five-field cron expression
+// (minute hour day-of-month month day-of-week) or a cron macro.
+func ParseCron(expr string) (Spec, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression must have 5 fields, got %d", len(fields))
+	}
+
+	var spec cronSpec
+	var err error
+	if spec.minute, err = parseField(fields[0], minuteRange); err != nil {
+		return nil, fmt.Errorf("minute: %w", err)
+	}
+	if spec.hour, err = parseField(fields[1], hourRange); err != nil {
+		return nil, fmt.Errorf("hour: %w", err)
+	}
+	if spec.dom, err = parseField(fields[2], domRange); err != nil {
+		return nil, fmt.Errorf("day of month: %w", err)
+	}
+	if spec.month, err = parseField(fields[3], monthRange); err != nil {
+		return nil, fmt.Errorf("month: %w", err)
+	}
+	if spec.dow, err = parseField(fields[4], dowRange); err != nil {
+		return nil, fmt.Errorf("day of week: %w", err)
+	}
+	// Both 0 and 7 mean Sunday.
+	if spec.dow&(1<<7) != 0 {
+		spec.dow |= 1
+	}
+	spec.domStar = fields[2] == "*" || fields[2] == "?"
+	spec.dowStar = fields[4] == "*" || fields[4] == "?"
+	return spec, nil
+}
+
+func parseField(field string, r fieldRange) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		if part == "" {
+			return 0, fmt.Errorf("empty list item in %q", field)
+		}
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			parsed, err := strconv.Atoi(part[idx+1:])
+			if err != nil || parsed <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			step = parsed
+			part = part[:idx]
+		}
+
+		var lo, hi int
+		switch {
+		case part == "*" || part == "?":
+			lo, hi = r.min, r.max
+		case strings.Contains(part, "-"):
+			idx := strings.Index(part, "-")
+			var err error
+			if lo, err = parseValue(part[:idx], r); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(part[idx+1:], r); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			value, err := parseValue(part, r)
+			if err != nil {
+				return 0, err
+			}
+			lo, hi = value, value
+			if step > 1 {
+				hi = r.max
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(text string, r fieldRange) (int, error) {
+	if r.names != nil {
+		if value, ok := r.names[strings.ToLower(text)]; ok {
+			return value, nil
+		}
+	}
+	value, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", text)
+	}
+	if value < r.min || value > r.max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", value, r.min, r.max)
+	}
+	return value, nil
+}
+
+// Next returns the first minute strictly after the given time matching the
+// expression, or the zero time if none exists within five years.
+func (c cronSpec) Next(after time.Time) time.Time {
+	loc := after.Location()
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (c cronSpec) dayMatches(t time.Time) bool {
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+func (o onceSpec) Next(after time.Time) time.Time {
+	if o.at.After(after) {
+		return o.at
+	}
+	return time.Time{}
+}
+
+func parseOnce(spec string, now time.Time) (time.Time, bool) {
+	loc := now.Location()
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02 15:04:05"} {
+		if at, err := time.ParseInLocation(layout, spec, loc); err == nil {
+			return at, true
+		}
+	}
+	if clock, err := time.ParseInLocation("15:04", spec, loc); err == nil {
+		at := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+		return at, true
+	}
+	return time.Time{}, false
+}
+
+// IsOnce reports whether the spec fires a single time.
+func IsOnce(spec Spec) bool {
+	_, ok := spec.(onceSpec)
+	return ok
+}
+
+// SplitSpec separates the schedule spec from the prompt in command text such
+// as `"0 9 * * 1-5" summarize` or `@daily summarize`. Quoted specs are taken
+// verbatim; otherwise it tries a five-field cron expression, a dated time and
+// a time of day in that order.
+func SplitSpec(text string, now time.Time) (string, string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", "", fmt.Errorf("empty schedule")
+	}
+	if quote := text[0]; quote == '"' || quote == '\'' {
+		end := strings.IndexByte(text[1:], quote)
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated quote")
+		}
+		return text[1 : end+1], strings.TrimSpace(text[end+2:]), nil
+	}
+
+	fields := strings.Fields(text)
+	if strings.HasPrefix(fields[0], "@") {
+		return fields[0], strings.TrimSpace(strings.Join(fields[1:], " ")), nil
+	}
+	if len(fields) >= 5 {
+		candidate := strings.Join(fields[:5], " ")
+		if _, err := ParseCron(candidate); err == nil {
+			return candidate, strings.Join(fields[5:], " "), nil
+		}
+	}
+	if len(fields) >= 2 {
+		candidate := fields[0] + " " + fields[1]
+		if _, ok := parseOnce(candidate, now); ok {
+			return candidate, strings.Join(fields[2:], " "), nil
+		}
+	}
+	if _, ok := parseOnce(fields[0], now); ok {
+		return fields[0], strings.Join(fields[1:], " "), nil
+	}
+	return "", "", fmt.Errorf("unrecognized schedule %q", fields[0])
+}
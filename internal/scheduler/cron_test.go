@@ -0,0 +1,103 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNextWeekdays(t *testing.T) {
+	spec, err := ParseCron("0 9 * * 1-5")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	// Friday 2026-02-06 10:00 -> Monday 2026-02-09 09:00.
+	after := time.Date(2026, 2, 6, 10, 0, 0, 0, time.UTC)
+	got := spec.Next(after)
+	want := time.Date(2026, 2, 9, 9, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Fatalf("next mismatch: got %s want %s", got, want)
+	}
+}
+
+func TestCronNextStepsAndMacros(t *testing.T) {
+	spec, err := ParseCron("*/15 * * * *")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	after := time.Date(2026, 2, 3, 8, 7, 30, 0, time.UTC)
+	if got := spec.Next(after); !got.Equal(time.Date(2026, 2, 3, 8, 15, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected next: %s", got)
+	}
+
+	daily, err := ParseCron("@daily")
+	if err != nil {
+		t.Fatalf("parse macro: %v", err)
+	}
+	if got := daily.Next(after); !got.Equal(time.Date(2026, 2, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected daily next: %s", got)
+	}
+}
+
+func TestCronDayFieldsUseOr(t *testing.T) {
+	spec, err := ParseCron("0 0 1 * mon")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	// 2026-02-03 is a Tuesday; the next Monday (02-09) comes before 03-01.
+	after := time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC)
+	if got := spec.Next(after); !got.Equal(time.Date(2026, 2, 9, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected next: %s", got)
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{"* * * *", "60 * * * *", "* * * * 8", "5-1 * * * *", "*/0 * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Fatalf("expected error for %q", expr)
+		}
+	}
+}
+
+func TestParseSpecOnce(t *testing.T) {
+	now := time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)
+	spec, err := ParseSpec("09:30", now)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if !IsOnce(spec) {
+		t.Fatalf("expected once spec")
+	}
+	if got := spec.Next(now); !got.Equal(time.Date(2026, 2, 4, 9, 30, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected next: %s", got)
+	}
+	if got := spec.Next(time.Date(2026, 2, 5, 0, 0, 0, 0, time.UTC)); !got.IsZero() {
+		t.Fatalf("expected once spec to expire, got %s", got)
+	}
+}
+
+func TestSplitSpec(t *testing.T) {
+	now := time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)
+	cases := []struct {
+		input  string
+		spec   string
+		prompt string
+	}{
+		{`"0 9 * * 1-5" summarize yesterday's memory`, "0 9 * * 1-5", "summarize yesterday's memory"},
+		{"0 9 * * 1-5 summarize", "0 9 * * 1-5", "summarize"},
+		{"@hourly check mail", "@hourly", "check mail"},
+		{"2026-02-04 08:00 ship it", "2026-02-04 08:00", "ship it"},
+		{"18:00 wrap up", "18:00", "wrap up"},
+	}
+	for _, tc := range cases {
+		spec, prompt, err := SplitSpec(tc.input, now)
+		if err != nil {
+			t.Fatalf("split %q: %v", tc.input, err)
+		}
+		if spec != tc.spec || prompt != tc.prompt {
+			t.Fatalf("split %q: got (%q, %q)", tc.input, spec, prompt)
+		}
+	}
+	if _, _, err := SplitSpec("tomorrow do it", now); err == nil {
+		t.Fatalf("expected error for unknown spec")
+	}
+}
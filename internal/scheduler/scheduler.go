@@ -0,0 +1,392 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"enoch/internal/logging"
+)
+
+const maxSleep = 30 * time.Second
+
+// onceRetry is how long a one-shot entry waits before it fires again when
+// it could not be delivered.
+const onceRetry = time.Minute
+
+// Entry kinds. Prompts run through the agent; reminders are delivered as-is.
+const (
+	KindPrompt   = "prompt"
+	KindReminder = "reminder"
+)
+
+// Entry is a persisted prompt or reminder registered by a chat.
+type Entry struct {
+	ID        int       `json:"id"`
+	ChatID    int64     `json:"chat_id"`
+	Kind      string    `json:"kind,omitempty"`
+	Spec      string    `json:"spec"`
+	Prompt    string    `json:"prompt"`
+	Once      bool      `json:"once,omitempty"`
+	Paused    bool      `json:"paused,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	NextRun   time.Time `json:"next_run"`
+	LastRun   time.Time `json:"last_run,omitempty"`
+}
+
+// EntryKind returns the entry kind, treating entries saved before kinds
+// existed as prompts.
+func (e Entry) EntryKind() string {
+	if e.Kind == "" {
+		return KindPrompt
+	}
+	return e.Kind
+}
+
+type fileState struct {
+	NextID  int      `json:"next_id"`
+	Entries []*Entry `json:"entries"`
+}
+
+// Scheduler keeps scheduled prompts on disk and fires them when due.
+type Scheduler struct {
+	Now func() time.Time
+
+	path    string
+	logger  *logging.Logger
+	mu      sync.Mutex
+	nextID  int
+	entries []*Entry
+	wake    chan struct{}
+}
+
+// New loads the schedule file at path (a missing file is not an error).
+func New(path string, logger *logging.Logger) (*Scheduler, error) {
+	s := &Scheduler{
+		Now:    time.Now,
+		path:   path,
+		logger: logger,
+		nextID: 1,
+		wake:   make(chan struct{}, 1),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add registers a new prompt for the chat.
+func (s *Scheduler) Add(chatID int64, spec, prompt string) (Entry, error) {
+	prompt = strings.TrimSpace(prompt)
+	if prompt == "" {
+		return Entry{}, fmt.Errorf("prompt is empty")
+	}
+	now := s.Now()
+	parsed, err := ParseSpec(spec, now)
+	if err != nil {
+		return Entry{}, err
+	}
+	next := parsed.Next(now)
+	if next.IsZero() {
+		return Entry{}, fmt.Errorf("schedule %q never fires", spec)
+	}
+
+	entry := &Entry{
+		ChatID:    chatID,
+		Kind:      KindPrompt,
+		Spec:      strings.TrimSpace(spec),
+		Prompt:    prompt,
+		Once:      IsOnce(parsed),
+		CreatedAt: now,
+		NextRun:   next,
+	}
+	if entry.Once {
+		// Store the absolute time so a restart does not shift "09:00" by a day.
+		entry.Spec = next.Format("2006-01-02 15:04")
+	}
+
+	return s.insert(entry)
+}
+
+// AddReminder registers a one-shot reminder delivered at the given time.
+func (s *Scheduler) AddReminder(chatID int64, at time.Time, text string) (Entry, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Entry{}, fmt.Errorf("reminder text is empty")
+	}
+	now := s.Now()
+	if !at.After(now) {
+		return Entry{}, fmt.Errorf("reminder time %s is in the past", at.Format("2006-01-02 15:04"))
+	}
+	return s.insert(&Entry{
+		ChatID:    chatID,
+		Kind:      KindReminder,
+		Spec:      at.Format("2006-01-02 15:04"),
+		Prompt:    text,
+		Once:      true,
+		CreatedAt: now,
+		NextRun:   at,
+	})
+}
+
+func (s *Scheduler) insert(entry *Entry) (Entry, error) {
+	s.mu.Lock()
+	entry.ID = s.nextID
+	s.nextID++
+	s.entries = append(s.entries, entry)
+	err := s.saveLocked()
+	s.mu.Unlock()
+	if err != nil {
+		return Entry{}, err
+	}
+	s.notify()
+	return *entry, nil
+}
+
+// List returns the chat's entries of the given kind ordered by id.
+// A chatID of 0 matches all chats and an empty kind matches all kinds.
+func (s *Scheduler) List(chatID int64, kind string) []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Entry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		if chatID != 0 && entry.ChatID != chatID {
+			continue
+		}
+		if kind != "" && entry.EntryKind() != kind {
+			continue
+		}
+		out = append(out, *entry)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// SetPaused pauses or resumes an entry owned by the chat.
+func (s *Scheduler) SetPaused(chatID int64, id int, paused bool) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.findLocked(chatID, id)
+	if entry == nil {
+		return Entry{}, fmt.Errorf("schedule %d not found", id)
+	}
+	entry.Paused = paused
+	if !paused && !entry.Once {
+		if next, err := s.nextRun(entry, s.Now()); err == nil {
+			entry.NextRun = next
+		}
+	}
+	if err := s.saveLocked(); err != nil {
+		return Entry{}, err
+	}
+	s.notify()
+	return *entry, nil
+}
+
+// Remove deletes an entry of the given kind owned by the chat. An empty
+// kind matches all kinds.
+func (s *Scheduler) Remove(chatID int64, id int, kind string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, entry := range s.entries {
+		if entry.ID == id && entry.ChatID == chatID && (kind == "" || entry.EntryKind() == kind) {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			return s.saveLocked()
+		}
+	}
+	return fmt.Errorf("schedule %d not found", id)
+}
+
+// Run fires due entries forever. fire reports whether the entry was
+// delivered. One-shot entries are removed once delivered and retried after
+// onceRetry otherwise; recurring ones are rescheduled from the current time,
+// so runs missed while the process was down are skipped rather than
+// replayed.
+func (s *Scheduler) Run(fire func(Entry) bool) {
+	for {
+		s.fireDue(fire)
+
+		wait := s.untilNext()
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-s.wake:
+			timer.Stop()
+		}
+	}
+}
+
+func (s *Scheduler) fireDue(fire func(Entry) bool) {
+	for _, entry := range s.takeDue() {
+		if s.logger != nil {
+			s.logger.Infof("schedule fired: schedule_id=%d chat_id=%d kind=%s spec=%q", entry.ID, entry.ChatID, entry.EntryKind(), entry.Spec)
+		}
+		delivered := fire(entry)
+		if !entry.Once {
+			continue
+		}
+		if delivered {
+			s.finishOnce(entry.ID)
+		} else if s.logger != nil {
+			s.logger.Warnf("schedule retry: schedule_id=%d chat_id=%d in=%s", entry.ID, entry.ChatID, onceRetry)
+		}
+	}
+}
+
+// takeDue returns the entries due now. One-shot entries stay stored with a
+// retry time until finishOnce removes them, so one that is never delivered
+// (a full queue, a shutdown or a crash) fires again later.
+func (s *Scheduler) takeDue() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.Now()
+	var due []Entry
+	kept := s.entries[:0]
+	changed := false
+	for _, entry := range s.entries {
+		if entry.Paused || entry.NextRun.After(now) {
+			kept = append(kept, entry)
+			continue
+		}
+		due = append(due, *entry)
+		changed = true
+		if entry.Once {
+			entry.NextRun = now.Add(onceRetry)
+			kept = append(kept, entry)
+			continue
+		}
+		entry.LastRun = now
+		next, err := s.nextRun(entry, now)
+		if err != nil || next.IsZero() {
+			if s.logger != nil {
+				s.logger.Warnf("schedule dropped: schedule_id=%d spec=%q err=%v", entry.ID, entry.Spec, err)
+			}
+			continue
+		}
+		entry.NextRun = next
+		kept = append(kept, entry)
+	}
+	s.entries = kept
+	if changed {
+		if err := s.saveLocked(); err != nil && s.logger != nil {
+			s.logger.Errorf("schedule save failed: %v", err)
+		}
+	}
+	return due
+}
+
+// finishOnce removes a delivered one-shot entry.
+func (s *Scheduler) finishOnce(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, entry := range s.entries {
+		if entry.ID == id && entry.Once {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			if err := s.saveLocked(); err != nil && s.logger != nil {
+				s.logger.Errorf("schedule save failed: %v", err)
+			}
+			return
+		}
+	}
+}
+
+func (s *Scheduler) untilNext() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wait := maxSleep
+	now := s.Now()
+	for _, entry := range s.entries {
+		if entry.Paused {
+			continue
+		}
+		if d := entry.NextRun.Sub(now); d < wait {
+			wait = d
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+func (s *Scheduler) nextRun(entry *Entry, now time.Time) (time.Time, error) {
+	spec, err := ParseSpec(entry.Spec, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return spec.Next(now), nil
+}
+
+func (s *Scheduler) findLocked(chatID int64, id int) *Entry {
+	for _, entry := range s.entries {
+		if entry.ID == id && entry.ChatID == chatID {
+			return entry
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) load() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var state fileState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("parse %s: %w", s.path, err)
+	}
+	now := s.Now()
+	for _, entry := range state.Entries {
+		if entry == nil {
+			continue
+		}
+		if !entry.Once {
+			if next, err := s.nextRun(entry, now); err == nil && !next.IsZero() {
+				entry.NextRun = next
+			}
+		}
+		s.entries = append(s.entries, entry)
+		if entry.ID >= s.nextID {
+			s.nextID = entry.ID + 1
+		}
+	}
+	if state.NextID > s.nextID {
+		s.nextID = state.NextID
+	}
+	return nil
+}
+
+func (s *Scheduler) saveLocked() error {
+	state := fileState{NextID: s.nextID, Entries: s.entries}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,133 @@
+package scheduler
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSchedulerPersistsEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	now := time.Date(2026, 2, 3, 8, 0, 0, 0, time.UTC)
+
+	s, err := New(path, nil)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	s.Now = func() time.Time { return now }
+	entry, err := s.Add(42, "0 9 * * *", "summarize")
+	if err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if !entry.NextRun.Equal(time.Date(2026, 2, 3, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected next run: %s", entry.NextRun)
+	}
+	if _, err := s.SetPaused(42, entry.ID, true); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+
+	reloaded, err := New(path, nil)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	entries := reloaded.List(42, KindPrompt)
+	if len(entries) != 1 || !entries[0].Paused || entries[0].Prompt != "summarize" {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+	if _, err := reloaded.Add(42, "@daily", "again"); err != nil {
+		t.Fatalf("add after reload: %v", err)
+	}
+	if got := reloaded.List(42, KindPrompt); got[1].ID != entry.ID+1 {
+		t.Fatalf("expected ids to continue, got %+v", got)
+	}
+}
+
+func TestSchedulerTakeDue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	now := time.Date(2026, 2, 3, 8, 0, 0, 0, time.UTC)
+	s, err := New(path, nil)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	s.Now = func() time.Time { return now }
+
+	recurring, err := s.Add(1, "*/5 * * * *", "tick")
+	if err != nil {
+		t.Fatalf("add recurring: %v", err)
+	}
+	if _, err := s.Add(1, "08:02", "once"); err != nil {
+		t.Fatalf("add once: %v", err)
+	}
+	if _, err := s.Add(2, "08:03", "other chat"); err != nil {
+		t.Fatalf("add other: %v", err)
+	}
+	if err := s.Remove(1, 99, ""); err == nil {
+		t.Fatalf("expected remove of unknown id to fail")
+	}
+	if err := s.Remove(1, recurring.ID, KindReminder); err == nil {
+		t.Fatalf("expected remove of another kind to fail")
+	}
+
+	now = now.Add(4 * time.Minute)
+	var fired []Entry
+	s.fireDue(func(entry Entry) bool {
+		fired = append(fired, entry)
+		return true
+	})
+	if len(fired) != 2 {
+		t.Fatalf("expected 2 due entries, got %+v", fired)
+	}
+
+	entries := s.List(0, "")
+	if len(entries) != 1 || entries[0].ID != recurring.ID {
+		t.Fatalf("expected only recurring entry to remain, got %+v", entries)
+	}
+	if !entries[0].NextRun.Equal(time.Date(2026, 2, 3, 8, 5, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected next run: %s", entries[0].NextRun)
+	}
+}
+
+func TestSchedulerRetriesUndeliveredOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	now := time.Date(2026, 2, 3, 8, 0, 0, 0, time.UTC)
+	s, err := New(path, nil)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	s.Now = func() time.Time { return now }
+	entry, err := s.Add(1, "08:02", "once")
+	if err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	now = now.Add(3 * time.Minute)
+	fires := 0
+	s.fireDue(func(Entry) bool {
+		fires++
+		return false
+	})
+	reloaded, err := New(path, nil)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	entries := reloaded.List(1, KindPrompt)
+	if fires != 1 || len(entries) != 1 || !entries[0].NextRun.Equal(now.Add(onceRetry)) {
+		t.Fatalf("expected the entry to be kept for a retry, got fires=%d %+v", fires, entries)
+	}
+
+	s.fireDue(func(Entry) bool {
+		fires++
+		return true
+	})
+	if fires != 1 {
+		t.Fatalf("expected no fire before the retry time")
+	}
+	now = now.Add(onceRetry)
+	s.fireDue(func(got Entry) bool {
+		fires++
+		return got.ID == entry.ID
+	})
+	if fires != 2 || len(s.List(1, "")) != 0 {
+		t.Fatalf("expected the entry to be removed after delivery, got fires=%d %+v", fires, s.List(1, ""))
+	}
+}
@@ -0,0 +1,191 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultReminderHour is used when only a day ("tomorrow") is given.
+const defaultReminderHour = 9
+
+var relativeDays = map[string]int{
+	"today":    0,
+	"今天":       0,
+	"tomorrow": 1,
+	"明天":       1,
+	"后天":       2,
+}
+
+var durationUnits = map[string]time.Duration{
+	"s": time.Second, "sec": time.Second, "secs": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+}
+
+// ParseWhen reads a natural time expression from the start of text and
+// returns the resolved time plus the remaining text. Supported forms:
+// "in 2h", "in 1h30m", "in 10 minutes", "tomorrow 9:00", "today 18:30",
+// "明天 9:00", "at 18:00", "18:00", "9pm" and "2006-01-02 15:04".
+func ParseWhen(text string, now time.Time) (time.Time, string, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return time.Time{}, "", fmt.Errorf("missing time")
+	}
+	loc := now.Location()
+	rest := func(n int) string { return strings.Join(fields[n:], " ") }
+
+	head := strings.ToLower(fields[0])
+	switch {
+	case head == "in":
+		if len(fields) >= 3 {
+			if d, ok := parseAmountUnit(fields[1], fields[2]); ok {
+				return now.Add(d), rest(3), nil
+			}
+		}
+		if len(fields) >= 2 {
+			if d, ok := parseCompactDuration(fields[1]); ok {
+				return now.Add(d), rest(2), nil
+			}
+		}
+		return time.Time{}, "", fmt.Errorf("invalid relative time after %q", fields[0])
+	case head == "at":
+		if len(fields) >= 2 {
+			if hour, minute, ok := parseClock(fields[1]); ok {
+				return nextClock(now, hour, minute), rest(2), nil
+			}
+		}
+		return time.Time{}, "", fmt.Errorf("invalid time after %q", fields[0])
+	}
+
+	if offset, ok := relativeDays[head]; ok {
+		day := time.Date(now.Year(), now.Month(), now.Day()+offset, 0, 0, 0, 0, loc)
+		idx := 1
+		if idx < len(fields) && strings.ToLower(fields[idx]) == "at" {
+			idx++
+		}
+		if idx < len(fields) {
+			if hour, minute, ok := parseClock(fields[idx]); ok {
+				at := day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+				if !at.After(now) {
+					return time.Time{}, "", fmt.Errorf("time %s is in the past", at.Format("2006-01-02 15:04"))
+				}
+				return at, rest(idx + 1), nil
+			}
+		}
+		if offset == 0 {
+			return time.Time{}, "", fmt.Errorf("missing time of day after %q", fields[0])
+		}
+		return day.Add(defaultReminderHour * time.Hour), rest(1), nil
+	}
+
+	if len(fields) >= 2 {
+		if at, err := time.ParseInLocation("2006-01-02 15:04", fields[0]+" "+fields[1], loc); err == nil {
+			if !at.After(now) {
+				return time.Time{}, "", fmt.Errorf("time %s is in the past", at.Format("2006-01-02 15:04"))
+			}
+			return at, rest(2), nil
+		}
+	}
+	if hour, minute, ok := parseClock(fields[0]); ok {
+		return nextClock(now, hour, minute), rest(1), nil
+	}
+	return time.Time{}, "", fmt.Errorf("unrecognized time %q", fields[0])
+}
+
+func parseAmountUnit(amount, unit string) (time.Duration, bool) {
+	n, err := strconv.Atoi(amount)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	base, ok := durationUnits[strings.ToLower(unit)]
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(n) * base, true
+}
+
+// parseCompactDuration accepts "2h", "90m", "1h30m" and "3d".
+func parseCompactDuration(text string) (time.Duration, bool) {
+	text = strings.ToLower(text)
+	var total time.Duration
+	for text != "" {
+		i := 0
+		for i < len(text) && text[i] >= '0' && text[i] <= '9' {
+			i++
+		}
+		if i == 0 {
+			return 0, false
+		}
+		n, err := strconv.Atoi(text[:i])
+		if err != nil {
+			return 0, false
+		}
+		j := i
+		for j < len(text) && (text[j] < '0' || text[j] > '9') {
+			j++
+		}
+		base, ok := durationUnits[text[i:j]]
+		if !ok {
+			return 0, false
+		}
+		total += time.Duration(n) * base
+		text = text[j:]
+	}
+	return total, total > 0
+}
+
+// parseClock accepts "9:00", "09:30", "9am" and "6pm".
+func parseClock(text string) (int, int, bool) {
+	text = strings.ToLower(text)
+	suffix := ""
+	if strings.HasSuffix(text, "am") || strings.HasSuffix(text, "pm") {
+		suffix = text[len(text)-2:]
+		text = text[:len(text)-2]
+	}
+	hourText, minuteText := text, "0"
+	if idx := strings.Index(text, ":"); idx >= 0 {
+		hourText, minuteText = text[:idx], text[idx+1:]
+	} else if suffix == "" {
+		return 0, 0, false
+	}
+	hour, err := strconv.Atoi(hourText)
+	if err != nil {
+		return 0, 0, false
+	}
+	minute, err := strconv.Atoi(minuteText)
+	if err != nil || minute < 0 || minute > 59 {
+		return 0, 0, false
+	}
+	switch suffix {
+	case "am":
+		if hour < 1 || hour > 12 {
+			return 0, 0, false
+		}
+		if hour == 12 {
+			hour = 0
+		}
+	case "pm":
+		if hour < 1 || hour > 12 {
+			return 0, 0, false
+		}
+		if hour != 12 {
+			hour += 12
+		}
+	}
+	if hour < 0 || hour > 23 {
+		return 0, 0, false
+	}
+	return hour, minute, true
+}
+
+func nextClock(now time.Time, hour, minute int) time.Time {
+	at := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if !at.After(now) {
+		at = at.AddDate(0, 0, 1)
+	}
+	return at
+}
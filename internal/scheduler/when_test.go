@@ -0,0 +1,45 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWhen(t *testing.T) {
+	now := time.Date(2026, 2, 3, 10, 15, 0, 0, time.UTC)
+	cases := []struct {
+		input string
+		want  time.Time
+		rest  string
+	}{
+		{"in 2h call mom", now.Add(2 * time.Hour), "call mom"},
+		{"in 1h30m stretch", now.Add(90 * time.Minute), "stretch"},
+		{"in 10 minutes tea", now.Add(10 * time.Minute), "tea"},
+		{"tomorrow 9:00 standup", time.Date(2026, 2, 4, 9, 0, 0, 0, time.UTC), "standup"},
+		{"tomorrow at 18:30 gym", time.Date(2026, 2, 4, 18, 30, 0, 0, time.UTC), "gym"},
+		{"tomorrow pay rent", time.Date(2026, 2, 4, 9, 0, 0, 0, time.UTC), "pay rent"},
+		{"明天 8:00 开会", time.Date(2026, 2, 4, 8, 0, 0, 0, time.UTC), "开会"},
+		{"today 18:00 leave", time.Date(2026, 2, 3, 18, 0, 0, 0, time.UTC), "leave"},
+		{"9:00 next day", time.Date(2026, 2, 4, 9, 0, 0, 0, time.UTC), "next day"},
+		{"at 6pm dinner", time.Date(2026, 2, 3, 18, 0, 0, 0, time.UTC), "dinner"},
+		{"2026-03-01 12:00 lunch", time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), "lunch"},
+	}
+	for _, tc := range cases {
+		got, rest, err := ParseWhen(tc.input, now)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tc.input, err)
+		}
+		if !got.Equal(tc.want) || rest != tc.rest {
+			t.Fatalf("parse %q: got (%s, %q) want (%s, %q)", tc.input, got, rest, tc.want, tc.rest)
+		}
+	}
+}
+
+func TestParseWhenErrors(t *testing.T) {
+	now := time.Date(2026, 2, 3, 10, 15, 0, 0, time.UTC)
+	for _, input := range []string{"", "in", "in soon", "today 9:00 late", "someday", "25:00 x", "2026-01-01 09:00 past"} {
+		if _, _, err := ParseWhen(input, now); err == nil {
+			t.Fatalf("expected error for %q", input)
+		}
+	}
+}
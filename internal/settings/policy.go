@@ -0,0 +1,123 @@
+package settings
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"enoch/internal/agent"
+)
+
+// Policy is the admin-defined allowlist for per-chat run settings. An empty
+// list means the setting cannot be changed from a chat.
+type Policy struct {
+	Models    []string
+	Efforts   []string
+	Sandboxes []string
+	Approvals []string
+	Profiles  []string
+	// ExtraArgs lists the flags users may pass. A trailing "=" marks a flag
+	// that takes a value (e.g. --color=); others (e.g. --search) are
+	// boolean.
+	ExtraArgs []string
+	// EnvNames lists the variable name patterns (e.g. GIT_*) users may set.
+	EnvNames []string
+}
+
+// Overrides returns the chat's settings as run overrides, dropping values the
+// policy no longer allows (the allowlist may have changed since they were
+// stored).
+func (p Policy) Overrides(c Chat) agent.Overrides {
+	o := agent.Overrides{}
+	if Allowed(p.Models, c.Model) {
+		o.Model = c.Model
+	}
+	if Allowed(p.Efforts, c.ReasoningEffort) {
+		o.ReasoningEffort = c.ReasoningEffort
+	}
+	if Allowed(p.Sandboxes, c.Sandbox) {
+		o.Sandbox = c.Sandbox
+	}
+	if Allowed(p.Approvals, c.Approval) {
+		o.Approval = c.Approval
+	}
+	if Allowed(p.Profiles, c.Profile) {
+		o.Profile = c.Profile
+	}
+	if len(c.ExtraArgs) > 0 && CheckExtraArgs(c.ExtraArgs, p.ExtraArgs) == nil {
+		o.ExtraArgs = append([]string(nil), c.ExtraArgs...)
+	}
+	return o
+}
+
+// Env returns the chat's extra variables that the policy still allows.
+func (p Policy) Env(c Chat) map[string]string {
+	if len(c.Env) == 0 {
+		return nil
+	}
+	env := map[string]string{}
+	for name, value := range c.Env {
+		if AllowedEnv(p.EnvNames, name) {
+			env[name] = value
+		}
+	}
+	return env
+}
+
+// AllowedEnv reports whether name matches one of the patterns (path.Match
+// syntax).
+func AllowedEnv(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed reports whether value is in list. Empty values are never allowed.
+func Allowed(list []string, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckExtraArgs validates user-supplied CLI args against the allowed
+// flags. Flags declared with a trailing "=" take a value, inline
+// (--flag=value) or as the next argument; boolean flags take none. Any other
+// bare argument is rejected.
+func CheckExtraArgs(args, allowed []string) error {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			return fmt.Errorf("unexpected argument %q", arg)
+		}
+		name, inline := arg, false
+		if idx := strings.Index(arg, "="); idx >= 0 {
+			name, inline = arg[:idx], true
+		}
+		switch {
+		case Allowed(allowed, name+"="):
+			if inline {
+				continue
+			}
+			if i+1 >= len(args) {
+				return fmt.Errorf("flag %s needs a value", name)
+			}
+			i++
+		case Allowed(allowed, name):
+			if inline {
+				return fmt.Errorf("flag %s does not take a value", name)
+			}
+		default:
+			return fmt.Errorf("flag %s is not allowed", name)
+		}
+	}
+	return nil
+}
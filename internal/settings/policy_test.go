@@ -0,0 +1,45 @@
+package settings
+
+import "testing"
+
+func TestCheckExtraArgs(t *testing.T) {
+	allowed := []string{"--search", "--color="}
+	if err := CheckExtraArgs([]string{"--search", "--color", "never"}, allowed); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := CheckExtraArgs([]string{"--color=never"}, allowed); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := CheckExtraArgs([]string{"--color=never", "x"}, allowed); err == nil {
+		t.Fatalf("expected bare argument after inline value to be rejected")
+	}
+	if err := CheckExtraArgs([]string{"--dangerously-bypass-approvals-and-sandbox"}, allowed); err == nil {
+		t.Fatalf("expected unknown flag to be rejected")
+	}
+	if err := CheckExtraArgs([]string{"prompt"}, allowed); err == nil {
+		t.Fatalf("expected leading bare argument to be rejected")
+	}
+	if err := CheckExtraArgs([]string{"--search", "prompt"}, allowed); err == nil {
+		t.Fatalf("expected bare argument after a boolean flag to be rejected")
+	}
+	if err := CheckExtraArgs([]string{"--search=yes"}, allowed); err == nil {
+		t.Fatalf("expected a value on a boolean flag to be rejected")
+	}
+	if err := CheckExtraArgs([]string{"--color"}, allowed); err == nil {
+		t.Fatalf("expected a missing value to be rejected")
+	}
+	if err := CheckExtraArgs([]string{"--color", "never"}, []string{"--color"}); err == nil {
+		t.Fatalf("expected a flag not declared with = to take no value")
+	}
+}
+
+func TestPolicyOverridesDropsDisallowedValues(t *testing.T) {
+	policy := Policy{Models: []string{"o3"}, Sandboxes: []string{"read-only"}}
+	o := policy.Overrides(Chat{Model: "o3", Sandbox: "danger-full-access", Profile: "work"})
+	if o.Model != "o3" {
+		t.Fatalf("expected allowed model to be kept, got %+v", o)
+	}
+	if o.Sandbox != "" || o.Profile != "" {
+		t.Fatalf("expected disallowed values to be dropped, got %+v", o)
+	}
+}
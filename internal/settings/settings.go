@@ -0,0 +1,104 @@
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// Chat holds the per-chat preferences users can change from Telegram.
+// Empty fields mean "use the global default".
+type Chat struct {
+	Backend         string   `json:"backend,omitempty"`
+	Model           string   `json:"model,omitempty"`
+	ReasoningEffort string   `json:"reasoning_effort,omitempty"`
+	Sandbox         string   `json:"sandbox,omitempty"`
+	Approval        string   `json:"approval,omitempty"`
+	Profile         string   `json:"profile,omitempty"`
+	ExtraArgs       []string `json:"extra_args,omitempty"`
+	// Env holds extra environment variables for CLI backends.
+	Env map[string]string `json:"env,omitempty"`
+}
+
+func (c Chat) empty() bool {
+	return c.Backend == "" && c.Model == "" && c.ReasoningEffort == "" &&
+		c.Sandbox == "" && c.Approval == "" && c.Profile == "" && len(c.ExtraArgs) == 0 &&
+		len(c.Env) == 0
+}
+
+// Store persists per-chat settings as a JSON file keyed by chat id.
+type Store struct {
+	path  string
+	mu    sync.Mutex
+	chats map[int64]Chat
+}
+
+// Open loads the settings file at path (a missing file is not an error).
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, chats: map[int64]Chat{}}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	raw := map[string]Chat{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for key, chat := range raw {
+		id, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: invalid chat id %q", path, key)
+		}
+		s.chats[id] = chat
+	}
+	return s, nil
+}
+
+// Get returns the chat's settings (the zero value when none are stored).
+func (s *Store) Get(chatID int64) Chat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.chats[chatID]
+}
+
+// Update applies fn to the chat's settings and saves the result.
+func (s *Store) Update(chatID int64, fn func(*Chat)) (Chat, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chat := s.chats[chatID]
+	fn(&chat)
+	if chat.empty() {
+		delete(s.chats, chatID)
+	} else {
+		s.chats[chatID] = chat
+	}
+	if err := s.saveLocked(); err != nil {
+		return Chat{}, err
+	}
+	return chat, nil
+}
+
+func (s *Store) saveLocked() error {
+	raw := make(map[string]Chat, len(s.chats))
+	for id, chat := range s.chats {
+		raw[strconv.FormatInt(id, 10)] = chat
+	}
+	data, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
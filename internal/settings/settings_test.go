@@ -0,0 +1,35 @@
+package settings
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestStorePersistsChats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chats.json")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if got := store.Get(42); got.Backend != "" {
+		t.Fatalf("expected empty settings, got %+v", got)
+	}
+	if _, err := store.Update(42, func(c *Chat) { c.Backend = "http" }); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if got := reopened.Get(42); got.Backend != "http" {
+		t.Fatalf("expected persisted backend, got %+v", got)
+	}
+
+	if _, err := reopened.Update(42, func(c *Chat) { c.Backend = "" }); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if len(reopened.chats) != 0 {
+		t.Fatalf("expected empty settings to be dropped, got %+v", reopened.chats)
+	}
+}
@@ -0,0 +1,179 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrJobNotFound is returned by Cancel when no running or queued job
+	// has the trace.
+	ErrJobNotFound = errors.New("job not found")
+	// ErrQueueFull is returned by Enqueue when the queue is full or closed.
+	ErrQueueFull = errors.New("queue is full")
+	// ErrChatNotAllowed is returned by Enqueue for chats outside
+	// TELEGRAM_ALLOWED_CHAT_ID.
+	ErrChatNotAllowed = errors.New("chat is not allowed")
+)
+
+// Health is the liveness of the poll loop and the worker.
+type Health struct {
+	// LastPoll is when the poll loop last started a getUpdates call.
+	LastPoll time.Time
+	// LastUpdates is when getUpdates last succeeded.
+	LastUpdates time.Time
+	// WorkerAlive reports whether the worker goroutine is running.
+	WorkerAlive bool
+	// JobStarted is when the running job started, zero when idle.
+	JobStarted time.Time
+	Stopping   bool
+}
+
+// Status mirrors /status for the HTTP endpoint.
+type Status struct {
+	Paused         bool        `json:"paused"`
+	Running        bool        `json:"running"`
+	CurrentJob     string      `json:"current_job"`
+	QueueLength    int         `json:"queue_length"`
+	ContextSize    int         `json:"context_size"`
+	ContextEntries int         `json:"context_entries"`
+	Queue          []QueuedJob `json:"queue"`
+}
+
+// QueuedJob is a waiting job in Status.
+type QueuedJob struct {
+	Position int    `json:"position"`
+	Trace    string `json:"trace"`
+	ChatID   int64  `json:"chat_id"`
+	Priority string `json:"priority"`
+}
+
+func (b *Bot) markPoll(ok bool) {
+	now := time.Now()
+	b.stateMu.Lock()
+	defer b.stateMu.Unlock()
+	if ok {
+		b.lastUpdates = now
+	} else {
+		b.lastPoll = now
+	}
+}
+
+// Health reports the poll loop heartbeat and worker liveness.
+func (b *Bot) Health() Health {
+	b.stateMu.Lock()
+	h := Health{
+		LastPoll:    b.lastPoll,
+		LastUpdates: b.lastUpdates,
+		JobStarted:  b.jobStarted,
+		Stopping:    b.stopping,
+	}
+	done := b.workerDone
+	b.stateMu.Unlock()
+	if done != nil {
+		select {
+		case <-done:
+		default:
+			h.WorkerAlive = true
+		}
+	}
+	return h
+}
+
+// Status returns the state /status shows, with the whole queue.
+func (b *Bot) Status() Status {
+	b.stateMu.Lock()
+	s := Status{Paused: b.paused, Running: b.running, CurrentJob: b.currentTrace}
+	b.stateMu.Unlock()
+	s.ContextSize = b.cfg().TelegramContextSize
+	s.ContextEntries = b.contextCount()
+	s.Queue = []QueuedJob{}
+	for i, e := range b.queue.entries() {
+		s.Queue = append(s.Queue, QueuedJob{
+			Position: i + 1,
+			Trace:    e.job.trace,
+			ChatID:   e.job.chatID,
+			Priority: priorityName(e.priority),
+		})
+	}
+	s.QueueLength = len(s.Queue)
+	return s
+}
+
+func priorityName(p priority) string {
+	switch p {
+	case priorityLow:
+		return "low"
+	case priorityHigh:
+		return "high"
+	}
+	return "normal"
+}
+
+// Cancel cancels the running job or drops a queued one by trace. An empty
+// trace means the running job.
+func (b *Bot) Cancel(trace string) error {
+	b.stateMu.Lock()
+	current := b.current
+	var cancel func()
+	if current != nil && (trace == "" || current.trace == trace) {
+		cancel = current.cancel
+	}
+	b.stateMu.Unlock()
+	if cancel != nil {
+		if b.logger != nil {
+			b.logger.Infof("admin cancel: job=%s status=running", current.trace)
+		}
+		cancel()
+		return nil
+	}
+	if trace == "" {
+		return ErrJobNotFound
+	}
+	for _, j := range b.queue.snapshot() {
+		if j.trace != trace || !b.queue.remove(j) {
+			continue
+		}
+		b.stateMu.Lock()
+		j.status = jobDone
+		b.stateMu.Unlock()
+		if b.logger != nil {
+			b.logger.Infof("admin cancel: job=%s status=queued", trace)
+		}
+		b.notifyStatus(j, "已被管理员移出队列。")
+		b.refreshQueuedStatus()
+		return nil
+	}
+	return ErrJobNotFound
+}
+
+// Enqueue queues a prompt for a chat as if it had been sent there. The
+// reply goes to the chat; the returned trace identifies the job.
+func (b *Bot) Enqueue(chatID int64, text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", errors.New("empty prompt")
+	}
+	if !isAllowedChat(b.cfg().TelegramAllowedChatID, chatID) {
+		return "", ErrChatNotAllowed
+	}
+	// Traces must not repeat across restarts: they name job logs and
+	// history entries, and saved queue jobs keep theirs.
+	b.stateMu.Lock()
+	id := time.Now().UnixNano() / int64(time.Millisecond)
+	if id <= b.adminSeq {
+		id = b.adminSeq + 1
+	}
+	b.adminSeq = id
+	trace := fmt.Sprintf("admin_id=%d", id)
+	b.stateMu.Unlock()
+	if !b.enqueueJob(&job{chatID: chatID, text: text, trace: trace, header: "[管理接口]"}) {
+		return "", ErrQueueFull
+	}
+	if b.logger != nil {
+		b.logger.Infof("admin enqueue: %s chat_id=%d text=%q", trace, chatID, truncateText(text, 160))
+	}
+	return trace, nil
+}
@@ -0,0 +1,48 @@
+package telegram
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAdminEnqueueAndCancel(t *testing.T) {
+	bot, sent := newRecordingBot("ask")
+	bot.config.TelegramAllowedChatID = "1"
+
+	if _, err := bot.Enqueue(2, "hello"); !errors.Is(err, ErrChatNotAllowed) {
+		t.Fatalf("expected ErrChatNotAllowed, got %v", err)
+	}
+	trace, err := bot.Enqueue(1, "  summarize  ")
+	if err != nil || !strings.HasPrefix(trace, "admin_id=") {
+		t.Fatalf("unexpected enqueue result %q err=%v", trace, err)
+	}
+	// Traces are time-based so they do not repeat after a restart.
+	if again, err := bot.Enqueue(1, "again"); err != nil || again == trace {
+		t.Fatalf("expected a new trace, got %q err=%v", again, err)
+	}
+	bot.queue.remove(bot.queue.snapshot()[1])
+	status := bot.Status()
+	if status.QueueLength != 1 || status.Queue[0].Trace != trace || status.Queue[0].Priority != "normal" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	if err := bot.Cancel(""); !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("expected no running job, got %v", err)
+	}
+	if err := bot.Cancel(trace); err != nil {
+		t.Fatalf("cancel queued: %v", err)
+	}
+	if bot.queue.len() != 0 || len(*sent) != 0 {
+		t.Fatalf("expected the job dropped silently (no status message), queue=%d sent=%d", bot.queue.len(), len(*sent))
+	}
+
+	canceled := false
+	bot.startJob(&job{chatID: 1, trace: "update_id=5"}, func() { canceled = true })
+	if err := bot.Cancel("update_id=5"); err != nil || !canceled {
+		t.Fatalf("expected the running job canceled, err=%v", err)
+	}
+	if h := bot.Health(); h.JobStarted.IsZero() || h.WorkerAlive {
+		t.Fatalf("unexpected health: %+v", h)
+	}
+}
@@ -0,0 +1,90 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+
+	"enoch/internal/agent"
+	"enoch/internal/settings"
+)
+
+// agentFor returns the backend selected for the chat, or the default.
+func (b *Bot) agentFor(chatID int64) agent.Agent {
+	var name string
+	if b.chats != nil {
+		name = b.chats.Get(chatID).Backend
+	}
+	return b.agents.Resolve(name)
+}
+
+// jobEvents records the latest line of streamed output so the status message
+// can show it. Output chunks may split lines, so a short tail is kept. Retry
+// events are shown immediately.
+func (b *Bot) jobEvents(j *job) func(agent.Event) {
+	var tail string
+	return func(event agent.Event) {
+		if event.Kind == agent.EventRetry {
+			b.stateMu.Lock()
+			j.attempt = event.Attempt
+			j.maxAttempts = event.MaxAttempts
+			j.lastOutput = ""
+			b.stateMu.Unlock()
+			tail = ""
+			b.updateStatus(j, fmt.Sprintf("上次尝试失败（%s），%s 后进行第 %d/%d 次尝试…",
+				event.Text, formatElapsed(event.Wait), event.Attempt, event.MaxAttempts))
+			return
+		}
+		if event.Kind == agent.EventInfo {
+			tail += "\n" + event.Text + "\n"
+		} else {
+			tail += event.Text
+		}
+		if len(tail) > 4096 {
+			tail = tail[len(tail)-4096:]
+		}
+		line := lastLine(tail)
+		if line == "" {
+			return
+		}
+		b.stateMu.Lock()
+		j.lastOutput = line
+		b.stateMu.Unlock()
+	}
+}
+
+// lastLine returns the last non-empty line of text.
+func lastLine(text string) string {
+	lines := strings.Split(text, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(lines[i]); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+func (b *Bot) handleBackend(chatID int64, args []string, trace string) {
+	if b.chats == nil {
+		b.reply(chatID, "未启用后端切换。", trace)
+		return
+	}
+	names := b.agents.Names()
+	if len(args) == 0 {
+		current := b.agentFor(chatID).Name()
+		b.reply(chatID, fmt.Sprintf("当前后端：%s\n可用后端：%s\n用法: /backend <名称> | /backend default", current, strings.Join(names, ", ")), trace)
+		return
+	}
+
+	name := strings.TrimSpace(args[0])
+	if name == "default" || name == "reset" {
+		name = ""
+	} else if _, ok := b.agents.Get(name); !ok {
+		b.reply(chatID, fmt.Sprintf("未知后端：%s\n可用后端：%s", name, strings.Join(names, ", ")), trace)
+		return
+	}
+	display := name
+	if display == "" {
+		display = b.agents.DefaultName()
+	}
+	b.updateChat(chatID, trace, fmt.Sprintf("已切换后端：%s", display), func(c *settings.Chat) { c.Backend = name })
+}
@@ -0,0 +1,29 @@
+package telegram
+
+import (
+	"testing"
+
+	"enoch/internal/agent"
+)
+
+func TestJobEventsTracksLastLine(t *testing.T) {
+	b := &Bot{}
+	j := &job{}
+	events := b.jobEvents(j)
+
+	events(agent.Event{Kind: agent.EventOutput, Text: "reading files\n"})
+	if j.lastOutput != "reading files" {
+		t.Fatalf("unexpected last output: %q", j.lastOutput)
+	}
+
+	events(agent.Event{Kind: agent.EventOutput, Text: "writ"})
+	events(agent.Event{Kind: agent.EventOutput, Text: "ing patch"})
+	if j.lastOutput != "writing patch" {
+		t.Fatalf("fragments should be joined, got %q", j.lastOutput)
+	}
+
+	events(agent.Event{Kind: agent.EventOutput, Text: "\n\n"})
+	if j.lastOutput != "writing patch" {
+		t.Fatalf("blank output should keep the previous line, got %q", j.lastOutput)
+	}
+}
@@ -2,44 +2,107 @@ package telegram
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"enoch/internal/codex"
+	"enoch/internal/agent"
 	"enoch/internal/config"
+	"enoch/internal/history"
 	"enoch/internal/logging"
 	"enoch/internal/memory"
+	"enoch/internal/redact"
+	"enoch/internal/scheduler"
+	"enoch/internal/settings"
+	"enoch/internal/usage"
+	"enoch/internal/workspace"
 )
 
+// apiBase is the Bot API endpoint; the token follows it.
+const apiBase = "https://api.telegram.org/bot"
+
 type Bot struct {
+	// config is replaced on reload; read it through cfg().
 	config       config.Config
-	codex        *codex.Client
+	configMu     sync.RWMutex
+	reload       func() ([]string, error)
+	agents       *agent.Registry
+	chats        *settings.Store
+	workspaces   *workspace.Manager
+	usage        *usage.Store
+	history      *history.Store
 	client       *http.Client
 	baseURL      string
+	redactor     *redact.Redactor
 	logger       *logging.Logger
-	queue        chan job
+	queue        *jobQueue
 	paused       bool
+	stopping     bool
 	running      bool
 	currentTrace string
-	memory       *memory.Manager
-	stateMu      sync.Mutex
-	contextMu    sync.Mutex
-	context      map[int64][]contextEntry
-	workerOnce   sync.Once
+	current      *job
+	// jobStarted, lastPoll and lastUpdates feed Health.
+	jobStarted  time.Time
+	lastPoll    time.Time
+	lastUpdates time.Time
+	// adminSeq is the last admin trace id, a Unix time in milliseconds.
+	adminSeq    int64
+	jobs        map[messageKey]*job
+	jobOrder    []messageKey
+	memory      *memory.Manager
+	scheduler   *scheduler.Scheduler
+	stateMu     sync.Mutex
+	contextMu   sync.Mutex
+	context     map[int64][]contextEntry
+	answers     map[messageKey]string
+	answerOrder []messageKey
+	workerOnce  sync.Once
+	workerDone  chan struct{}
+	schedOnce   sync.Once
 }
 
 type job struct {
 	chatID int64
-	text   string
-	trace  string
+	// userID is the sender, or 0 for scheduled runs.
+	userID    int64
+	messageID int
+	text      string
+	trace     string
+	// header is prepended to the reply, e.g. to label scheduled runs.
+	header string
+	// priority is the queue lane; it only changes while the job is queued,
+	// under the queue lock.
+	priority priority
+	// quoted is an earlier bot answer the user replied to; it is given to
+	// the agent as explicit context.
+	quoted string
+
+	// The fields below are guarded by Bot.stateMu.
+	status jobStatus
+	cancel context.CancelFunc
+	// pendingEdit and pendingPriority are an edit awaiting confirmation,
+	// already stripped of its "!" prefix.
+	pendingEdit     string
+	pendingPriority priority
+	superseded      bool
+	// statusID is the acknowledgment message edited in place as the job
+	// moves from queued to running to finished.
+	statusID int
+	// lastOutput is the latest streamed line, shown in the status message.
+	lastOutput string
+	// attempt and maxAttempts are set once the backend retries.
+	attempt     int
+	maxAttempts int
 }
 
 type contextEntry struct {
@@ -47,42 +110,97 @@ type contextEntry struct {
 	text string
 }
 
-func New(cfg config.Config, codexClient *codex.Client, logger *logging.Logger) *Bot {
+func New(cfg config.Config, agents *agent.Registry, chats *settings.Store, workspaces *workspace.Manager, usageStore *usage.Store, jobs *history.Store, sched *scheduler.Scheduler, logger *logging.Logger) *Bot {
 	client := &http.Client{Timeout: 70 * time.Second}
-	root, err := os.Getwd()
-	if err != nil {
-		root = "."
-		if logger != nil {
-			logger.Warnf("telegram bot getwd failed: %v", err)
+	root := cfg.MemoryRoot
+	if root == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			wd = "."
+			if logger != nil {
+				logger.Warnf("telegram bot getwd failed: %v", err)
+			}
 		}
+		root = wd
 	}
 	return &Bot{
-		config:  cfg,
-		codex:   codexClient,
-		client:  client,
-		baseURL: "https://api.telegram.org/bot" + cfg.TelegramBotToken,
-		logger:  logger,
-		queue:   make(chan job, 64),
-		context: map[int64][]contextEntry{},
-		memory:  memory.NewManager(root),
+		config:     cfg,
+		agents:     agents,
+		chats:      chats,
+		workspaces: workspaces,
+		usage:      usageStore,
+		history:    jobs,
+		client:     client,
+		baseURL:    apiBase + cfg.TelegramBotToken,
+		redactor:   cfg.Redactor(),
+		logger:     logger,
+		queue:      newJobQueue(64),
+		jobs:       map[messageKey]*job{},
+		context:    map[int64][]contextEntry{},
+		answers:    map[messageKey]string{},
+		memory:     memory.NewManager(root),
+		scheduler:  sched,
 	}
 }
 
-func (b *Bot) Run() {
+// ErrConflict is returned by Run when Telegram keeps answering getUpdates
+// with 409 Conflict: another process polls with the same token, or a
+// webhook is set.
+var ErrConflict = errors.New("telegram getUpdates conflict")
+
+// conflictTimeout is how long a 409 may persist before Run gives up. A
+// restarted bot briefly conflicts with the long poll of the old process.
+var conflictTimeout = 90 * time.Second
+
+const (
+	// pollTimeout is the getUpdates long poll, in seconds.
+	pollTimeout = 30
+	// confirmTimeout bounds the offset confirmation made when Run stops.
+	confirmTimeout = 5 * time.Second
+)
+
+// Run polls for updates until ctx is canceled, returning nil, or until the
+// polling conflict persists, returning an ErrConflict error. The worker keeps
+// running; call Shutdown to stop it.
+func (b *Bot) Run(ctx context.Context) error {
+	b.restoreQueue()
 	b.startWorker()
+	b.startScheduler()
 	var offset *int
+	var conflictSince time.Time
 	backoff := b.pollInterval()
 	for {
-		updates, err := b.getUpdates(offset)
-		if err != nil {
+		b.markPoll(false)
+		updates, err := b.getUpdates(ctx, offset, pollTimeout)
+		if ctx.Err() != nil {
+			b.confirmUpdates(offset)
+			return nil
+		}
+		if errors.Is(err, ErrConflict) {
+			if conflictSince.IsZero() {
+				conflictSince = time.Now()
+			}
+			if strings.Contains(err.Error(), "webhook") || time.Since(conflictSince) >= conflictTimeout {
+				return err
+			}
 			if b.logger != nil {
-				b.logger.Errorf("telegram getUpdates failed: %v", err)
+				b.logger.Errorf("telegram getUpdates conflict: another process is polling with this token, giving up in %s: %v",
+					(conflictTimeout - time.Since(conflictSince)).Round(time.Second), err)
+			}
+		} else if err != nil && b.logger != nil {
+			b.logger.Errorf("telegram getUpdates failed: %v", err)
+		}
+		if err != nil {
+			if !sleepContext(ctx, backoff) {
+				b.confirmUpdates(offset)
+				return nil
 			}
-			time.Sleep(backoff)
 			backoff = nextBackoff(backoff, 60*time.Second)
 			continue
 		}
+		conflictSince = time.Time{}
 		backoff = b.pollInterval()
+		b.markPoll(true)
 
 		if b.logger != nil {
 			b.logger.Debugf("telegram getUpdates ok: count=%d", len(updates))
@@ -94,9 +212,16 @@ func (b *Bot) Run() {
 
 			trace := fmt.Sprintf("update_id=%d", update.UpdateID)
 
+			if update.CallbackQuery != nil {
+				b.handleCallback(update.CallbackQuery, trace)
+				continue
+			}
+
 			msg := update.Message
+			edited := false
 			if msg == nil {
 				msg = update.EditedMessage
+				edited = msg != nil
 			}
 			if msg == nil {
 				if b.logger != nil {
@@ -114,30 +239,36 @@ func (b *Bot) Run() {
 			chatID := msg.Chat.ID
 			if b.logger != nil {
 				preview := truncateText(msg.Text, 160)
-				b.logger.Infof("telegram message received: %s chat_id=%d text=%q", trace, chatID, preview)
+				b.logger.Infof("telegram message received: %s chat_id=%d edited=%t text=%q", trace, chatID, edited, preview)
 			}
 
-			if !isAllowedChat(b.config.TelegramAllowedChatID, chatID) {
+			if !isAllowedChat(b.cfg().TelegramAllowedChatID, chatID) {
 				if b.logger != nil {
-					b.logger.Warnf("telegram message ignored: %s chat_id=%d allowed=%q", trace, chatID, b.config.TelegramAllowedChatID)
+					b.logger.Warnf("telegram message ignored: %s chat_id=%d allowed=%q", trace, chatID, b.cfg().TelegramAllowedChatID)
 				}
 				continue
 			}
 
-			if b.handleCommand(chatID, msg.Text, trace) {
+			if edited && b.handleEdit(msg, trace) {
 				continue
 			}
 
-			if b.enqueueJob(chatID, msg.Text, trace) {
-				ack := "已加入队列，请稍候。"
-				if b.isPaused() {
-					ack = "已暂停处理，任务已排队。"
-				}
-				if err := b.sendMessage(chatID, ack); err != nil {
-					if b.logger != nil {
-						b.logger.Errorf("telegram sendMessage failed: %s err=%v", trace, err)
-					}
-				}
+			if b.handleCommand(msg, trace) {
+				continue
+			}
+
+			text, prio := b.messagePriority(msg)
+			queued := &job{
+				chatID:    chatID,
+				userID:    senderID(msg),
+				messageID: msg.MessageID,
+				text:      text,
+				trace:     trace,
+				quoted:    b.quotedAnswer(msg),
+				priority:  prio,
+			}
+			if b.enqueueJob(queued) {
+				b.sendQueuedStatus(queued)
 			} else {
 				if err := b.sendMessage(chatID, "队列已满，请稍后再试。"); err != nil {
 					if b.logger != nil {
@@ -147,87 +278,223 @@ func (b *Bot) Run() {
 			}
 		}
 
-		time.Sleep(b.pollInterval())
+		if !sleepContext(ctx, b.pollInterval()) {
+			b.confirmUpdates(offset)
+			return nil
+		}
+	}
+}
+
+// confirmUpdates tells Telegram that the updates before offset were handled.
+// Offsets are otherwise only confirmed by the next long poll, so without it
+// the last batch would be delivered again after a restart, next to the jobs
+// Shutdown saved from it.
+func (b *Bot) confirmUpdates(offset *int) {
+	if offset == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), confirmTimeout)
+	defer cancel()
+	if _, err := b.getUpdates(ctx, offset, 0); err != nil && b.logger != nil {
+		b.logger.Errorf("telegram offset confirm failed: offset=%d err=%v", *offset, err)
+	}
+}
+
+// sleepContext waits for d and reports false if ctx ended first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
 	}
 }
 
 func (b *Bot) startWorker() {
 	b.workerOnce.Do(func() {
-		go b.workerLoop()
+		done := make(chan struct{})
+		b.stateMu.Lock()
+		b.workerDone = done
+		b.stateMu.Unlock()
+		go b.workerLoop(done)
 	})
 }
 
-func (b *Bot) workerLoop() {
-	for job := range b.queue {
-		b.waitForResume()
+// workerLoop runs queued jobs one at a time until the queue is closed.
+func (b *Bot) workerLoop(done chan struct{}) {
+	defer close(done)
+	for {
+		job := b.queue.pop()
+		if job == nil {
+			return
+		}
+		if !b.waitForResume() {
+			b.queue.requeue(job)
+			return
+		}
 		b.processJob(job)
 	}
 }
 
-func (b *Bot) waitForResume() {
+// waitForResume blocks while the bot is paused and reports false if it is
+// shutting down instead.
+func (b *Bot) waitForResume() bool {
 	for {
 		b.stateMu.Lock()
-		paused := b.paused
+		paused, stopping := b.paused, b.stopping
 		b.stateMu.Unlock()
+		if stopping {
+			return false
+		}
 		if !paused {
-			return
+			return true
 		}
 		time.Sleep(200 * time.Millisecond)
 	}
 }
 
-func (b *Bot) enqueueJob(chatID int64, text, trace string) bool {
-	select {
-	case b.queue <- job{chatID: chatID, text: text, trace: trace}:
-		return true
-	default:
+func (b *Bot) enqueueJob(j *job) bool {
+	if !b.queue.push(j) {
 		return false
 	}
+	if j.messageID != 0 {
+		b.trackJob(j)
+	}
+	if b.logger != nil && j.priority != priorityNormal {
+		b.logger.Debugf("job queued: %s priority=%s position=%d", j.trace, j.priority, b.queue.position(j))
+	}
+	if j.priority == priorityHigh {
+		// Jobs the new one overtook have moved back.
+		b.refreshQueuedStatus()
+	}
+	return true
 }
 
-func (b *Bot) processJob(job job) {
-	b.setRunning(true, job.trace)
-	defer b.setRunning(false, "")
+func (b *Bot) processJob(job *job) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	stopTyping := b.startTypingLoop(job.chatID, job.trace)
-	stopProgress := b.startProgressLoop(job.chatID, job.trace)
+	text := b.startJob(job, cancel)
+	defer b.finishJob(job)
+	b.refreshQueuedStatus()
+
+	entry := b.startHistory(job, text)
+	var output string
+	defer func() { b.finishHistory(entry, output) }()
+
+	if !b.checkBudget(job) {
+		entry.Status = history.StatusRejected
+		entry.Note = "budget"
+		return
+	}
+
+	workdir, release, ok := b.acquireWorkspace(job)
+	if !ok {
+		entry.Status = history.StatusRejected
+		entry.Note = "workspace"
+		return
+	}
+	defer release()
 
 	start := time.Now()
+	stopTyping := b.startTypingLoop(job.chatID, job.trace)
+	stopStatus := b.startStatusLoop(job, start)
+
+	backend := b.agentFor(job.chatID)
+	entry.Backend = backend.Name()
 	if b.logger != nil {
-		b.logger.Infof("codex start: %s", job.trace)
+		b.logger.Infof("agent start: %s backend=%s", job.trace, backend.Name())
 	}
 
-	prompt := b.buildPrompt(job.chatID, job.text)
-	reply, err := b.codex.Run(prompt)
+	prompt := b.buildPrompt(job.chatID, text, job.quoted)
+	result, err := backend.Run(ctx, agent.Request{
+		Prompt:    prompt,
+		Trace:     job.trace,
+		Overrides: b.overridesFor(job.chatID),
+		Workdir:   workdir,
+		Env:       b.envFor(job.chatID),
+	}, b.jobEvents(job))
+	// Failed, canceled and retried runs consumed tokens as well.
+	defer b.recordUsage(job, backend.Name(), result.Usage)
+	reply := result.Output
+	if err == nil && result.Truncated {
+		reply += truncatedNotice(job.trace)
+	}
 
 	stopTyping()
-	stopProgress()
+	stopStatus()
 
 	duration := time.Since(start)
+	if errors.Is(err, agent.ErrCanceled) {
+		if b.logger != nil {
+			b.logger.Warnf("agent canceled: %s duration=%s", job.trace, duration)
+		}
+		entry.Status = history.StatusCanceled
+		if b.isSuperseded(job) {
+			entry.Note = "superseded"
+			b.updateStatus(job, "已被修改后的内容取代。")
+		} else if b.isStopping() {
+			entry.Note = "shutdown"
+			b.notifyStatus(job, "机器人正在关闭，任务未完成已取消，请稍后重新发送。")
+		} else if !b.updateStatus(job, "已取消。") {
+			if _, err := b.sendMessageWithOptions(job.chatID, "任务已取消。", messageOptions{replyTo: job.messageID}); err != nil && b.logger != nil {
+				b.logger.Errorf("telegram sendMessage failed: %s err=%v", job.trace, err)
+			}
+		}
+		return
+	}
 	if err != nil {
 		if b.logger != nil {
-			b.logger.Errorf("codex failed: %s duration=%s err=%v", job.trace, duration, err)
+			b.logger.Errorf("agent failed: %s backend=%s duration=%s class=%s err=%v", job.trace, backend.Name(), duration, agent.ErrorClass(err), err)
 		}
 		reply = "处理失败，请稍后重试。"
-	} else if b.logger != nil {
-		b.logger.Infof("codex ok: %s duration=%s bytes=%d", job.trace, duration, len(reply))
+		entry.Status = history.StatusFailed
+		entry.ErrorClass = agent.ErrorClass(err)
+		entry.ExitCode = -1
+		var runErr *agent.RunError
+		if errors.As(err, &runErr) {
+			entry.ExitCode = runErr.ExitCode
+			entry.Attempts = runErr.Attempts
+			if runErr.Attempts > 1 {
+				reply = fmt.Sprintf("处理失败（共尝试 %d 次），请稍后重试。", runErr.Attempts)
+			}
+		}
+	} else {
+		if b.logger != nil {
+			b.logger.Infof("agent ok: %s backend=%s duration=%s bytes=%d", job.trace, backend.Name(), duration, len(reply))
+		}
+		entry.Status = history.StatusOK
+		entry.Truncated = result.Truncated
+		output = reply
 	}
 
 	if strings.TrimSpace(reply) == "" {
 		if b.logger != nil {
-			b.logger.Warnf("codex empty reply: %s duration=%s", job.trace, duration)
+			b.logger.Warnf("agent empty reply: %s duration=%s", job.trace, duration)
 		}
+		b.updateStatus(job, fmt.Sprintf("完成（无输出） · 用时 %s", formatElapsed(duration)))
 		return
 	}
 
-	if err := b.sendReply(job.chatID, reply); err != nil {
+	outgoing := reply
+	if job.header != "" {
+		outgoing = job.header + "\n" + reply
+	}
+
+	sentIDs, sendErr := b.sendReply(job.chatID, job.messageID, outgoing)
+	if sendErr != nil {
 		if b.logger != nil {
-			b.logger.Errorf("telegram sendMessage failed: %s err=%v", job.trace, err)
+			b.logger.Errorf("telegram sendMessage failed: %s err=%v", job.trace, sendErr)
 		}
+		b.updateStatus(job, fmt.Sprintf("答复发送失败 · 用时 %s", formatElapsed(duration)))
 		return
 	}
+	b.rememberAnswer(job.chatID, sentIDs, reply)
+	b.completeStatus(job, err == nil, duration)
 
-	b.appendContext(job.chatID, "User", job.text)
+	b.appendContext(job.chatID, "User", text)
 	b.appendContext(job.chatID, "Assistant", reply)
 
 	if b.logger != nil {
@@ -235,8 +502,9 @@ func (b *Bot) processJob(job job) {
 	}
 }
 
-func (b *Bot) handleCommand(chatID int64, text, trace string) bool {
-	trimmed := strings.TrimSpace(text)
+func (b *Bot) handleCommand(msg *Message, trace string) bool {
+	chatID := msg.Chat.ID
+	trimmed := strings.TrimSpace(msg.Text)
 	if trimmed == "" || trimmed[0] != '/' {
 		return false
 	}
@@ -249,22 +517,36 @@ func (b *Bot) handleCommand(chatID int64, text, trace string) bool {
 		cmd = "/memory_" + strings.ToLower(parts[1])
 		parts = append([]string{cmd}, parts[2:]...)
 	}
+	if cmd == "/schedule" && len(parts) >= 2 && isScheduleSubcommand(parts[1]) {
+		cmd = "/schedule_" + strings.ToLower(parts[1])
+		parts = append([]string{cmd}, parts[2:]...)
+	}
+	if cmd == "/remind" && len(parts) >= 2 && isRemindSubcommand(parts[1]) {
+		cmd = "/remind_" + strings.ToLower(parts[1])
+		parts = append([]string{cmd}, parts[2:]...)
+	}
 
 	switch cmd {
 	case "/status":
-		status := b.statusSummary()
+		status := b.statusSummary(chatID)
 		if err := b.sendMessage(chatID, status); err != nil && b.logger != nil {
 			b.logger.Errorf("telegram sendMessage failed: %s err=%v", trace, err)
 		}
 		return true
+	case "/queue":
+		b.handleQueue(msg, parts[1:], trace)
+		return true
+	case "/reload":
+		b.handleReload(msg, trace)
+		return true
 	case "/stop":
-		b.setPaused(true)
+		b.SetPaused(true)
 		if err := b.sendMessage(chatID, "已暂停处理新任务。"); err != nil && b.logger != nil {
 			b.logger.Errorf("telegram sendMessage failed: %s err=%v", trace, err)
 		}
 		return true
 	case "/resume":
-		b.setPaused(false)
+		b.SetPaused(false)
 		if err := b.sendMessage(chatID, "已恢复处理。"); err != nil && b.logger != nil {
 			b.logger.Errorf("telegram sendMessage failed: %s err=%v", trace, err)
 		}
@@ -275,6 +557,57 @@ func (b *Bot) handleCommand(chatID int64, text, trace string) bool {
 			b.logger.Errorf("telegram sendMessage failed: %s err=%v", trace, err)
 		}
 		return true
+	case "/schedule":
+		b.handleScheduleAdd(chatID, strings.TrimSpace(strings.TrimPrefix(trimmed, parts[0])), trace)
+		return true
+	case "/schedule_list":
+		b.handleScheduleList(chatID, trace)
+		return true
+	case "/schedule_pause", "/schedule_resume", "/schedule_delete":
+		b.handleScheduleUpdate(chatID, cmd, parts[1:], trace)
+		return true
+	case "/remind":
+		b.handleRemindAdd(chatID, strings.TrimSpace(strings.TrimPrefix(trimmed, parts[0])), trace)
+		return true
+	case "/remind_list":
+		b.handleRemindList(chatID, trace)
+		return true
+	case "/remind_delete":
+		b.handleRemindDelete(chatID, parts[1:], trace)
+		return true
+	case "/backend":
+		b.handleBackend(chatID, parts[1:], trace)
+		return true
+	case "/usage":
+		b.handleUsage(chatID, senderID(msg), trace)
+		return true
+	case "/env":
+		b.handleEnv(chatID, senderID(msg), parts[1:], trace)
+		return true
+	case "/jobs":
+		b.handleJobs(chatID, trace)
+		return true
+	case "/job":
+		b.handleJob(chatID, parts[1:], trace)
+		return true
+	case "/rerun":
+		b.handleRerun(msg, parts[1:], trace)
+		return true
+	case "/log":
+		b.handleLog(chatID, parts[1:], trace)
+		return true
+	case "/workspace":
+		b.handleWorkspace(chatID, parts[1:], trace)
+		return true
+	case "/model":
+		b.handleModel(chatID, parts[1:], trace)
+		return true
+	case "/sandbox":
+		b.handleSandbox(chatID, parts[1:], trace)
+		return true
+	case "/profile":
+		b.handleProfile(chatID, parts[1:], trace)
+		return true
 	case "/memory_add":
 		message := strings.TrimSpace(strings.Join(parts[1:], " "))
 		if message == "" {
@@ -354,7 +687,9 @@ func (b *Bot) handleCommand(chatID int64, text, trace string) bool {
 	}
 }
 
-func (b *Bot) setPaused(paused bool) {
+// SetPaused stops or resumes taking jobs from the queue; the running job is
+// not affected.
+func (b *Bot) SetPaused(paused bool) {
 	b.stateMu.Lock()
 	b.paused = paused
 	b.stateMu.Unlock()
@@ -366,22 +701,46 @@ func (b *Bot) isPaused() bool {
 	return b.paused
 }
 
-func (b *Bot) setRunning(running bool, trace string) {
+// startJob marks the job as running and returns its text as of now, since
+// edits may have replaced it while it was queued.
+func (b *Bot) startJob(j *job, cancel context.CancelFunc) string {
 	b.stateMu.Lock()
-	b.running = running
-	b.currentTrace = trace
-	b.stateMu.Unlock()
+	defer b.stateMu.Unlock()
+	b.running = true
+	b.currentTrace = j.trace
+	b.current = j
+	b.jobStarted = time.Now()
+	j.status = jobRunning
+	j.cancel = cancel
+	return j.text
+}
+
+func (b *Bot) finishJob(j *job) {
+	b.stateMu.Lock()
+	defer b.stateMu.Unlock()
+	b.running = false
+	b.currentTrace = ""
+	b.current = nil
+	b.jobStarted = time.Time{}
+	j.status = jobDone
+	j.cancel = nil
 }
 
-func (b *Bot) statusSummary() string {
+func (b *Bot) isSuperseded(j *job) bool {
+	b.stateMu.Lock()
+	defer b.stateMu.Unlock()
+	return j.superseded
+}
+
+func (b *Bot) statusSummary(chatID int64) string {
 	b.stateMu.Lock()
 	paused := b.paused
 	running := b.running
 	trace := b.currentTrace
-	queueLen := len(b.queue)
 	b.stateMu.Unlock()
+	queueLen := b.queue.len()
 
-	contextSize := b.config.TelegramContextSize
+	contextSize := b.cfg().TelegramContextSize
 	contextCount := b.contextCount()
 
 	status := "状态："
@@ -398,14 +757,14 @@ func (b *Bot) statusSummary() string {
 		trace = "-"
 	}
 	return fmt.Sprintf("%s\n处理中：%s\n队列长度：%d\n当前任务：%s\n上下文大小：%d\n上下文条目：%d",
-		status, runningText, queueLen, trace, contextSize, contextCount)
+		status, runningText, queueLen, trace, contextSize, contextCount) + b.queueByChat(chatID)
 }
 
 func (b *Bot) pollInterval() time.Duration {
-	if b.config.TelegramPollInterval <= 0 {
+	if b.cfg().TelegramPollInterval <= 0 {
 		return 2 * time.Second
 	}
-	return b.config.TelegramPollInterval
+	return b.cfg().TelegramPollInterval
 }
 
 func nextBackoff(current, max time.Duration) time.Duration {
@@ -425,24 +784,60 @@ type updatesResponse struct {
 }
 
 type Update struct {
-	UpdateID      int      `json:"update_id"`
-	Message       *Message `json:"message"`
-	EditedMessage *Message `json:"edited_message"`
+	UpdateID      int            `json:"update_id"`
+	Message       *Message       `json:"message"`
+	EditedMessage *Message       `json:"edited_message"`
+	CallbackQuery *CallbackQuery `json:"callback_query"`
 }
 
 type Message struct {
-	MessageID int    `json:"message_id"`
-	Text      string `json:"text"`
-	Chat      Chat   `json:"chat"`
+	MessageID      int      `json:"message_id"`
+	Text           string   `json:"text"`
+	Chat           Chat     `json:"chat"`
+	From           *User    `json:"from"`
+	ReplyToMessage *Message `json:"reply_to_message"`
 }
 
 type Chat struct {
 	ID int64 `json:"id"`
 }
 
-func (b *Bot) getUpdates(offset *int) ([]Update, error) {
+type User struct {
+	ID    int64 `json:"id"`
+	IsBot bool  `json:"is_bot"`
+}
+
+type CallbackQuery struct {
+	ID      string   `json:"id"`
+	From    User     `json:"from"`
+	Message *Message `json:"message"`
+	Data    string   `json:"data"`
+}
+
+type apiResponse struct {
+	Ok          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	Description string          `json:"description"`
+}
+
+type inlineKeyboardMarkup struct {
+	InlineKeyboard [][]inlineKeyboardButton `json:"inline_keyboard"`
+}
+
+type inlineKeyboardButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data"`
+}
+
+type messageOptions struct {
+	replyTo int
+	markup  *inlineKeyboardMarkup
+}
+
+// getUpdates long polls for up to timeout seconds.
+func (b *Bot) getUpdates(ctx context.Context, offset *int, timeout int) ([]Update, error) {
 	payload := map[string]interface{}{
-		"timeout": 30,
+		"timeout": timeout,
 	}
 	if offset != nil {
 		payload["offset"] = *offset
@@ -453,18 +848,23 @@ func (b *Bot) getUpdates(offset *int) ([]Update, error) {
 		return nil, err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, b.baseURL+"/getUpdates", bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.baseURL+"/getUpdates", bytes.NewBuffer(body))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := b.client.Do(req)
+	resp, err := b.do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusConflict {
+		var failure apiResponse
+		_ = json.NewDecoder(resp.Body).Decode(&failure)
+		return nil, fmt.Errorf("%w: %s", ErrConflict, failure.Description)
+	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		return nil, fmt.Errorf("getUpdates status: %s", resp.Status)
 	}
@@ -480,48 +880,139 @@ func (b *Bot) getUpdates(offset *int) ([]Update, error) {
 }
 
 func (b *Bot) sendMessage(chatID int64, text string) error {
+	_, err := b.sendMessageWithOptions(chatID, text, messageOptions{})
+	return err
+}
+
+// sendMessageWithOptions sends a text message and returns its message id.
+func (b *Bot) sendMessageWithOptions(chatID int64, text string, opts messageOptions) (int, error) {
 	payload := map[string]interface{}{
 		"chat_id": chatID,
-		"text":    text,
+		"text":    b.redactor.String(text),
+	}
+	if opts.replyTo != 0 {
+		payload["reply_to_message_id"] = opts.replyTo
+		payload["allow_sending_without_reply"] = true
+	}
+	if opts.markup != nil {
+		payload["reply_markup"] = opts.markup
+	}
+	var sent Message
+	if err := b.callMethod("sendMessage", payload, &sent); err != nil {
+		return 0, err
+	}
+	return sent.MessageID, nil
+}
+
+func (b *Bot) editMessageText(chatID int64, messageID int, text string) error {
+	payload := map[string]interface{}{
+		"chat_id":    chatID,
+		"message_id": messageID,
+		"text":       b.redactor.String(text),
+	}
+	return b.callMethod("editMessageText", payload, nil)
+}
+
+func (b *Bot) deleteMessage(chatID int64, messageID int) error {
+	payload := map[string]interface{}{
+		"chat_id":    chatID,
+		"message_id": messageID,
+	}
+	return b.callMethod("deleteMessage", payload, nil)
+}
+
+func (b *Bot) answerCallbackQuery(id, text string) error {
+	payload := map[string]interface{}{
+		"callback_query_id": id,
 	}
+	if text != "" {
+		payload["text"] = text
+	}
+	return b.callMethod("answerCallbackQuery", payload, nil)
+}
+
+// do sends a Bot API request. Transport errors quote the request URL, which
+// contains the token, so it is masked before the error is logged or shown.
+func (b *Bot) do(req *http.Request) (*http.Response, error) {
+	resp, err := b.client.Do(req)
+	if urlErr, ok := err.(*url.Error); ok {
+		if token := b.cfg().TelegramBotToken; token != "" {
+			urlErr.URL = strings.ReplaceAll(urlErr.URL, token, redact.Mask)
+		}
+	}
+	return resp, err
+}
+
+// callMethod posts a JSON payload to a Bot API method and decodes the
+// result into out when it is non-nil.
+func (b *Bot) callMethod(method string, payload interface{}, out interface{}) error {
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, b.baseURL+"/sendMessage", bytes.NewBuffer(body))
+	req, err := http.NewRequest(http.MethodPost, b.baseURL+"/"+method, bytes.NewBuffer(body))
 	if err != nil {
 		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := b.client.Do(req)
+	resp, err := b.do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("sendMessage status: %s", resp.Status)
+		var decoded apiResponse
+		if json.NewDecoder(resp.Body).Decode(&decoded) == nil && decoded.Description != "" {
+			return fmt.Errorf("%s status: %s (%s)", method, resp.Status, decoded.Description)
+		}
+		return fmt.Errorf("%s status: %s", method, resp.Status)
 	}
-	return nil
+	if out == nil {
+		return nil
+	}
+
+	var decoded apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return err
+	}
+	if !decoded.Ok {
+		return fmt.Errorf("%s returned ok=false: %s", method, decoded.Description)
+	}
+	return json.Unmarshal(decoded.Result, out)
 }
 
-func (b *Bot) sendReply(chatID int64, text string) error {
+// sendReply sends text as a reply to replyTo (0 for none), splitting or
+// attaching it as a file when long. It returns the ids of the sent messages.
+func (b *Bot) sendReply(chatID int64, replyTo int, text string) ([]int, error) {
 	const limit = 4096
+	opts := messageOptions{replyTo: replyTo}
 	if len([]rune(text)) <= limit {
-		return b.sendMessage(chatID, text)
+		id, err := b.sendMessageWithOptions(chatID, text, opts)
+		if err != nil {
+			return nil, err
+		}
+		return []int{id}, nil
 	}
 
 	chunks := splitMessage(text, limit)
 	if len(chunks) > 3 {
-		return b.sendDocument(chatID, "reply.txt", []byte(text))
+		id, err := b.sendDocument(chatID, replyTo, "reply.txt", []byte(text))
+		if err != nil {
+			return nil, err
+		}
+		return []int{id}, nil
 	}
+	ids := make([]int, 0, len(chunks))
 	for _, chunk := range chunks {
-		if err := b.sendMessage(chatID, chunk); err != nil {
-			return err
+		id, err := b.sendMessageWithOptions(chatID, chunk, opts)
+		if err != nil {
+			return ids, err
 		}
+		ids = append(ids, id)
 	}
-	return nil
+	return ids, nil
 }
 
 func (b *Bot) sendTextOrDocument(chatID int64, filename, text string) error {
@@ -529,41 +1020,59 @@ func (b *Bot) sendTextOrDocument(chatID int64, filename, text string) error {
 	if len([]rune(text)) <= limit {
 		return b.sendMessage(chatID, text)
 	}
-	return b.sendDocument(chatID, filename, []byte(text))
+	_, err := b.sendDocument(chatID, 0, filename, []byte(text))
+	return err
 }
 
-func (b *Bot) sendDocument(chatID int64, filename string, content []byte) error {
+func (b *Bot) sendDocument(chatID int64, replyTo int, filename string, content []byte) (int, error) {
 	var body bytes.Buffer
 	writer := multipart.NewWriter(&body)
 	if err := writer.WriteField("chat_id", strconv.FormatInt(chatID, 10)); err != nil {
-		return err
+		return 0, err
+	}
+	if replyTo != 0 {
+		if err := writer.WriteField("reply_to_message_id", strconv.Itoa(replyTo)); err != nil {
+			return 0, err
+		}
+		if err := writer.WriteField("allow_sending_without_reply", "true"); err != nil {
+			return 0, err
+		}
 	}
 	part, err := writer.CreateFormFile("document", filename)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	if _, err := io.Copy(part, bytes.NewReader(content)); err != nil {
-		return err
+	if _, err := io.Copy(part, strings.NewReader(b.redactor.String(string(content)))); err != nil {
+		return 0, err
 	}
 	if err := writer.Close(); err != nil {
-		return err
+		return 0, err
 	}
 
 	req, err := http.NewRequest(http.MethodPost, b.baseURL+"/sendDocument", &body)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	resp, err := b.client.Do(req)
+	resp, err := b.do(req)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("sendDocument status: %s", resp.Status)
+		return 0, fmt.Errorf("sendDocument status: %s", resp.Status)
 	}
-	return nil
+
+	var decoded apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return 0, err
+	}
+	var sent Message
+	if err := json.Unmarshal(decoded.Result, &sent); err != nil {
+		return 0, err
+	}
+	return sent.MessageID, nil
 }
 
 func (b *Bot) sendChatAction(chatID int64, action string) error {
@@ -582,7 +1091,7 @@ func (b *Bot) sendChatAction(chatID int64, action string) error {
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := b.client.Do(req)
+	resp, err := b.do(req)
 	if err != nil {
 		return err
 	}
@@ -594,7 +1103,7 @@ func (b *Bot) sendChatAction(chatID int64, action string) error {
 }
 
 func (b *Bot) startTypingLoop(chatID int64, trace string) func() {
-	if b.config.TelegramTypingInterval <= 0 {
+	if b.cfg().TelegramTypingInterval <= 0 {
 		return func() {}
 	}
 
@@ -608,7 +1117,7 @@ func (b *Bot) startTypingLoop(chatID int64, trace string) func() {
 
 	done := make(chan struct{})
 	go func() {
-		ticker := time.NewTicker(b.config.TelegramTypingInterval)
+		ticker := time.NewTicker(b.cfg().TelegramTypingInterval)
 		defer ticker.Stop()
 		for {
 			select {
@@ -629,39 +1138,6 @@ func (b *Bot) startTypingLoop(chatID int64, trace string) func() {
 	return func() { close(done) }
 }
 
-func (b *Bot) startProgressLoop(chatID int64, trace string) func() {
-	interval := b.config.CodexProgressInterval
-	if interval <= 0 {
-		return func() {}
-	}
-	minInterval := 30 * time.Second
-	if interval < minInterval {
-		interval = minInterval
-	}
-
-	done := make(chan struct{})
-	go func() {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-done:
-				return
-			case <-ticker.C:
-				if err := b.sendMessage(chatID, "仍在处理中"); err != nil {
-					if b.logger != nil {
-						b.logger.Warnf("telegram progress update failed: %s err=%v", trace, err)
-					}
-				} else if b.logger != nil {
-					b.logger.Debugf("telegram progress update sent: %s", trace)
-				}
-			}
-		}
-	}()
-
-	return func() { close(done) }
-}
-
 func isAllowedChat(allowed string, chatID int64) bool {
 	if strings.TrimSpace(allowed) == "" {
 		return true
@@ -669,6 +1145,14 @@ func isAllowedChat(allowed string, chatID int64) bool {
 	return strconv.FormatInt(chatID, 10) == allowed
 }
 
+// senderID returns the Telegram user id of the message author, or 0.
+func senderID(msg *Message) int64 {
+	if msg.From == nil {
+		return 0
+	}
+	return msg.From.ID
+}
+
 func truncateText(text string, limit int) string {
 	text = strings.ReplaceAll(text, "\n", " ")
 	text = strings.TrimSpace(text)
@@ -717,21 +1201,31 @@ func filepathBase(path string) string {
 	return path
 }
 
-func (b *Bot) buildPrompt(chatID int64, text string) string {
-	if b.config.TelegramContextSize <= 0 {
-		return text
+func (b *Bot) buildPrompt(chatID int64, text, quoted string) string {
+	var entries []contextEntry
+	if b.cfg().TelegramContextSize > 0 {
+		entries = b.getContext(chatID)
 	}
-	entries := b.getContext(chatID)
-	if len(entries) == 0 {
+	if len(entries) == 0 && quoted == "" {
 		return text
 	}
 	var sb strings.Builder
-	sb.WriteString("Conversation history:\n")
-	for _, entry := range entries {
-		sb.WriteString(entry.role)
-		sb.WriteString(": ")
-		sb.WriteString(entry.text)
-		sb.WriteString("\n")
+	if len(entries) > 0 {
+		sb.WriteString("Conversation history:\n")
+		for _, entry := range entries {
+			sb.WriteString(entry.role)
+			sb.WriteString(": ")
+			sb.WriteString(entry.text)
+			sb.WriteString("\n")
+		}
+	}
+	if quoted != "" {
+		if len(entries) > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("The user is replying to this earlier answer:\n")
+		sb.WriteString(quoted)
+		sb.WriteString("\n\n")
 	}
 	sb.WriteString("User: ")
 	sb.WriteString(text)
@@ -739,7 +1233,7 @@ func (b *Bot) buildPrompt(chatID int64, text string) string {
 }
 
 func (b *Bot) appendContext(chatID int64, role, text string) {
-	if b.config.TelegramContextSize <= 0 {
+	if b.cfg().TelegramContextSize <= 0 {
 		return
 	}
 	text = strings.TrimSpace(text)
@@ -749,7 +1243,7 @@ func (b *Bot) appendContext(chatID int64, role, text string) {
 	b.contextMu.Lock()
 	defer b.contextMu.Unlock()
 	entries := append(b.context[chatID], contextEntry{role: role, text: text})
-	limit := b.config.TelegramContextSize
+	limit := b.cfg().TelegramContextSize
 	if limit > 0 && len(entries) > limit {
 		entries = entries[len(entries)-limit:]
 	}
@@ -2,10 +2,16 @@ package telegram
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
+
+	"enoch/internal/config"
 )
 
 type roundTripFunc func(*http.Request) (*http.Response, error)
@@ -69,3 +75,79 @@ func TestSendChatAction(t *testing.T) {
 		t.Fatalf("expected action typing, got %q", got.Action)
 	}
 }
+
+func TestTransportErrorsHideToken(t *testing.T) {
+	const token = "123456:SECRET-token-value"
+	cfg := config.Config{TelegramBotToken: token}
+	var sent string
+	client := &http.Client{
+		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(r.URL.Path, "/sendMessage") {
+				var payload struct {
+					Text string `json:"text"`
+				}
+				_ = json.NewDecoder(r.Body).Decode(&payload)
+				sent = payload.Text
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true,"result":{"message_id":1}}`)),
+					Header:     make(http.Header),
+				}, nil
+			}
+			return nil, errors.New("connection refused")
+		}),
+	}
+	bot := &Bot{
+		config:   cfg,
+		client:   client,
+		baseURL:  "https://api.telegram.org/bot" + token,
+		redactor: cfg.Redactor(),
+	}
+
+	err := bot.sendChatAction(42, "typing")
+	if err == nil || strings.Contains(err.Error(), token) {
+		t.Fatalf("expected an error without the token, got %v", err)
+	}
+	if err := bot.sendMessage(42, "leaked "+token); err != nil {
+		t.Fatalf("sendMessage error: %v", err)
+	}
+	if strings.Contains(sent, token) {
+		t.Fatalf("expected reply text to be redacted, got %q", sent)
+	}
+}
+
+func TestRunStopsOnPollingConflict(t *testing.T) {
+	conflict := func(description string) *http.Client {
+		return &http.Client{
+			Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusConflict,
+					Body:       io.NopCloser(bytes.NewBufferString(`{"ok":false,"error_code":409,"description":"` + description + `"}`)),
+					Header:     make(http.Header),
+				}, nil
+			}),
+		}
+	}
+	newBot := func(client *http.Client) *Bot {
+		return &Bot{
+			config:  config.Config{DataDir: t.TempDir(), TelegramPollInterval: time.Millisecond},
+			client:  client,
+			baseURL: "http://example.com",
+			queue:   newJobQueue(4),
+			jobs:    map[messageKey]*job{},
+		}
+	}
+
+	bot := newBot(conflict("Conflict: can't use getUpdates method while webhook is active; use deleteWebhook to delete the webhook first"))
+	if err := bot.Run(context.Background()); !errors.Is(err, ErrConflict) || !strings.Contains(err.Error(), "deleteWebhook") {
+		t.Fatalf("expected a webhook conflict, got %v", err)
+	}
+
+	saved := conflictTimeout
+	conflictTimeout = 20 * time.Millisecond
+	defer func() { conflictTimeout = saved }()
+	bot = newBot(conflict("Conflict: terminated by other getUpdates request; make sure that only one bot instance is running"))
+	if err := bot.Run(context.Background()); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected a polling conflict, got %v", err)
+	}
+}
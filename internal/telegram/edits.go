@@ -0,0 +1,235 @@
+package telegram
+
+import (
+	"strconv"
+	"strings"
+)
+
+// maxTrackedJobs bounds how many message-to-job links are remembered for
+// handling later edits.
+const maxTrackedJobs = 256
+
+const editCallbackPrefix = "edit:"
+
+type jobStatus int
+
+const (
+	jobQueued jobStatus = iota
+	jobRunning
+	jobDone
+)
+
+type messageKey struct {
+	chatID    int64
+	messageID int
+}
+
+func (b *Bot) trackJob(j *job) {
+	key := messageKey{chatID: j.chatID, messageID: j.messageID}
+	b.stateMu.Lock()
+	defer b.stateMu.Unlock()
+	b.jobs[key] = j
+	b.jobOrder = append(b.jobOrder, key)
+	for len(b.jobOrder) > maxTrackedJobs {
+		oldest := b.jobOrder[0]
+		b.jobOrder = b.jobOrder[1:]
+		tracked, ok := b.jobs[oldest]
+		if !ok {
+			continue
+		}
+		if tracked.status != jobDone {
+			// Unfinished jobs stay reachable; the queue bounds how many exist.
+			b.jobOrder = append(b.jobOrder, oldest)
+			continue
+		}
+		delete(b.jobs, oldest)
+	}
+}
+
+func (b *Bot) trackedJob(chatID int64, messageID int) *job {
+	b.stateMu.Lock()
+	defer b.stateMu.Unlock()
+	return b.jobs[messageKey{chatID: chatID, messageID: messageID}]
+}
+
+// handleEdit applies TELEGRAM_EDIT_POLICY to an edited message. It returns
+// false when the edit should be processed like a new message.
+//
+//   - ask:    queued jobs get the new text; running or finished jobs get a
+//     button offering to (cancel and) rerun with the edited text.
+//   - auto:   queued jobs get the new text; running jobs are canceled and
+//     rerun; finished jobs are left alone.
+//   - ignore: edits are logged and dropped.
+//   - new:    edits are treated as new messages.
+func (b *Bot) handleEdit(msg *Message, trace string) bool {
+	policy := b.cfg().TelegramEditPolicy
+	if policy == "new" {
+		return false
+	}
+	chatID := msg.Chat.ID
+	if strings.HasPrefix(strings.TrimSpace(msg.Text), "/") {
+		if b.logger != nil {
+			b.logger.Infof("telegram edit ignored: %s chat_id=%d message_id=%d reason=command", trace, chatID, msg.MessageID)
+		}
+		return true
+	}
+	j := b.trackedJob(chatID, msg.MessageID)
+	if j == nil || policy == "ignore" {
+		if b.logger != nil {
+			b.logger.Infof("telegram edit ignored: %s chat_id=%d message_id=%d policy=%s tracked=%t", trace, chatID, msg.MessageID, policy, j != nil)
+		}
+		return true
+	}
+
+	// An edit may add or remove the "!" prefix, which picks the lane.
+	text, prio := b.messagePriority(msg)
+	b.stateMu.Lock()
+	status := j.status
+	switch status {
+	case jobQueued:
+		j.text = text
+		b.stateMu.Unlock()
+		if b.queue.reprioritize(j, prio) {
+			b.refreshQueuedStatus()
+		}
+		if b.logger != nil {
+			b.logger.Infof("telegram edit applied: %s job=%s status=queued", trace, j.trace)
+		}
+		b.reply(chatID, "已更新排队中的任务内容。", trace)
+		return true
+	case jobRunning:
+		if policy == "auto" {
+			j.superseded = true
+			cancel := j.cancel
+			b.stateMu.Unlock()
+			if cancel != nil {
+				cancel()
+			}
+			b.rerunEdited(chatID, msg.MessageID, text, prio, trace, "已取消正在执行的任务，按修改后的内容重新排队。")
+			return true
+		}
+		j.pendingEdit, j.pendingPriority = text, prio
+		b.stateMu.Unlock()
+		b.offerRerun(chatID, msg.MessageID, "原消息的任务正在执行。是否取消并按修改后的内容重新执行？", "取消并重新执行", trace)
+		return true
+	default:
+		if policy == "auto" {
+			b.stateMu.Unlock()
+			if b.logger != nil {
+				b.logger.Infof("telegram edit ignored: %s job=%s status=done policy=auto", trace, j.trace)
+			}
+			return true
+		}
+		j.pendingEdit, j.pendingPriority = text, prio
+		b.stateMu.Unlock()
+		b.offerRerun(chatID, msg.MessageID, "原消息已处理完成。是否按修改后的内容重新执行？", "重新执行", trace)
+		return true
+	}
+}
+
+func (b *Bot) offerRerun(chatID int64, messageID int, text, button, trace string) {
+	markup := &inlineKeyboardMarkup{InlineKeyboard: [][]inlineKeyboardButton{{
+		{Text: button, CallbackData: editCallbackPrefix + strconv.Itoa(messageID)},
+	}}}
+	if _, err := b.sendMessageWithOptions(chatID, text, messageOptions{markup: markup}); err != nil && b.logger != nil {
+		b.logger.Errorf("telegram sendMessage failed: %s err=%v", trace, err)
+	}
+}
+
+func (b *Bot) handleCallback(cb *CallbackQuery, trace string) {
+	answer := func(text string) {
+		if err := b.answerCallbackQuery(cb.ID, text); err != nil && b.logger != nil {
+			b.logger.Warnf("telegram answerCallbackQuery failed: %s err=%v", trace, err)
+		}
+	}
+	if cb.Message == nil {
+		answer("")
+		return
+	}
+	chatID := cb.Message.Chat.ID
+	if !isAllowedChat(b.cfg().TelegramAllowedChatID, chatID) {
+		if b.logger != nil {
+			b.logger.Warnf("telegram callback ignored: %s chat_id=%d allowed=%q", trace, chatID, b.cfg().TelegramAllowedChatID)
+		}
+		answer("")
+		return
+	}
+	if !strings.HasPrefix(cb.Data, editCallbackPrefix) {
+		answer("未知操作。")
+		return
+	}
+	messageID, err := strconv.Atoi(strings.TrimPrefix(cb.Data, editCallbackPrefix))
+	if err != nil {
+		answer("未知操作。")
+		return
+	}
+
+	j := b.trackedJob(chatID, messageID)
+	if j == nil {
+		answer("该任务已过期。")
+		return
+	}
+	// In group chats only the sender of the edited message, or an admin,
+	// may cancel and rerun it.
+	if cb.From.ID != j.userID && !b.isAdmin(cb.From.ID) {
+		if b.logger != nil {
+			b.logger.Warnf("telegram callback rejected: %s chat_id=%d user_id=%d job=%s", trace, chatID, cb.From.ID, j.trace)
+		}
+		answer("只有原发送者可以操作。")
+		return
+	}
+	b.stateMu.Lock()
+	text, prio := j.pendingEdit, j.pendingPriority
+	j.pendingEdit = ""
+	var cancel func()
+	switch j.status {
+	case jobRunning:
+		j.superseded = true
+		cancel = j.cancel
+	case jobQueued:
+		// The job has not started yet, so the edit can simply replace it.
+		if text != "" {
+			j.text = text
+		}
+	}
+	status := j.status
+	b.stateMu.Unlock()
+
+	if text == "" {
+		answer("该操作已处理。")
+		return
+	}
+	answer("")
+	if err := b.editMessageText(chatID, cb.Message.MessageID, "已确认。"); err != nil && b.logger != nil {
+		b.logger.Warnf("telegram editMessageText failed: %s err=%v", trace, err)
+	}
+	if status == jobQueued {
+		if b.queue.reprioritize(j, prio) {
+			b.refreshQueuedStatus()
+		}
+		b.reply(chatID, "已更新排队中的任务内容。", trace)
+		return
+	}
+	if cancel != nil {
+		cancel()
+	}
+	b.rerunEdited(chatID, messageID, text, prio, trace, "已按修改后的内容重新排队。")
+}
+
+// rerunEdited queues the edited text, already stripped of its "!" prefix,
+// in the lane the edit picked.
+func (b *Bot) rerunEdited(chatID int64, messageID int, text string, prio priority, trace, ack string) {
+	if b.logger != nil {
+		b.logger.Infof("telegram edit rerun: %s chat_id=%d message_id=%d", trace, chatID, messageID)
+	}
+	rerun := &job{chatID: chatID, messageID: messageID, text: text, trace: trace, priority: prio}
+	if prev := b.trackedJob(chatID, messageID); prev != nil {
+		rerun.quoted = prev.quoted
+		rerun.userID = prev.userID
+	}
+	if !b.enqueueJob(rerun) {
+		b.reply(chatID, "队列已满，请稍后再试。", trace)
+		return
+	}
+	b.reply(chatID, ack, trace)
+}
@@ -0,0 +1,164 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"enoch/internal/config"
+)
+
+type sentRequest struct {
+	method  string
+	payload map[string]interface{}
+}
+
+func newRecordingBot(policy string) (*Bot, *[]sentRequest) {
+	var sent []sentRequest
+	client := &http.Client{
+		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			payload := map[string]interface{}{}
+			body, _ := io.ReadAll(r.Body)
+			_ = json.Unmarshal(body, &payload)
+			sent = append(sent, sentRequest{method: r.URL.Path[1:], payload: payload})
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true,"result":{"message_id":99}}`)),
+				Header:     make(http.Header),
+			}, nil
+		}),
+	}
+	bot := &Bot{
+		config:  config.Config{TelegramEditPolicy: policy},
+		client:  client,
+		baseURL: "http://example.com",
+		queue:   newJobQueue(4),
+		jobs:    map[messageKey]*job{},
+	}
+	return bot, &sent
+}
+
+func TestHandleEditReplacesQueuedText(t *testing.T) {
+	bot, sent := newRecordingBot("ask")
+	queued := &job{chatID: 1, messageID: 7, text: "helo", trace: "update_id=1"}
+	if !bot.enqueueJob(queued) {
+		t.Fatalf("enqueue failed")
+	}
+
+	handled := bot.handleEdit(&Message{MessageID: 7, Text: "hello", Chat: Chat{ID: 1}}, "update_id=2")
+	if !handled {
+		t.Fatalf("expected edit to be handled")
+	}
+	if queued.text != "hello" {
+		t.Fatalf("expected queued text replaced, got %q", queued.text)
+	}
+	if bot.queue.len() != 1 {
+		t.Fatalf("expected no new job, queue len %d", bot.queue.len())
+	}
+	if len(*sent) != 1 || (*sent)[0].method != "sendMessage" {
+		t.Fatalf("expected one acknowledgment, got %+v", *sent)
+	}
+}
+
+func TestHandleEditOffersRerunWhenDone(t *testing.T) {
+	bot, sent := newRecordingBot("ask")
+	done := &job{chatID: 1, messageID: 7, text: "helo", trace: "update_id=1", status: jobDone}
+	bot.trackJob(done)
+
+	bot.handleEdit(&Message{MessageID: 7, Text: "hello", Chat: Chat{ID: 1}}, "update_id=2")
+	if done.pendingEdit != "hello" {
+		t.Fatalf("expected pending edit, got %q", done.pendingEdit)
+	}
+	if len(*sent) != 1 || (*sent)[0].payload["reply_markup"] == nil {
+		t.Fatalf("expected rerun button, got %+v", *sent)
+	}
+
+	bot.handleCallback(&CallbackQuery{ID: "cb", Data: "edit:7", Message: &Message{MessageID: 99, Chat: Chat{ID: 1}}}, "update_id=3")
+	if bot.queue.len() != 1 {
+		t.Fatalf("expected rerun to be queued, len %d", bot.queue.len())
+	}
+	if rerun := bot.trackedJob(1, 7); rerun == done || rerun.text != "hello" {
+		t.Fatalf("expected tracked job to be the rerun, got %+v", rerun)
+	}
+}
+
+func TestHandleEditAutoCancelsRunning(t *testing.T) {
+	bot, _ := newRecordingBot("auto")
+	canceled := false
+	running := &job{chatID: 1, messageID: 7, text: "helo", trace: "update_id=1", status: jobRunning}
+	running.cancel = func() { canceled = true }
+	bot.trackJob(running)
+
+	bot.handleEdit(&Message{MessageID: 7, Text: "hello", Chat: Chat{ID: 1}}, "update_id=2")
+	if !canceled || !running.superseded {
+		t.Fatalf("expected running job to be canceled and superseded")
+	}
+	if bot.queue.len() != 1 {
+		t.Fatalf("expected rerun to be queued, len %d", bot.queue.len())
+	}
+}
+
+func TestHandleEditNewPolicyFallsThrough(t *testing.T) {
+	bot, _ := newRecordingBot("new")
+	if bot.handleEdit(&Message{MessageID: 7, Text: "hello", Chat: Chat{ID: 1}}, "update_id=2") {
+		t.Fatalf("expected edit to be processed as new message")
+	}
+}
+
+func TestHandleEditAppliesPriorityPrefix(t *testing.T) {
+	bot, _ := newRecordingBot("ask")
+	first := &job{chatID: 1, messageID: 6, text: "first", trace: "update_id=1"}
+	edited := &job{chatID: 1, messageID: 7, text: "fix x", trace: "update_id=2"}
+	bot.enqueueJob(first)
+	bot.enqueueJob(edited)
+
+	bot.handleEdit(&Message{MessageID: 7, Text: "! fix y", Chat: Chat{ID: 1}}, "update_id=3")
+	if edited.text != "fix y" || edited.priority != priorityHigh {
+		t.Fatalf("expected the prefix stripped and the lane raised, got %q %s", edited.text, edited.priority)
+	}
+	if queued := bot.queue.snapshot(); queued[0] != edited {
+		t.Fatalf("expected the edited job to move ahead")
+	}
+
+	bot.handleEdit(&Message{MessageID: 7, Text: "fix z", Chat: Chat{ID: 1}}, "update_id=4")
+	if edited.text != "fix z" || edited.priority != priorityNormal {
+		t.Fatalf("expected the normal lane again, got %q %s", edited.text, edited.priority)
+	}
+	if queued := bot.queue.snapshot(); queued[1] != edited {
+		t.Fatalf("expected the edited job back behind the first")
+	}
+
+	done := &job{chatID: 1, messageID: 8, text: "old", trace: "update_id=5", status: jobDone}
+	bot.trackJob(done)
+	bot.handleEdit(&Message{MessageID: 8, Text: "!again", Chat: Chat{ID: 1}}, "update_id=6")
+	bot.handleCallback(&CallbackQuery{ID: "cb", Data: "edit:8", Message: &Message{MessageID: 99, Chat: Chat{ID: 1}}}, "update_id=7")
+	if rerun := bot.trackedJob(1, 8); rerun == done || rerun.text != "again" || rerun.priority != priorityHigh {
+		t.Fatalf("expected a high-priority rerun without the prefix, got %+v", rerun)
+	}
+}
+
+func TestCallbackOnlyForSenderOrAdmin(t *testing.T) {
+	bot, sent := newRecordingBot("ask")
+	bot.config.TelegramAdminIDs = []int64{30}
+	canceled := false
+	running := &job{chatID: 1, userID: 10, messageID: 7, text: "helo", trace: "update_id=1", status: jobRunning}
+	running.cancel = func() { canceled = true }
+	bot.trackJob(running)
+	bot.handleEdit(&Message{MessageID: 7, Text: "hello", Chat: Chat{ID: 1}, From: &User{ID: 10}}, "update_id=2")
+
+	*sent = nil
+	bot.handleCallback(&CallbackQuery{ID: "cb", From: User{ID: 20}, Data: "edit:7", Message: &Message{MessageID: 99, Chat: Chat{ID: 1}}}, "update_id=3")
+	if canceled || bot.queue.len() != 0 || running.pendingEdit != "hello" {
+		t.Fatalf("expected another member to be refused")
+	}
+	if len(*sent) != 1 || (*sent)[0].method != "answerCallbackQuery" || (*sent)[0].payload["text"] != "只有原发送者可以操作。" {
+		t.Fatalf("expected a refusal answer, got %+v", *sent)
+	}
+
+	bot.handleCallback(&CallbackQuery{ID: "cb", From: User{ID: 30}, Data: "edit:7", Message: &Message{MessageID: 99, Chat: Chat{ID: 1}}}, "update_id=4")
+	if !canceled || bot.queue.len() != 1 {
+		t.Fatalf("expected an admin to cancel and rerun")
+	}
+}
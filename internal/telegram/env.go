@@ -0,0 +1,112 @@
+package telegram
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"enoch/internal/agent"
+	"enoch/internal/codex"
+	"enoch/internal/settings"
+)
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (b *Bot) handleEnv(chatID, userID int64, args []string, trace string) {
+	if len(args) == 0 {
+		// The environment holds the host's configuration (database URLs,
+		// internal hosts) that masking by name does not catch.
+		if !b.requireAdmin(chatID, userID, "查看环境变量", trace) {
+			return
+		}
+		b.sendEnv(chatID, trace)
+		return
+	}
+	switch strings.ToLower(args[0]) {
+	case "set":
+		if len(args) < 2 || !strings.Contains(args[1], "=") {
+			b.reply(chatID, "用法: /env set NAME=VALUE", trace)
+			return
+		}
+		assignment := strings.Join(args[1:], " ")
+		idx := strings.Index(assignment, "=")
+		name, value := assignment[:idx], assignment[idx+1:]
+		if !envNamePattern.MatchString(name) {
+			b.reply(chatID, fmt.Sprintf("变量名无效：%s", name), trace)
+			return
+		}
+		allowed := b.cfg().CodexEnvChatAllow
+		if codex.IsBotSecret(name) || !settings.AllowedEnv(allowed, name) {
+			if len(allowed) == 0 {
+				b.reply(chatID, "管理员未开放环境变量设置。", trace)
+				return
+			}
+			b.reply(chatID, fmt.Sprintf("不允许设置 %s\n允许的变量：%s", name, strings.Join(allowed, ", ")), trace)
+			return
+		}
+		b.updateChat(chatID, trace, fmt.Sprintf("已设置 %s。", name), func(c *settings.Chat) {
+			env := make(map[string]string, len(c.Env)+1)
+			for k, v := range c.Env {
+				env[k] = v
+			}
+			env[name] = value
+			c.Env = env
+		})
+	case "unset":
+		if len(args) < 2 {
+			b.reply(chatID, "用法: /env unset NAME", trace)
+			return
+		}
+		name := args[1]
+		if !envNamePattern.MatchString(name) {
+			b.reply(chatID, fmt.Sprintf("变量名无效：%s", name), trace)
+			return
+		}
+		b.updateChat(chatID, trace, fmt.Sprintf("已移除 %s。", name), func(c *settings.Chat) {
+			env := map[string]string{}
+			for k, v := range c.Env {
+				if k != name {
+					env[k] = v
+				}
+			}
+			if len(env) == 0 {
+				env = nil
+			}
+			c.Env = env
+		})
+	default:
+		b.reply(chatID, "用法: /env | /env set NAME=VALUE | /env unset NAME", trace)
+	}
+}
+
+// sendEnv shows the redacted environment the chat's backend would run with.
+func (b *Bot) sendEnv(chatID int64, trace string) {
+	backend := b.agentFor(chatID)
+	reporter, ok := backend.(agent.EnvReporter)
+	if !ok {
+		b.reply(chatID, fmt.Sprintf("后端 %s 不启动子进程，没有环境变量。", backend.Name()), trace)
+		return
+	}
+	extra := b.envFor(chatID)
+	env := codex.RedactEnv(reporter.Environment(extra))
+	sort.Strings(env)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("后端 %s 的环境变量（%d 个，敏感值已隐藏）:\n", backend.Name(), len(env)))
+	for _, entry := range env {
+		sb.WriteString(entry)
+		sb.WriteString("\n")
+	}
+	if len(extra) > 0 {
+		names := make([]string, 0, len(extra))
+		for name := range extra {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		sb.WriteString("\n本 chat 额外设置：" + strings.Join(names, ", "))
+	}
+	if err := b.sendTextOrDocument(chatID, "env.txt", strings.TrimSpace(sb.String())); err != nil && b.logger != nil {
+		b.logger.Errorf("telegram sendMessage failed: %s err=%v", trace, err)
+	}
+}
@@ -1,6 +1,10 @@
 package telegram
 
-import "testing"
+import (
+	"testing"
+
+	"enoch/internal/config"
+)
 
 func TestIsAllowedChat(t *testing.T) {
 	if !isAllowedChat("", 123) {
@@ -40,3 +44,33 @@ func TestSplitMessage(t *testing.T) {
 		t.Fatalf("unexpected chunks: %#v", chunks)
 	}
 }
+
+func TestIsScheduleSubcommand(t *testing.T) {
+	if !isScheduleSubcommand("LIST") || !isScheduleSubcommand("delete") {
+		t.Fatalf("expected subcommands to be detected")
+	}
+	if isScheduleSubcommand("\"0") || isScheduleSubcommand("@daily") {
+		t.Fatalf("spec should not be treated as subcommand")
+	}
+}
+
+func TestMessagePriority(t *testing.T) {
+	bot := &Bot{config: config.Config{TelegramAdminIDs: []int64{7}}}
+	cases := []struct {
+		msg  *Message
+		text string
+		prio priority
+	}{
+		{&Message{Text: "hello", From: &User{ID: 1}}, "hello", priorityNormal},
+		{&Message{Text: "! hurry", From: &User{ID: 1}}, "hurry", priorityHigh},
+		{&Message{Text: "!", From: &User{ID: 1}}, "!", priorityNormal},
+		{&Message{Text: "from admin", From: &User{ID: 7}}, "from admin", priorityHigh},
+		{&Message{Text: "anonymous"}, "anonymous", priorityNormal},
+	}
+	for _, tc := range cases {
+		text, prio := bot.messagePriority(tc.msg)
+		if text != tc.text || prio != tc.prio {
+			t.Fatalf("%q: expected %q/%s, got %q/%s", tc.msg.Text, tc.text, tc.prio, text, prio)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package telegram
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"enoch/internal/codex"
+)
+
+// maxLogDocument stays below Telegram's 50 MB upload limit.
+const maxLogDocument = 45 * 1024 * 1024
+
+// truncatedNotice is appended to replies whose output was cut to excerpts.
+func truncatedNotice(trace string) string {
+	return fmt.Sprintf("\n\n（输出过长，仅显示开头和结尾；发送 /log %s 获取完整日志）", trace)
+}
+
+func (b *Bot) handleLog(chatID int64, args []string, trace string) {
+	if len(args) == 0 {
+		b.reply(chatID, "用法: /log <trace>，如 /log update_id=123 或 /log 123", trace)
+		return
+	}
+	target := strings.TrimSpace(args[0])
+	if isDigits(target) {
+		target = "update_id=" + target
+	}
+	// Logs are only served to the chat that ran the job; other chats are
+	// told nothing exists.
+	if !b.ownsTrace(chatID, target) {
+		b.reply(chatID, fmt.Sprintf("未找到 %s 的完整日志（只有输出超过上限时才会保存）。", target), trace)
+		return
+	}
+
+	sent := 0
+	for _, stream := range []string{"", "stderr"} {
+		path := codex.LogPath(b.cfg().CodexOutputDir, target, stream)
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.Size() > maxLogDocument {
+			b.reply(chatID, fmt.Sprintf("日志过大（%d 字节），请在服务器上查看：%s", info.Size(), path), trace)
+			sent++
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if b.logger != nil {
+				b.logger.Errorf("job log read failed: %s path=%s err=%v", trace, path, err)
+			}
+			continue
+		}
+		if _, err := b.sendDocument(chatID, 0, filepath.Base(path), data); err != nil {
+			if b.logger != nil {
+				b.logger.Errorf("telegram sendDocument failed: %s err=%v", trace, err)
+			}
+			b.reply(chatID, "发送日志失败，请稍后重试。", trace)
+			return
+		}
+		sent++
+	}
+	if sent == 0 {
+		b.reply(chatID, fmt.Sprintf("未找到 %s 的完整日志（只有输出超过上限时才会保存）。", target), trace)
+	}
+}
+
+// ownsTrace reports whether the latest job with the trace ran in chatID,
+// according to the job history.
+func (b *Bot) ownsTrace(chatID int64, trace string) bool {
+	if b.history == nil {
+		return false
+	}
+	for _, entry := range b.history.Recent(0, 0) {
+		if entry.Trace == trace {
+			return entry.ChatID == chatID
+		}
+	}
+	return false
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,211 @@
+package telegram
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"enoch/internal/history"
+	"enoch/internal/workspace"
+)
+
+const recentJobs = 10
+
+// startHistory records the job as running. The returned entry is filled in
+// by processJob and saved by finishHistory.
+func (b *Bot) startHistory(j *job, prompt string) *history.Job {
+	entry := &history.Job{
+		ChatID: j.chatID,
+		UserID: j.userID,
+		Trace:  j.trace,
+		Prompt: prompt,
+	}
+	if b.history == nil {
+		return entry
+	}
+	started, err := b.history.Start(*entry)
+	if err != nil {
+		if b.logger != nil {
+			b.logger.Errorf("job history failed: %s err=%v", j.trace, err)
+		}
+		return entry
+	}
+	return &started
+}
+
+func (b *Bot) finishHistory(entry *history.Job, output string) {
+	if b.history == nil || entry.ID == 0 {
+		return
+	}
+	if entry.Status == history.StatusRunning {
+		entry.Status = history.StatusFailed
+	}
+	if err := b.history.Finish(*entry, output); err != nil && b.logger != nil {
+		b.logger.Errorf("job history failed: %s job_id=%d err=%v", entry.Trace, entry.ID, err)
+	}
+}
+
+// findJob resolves a job id ("12" or "#12") or trace within the chat.
+func (b *Bot) findJob(chatID int64, arg string) (history.Job, bool) {
+	arg = strings.TrimPrefix(strings.TrimSpace(arg), "#")
+	if id, err := strconv.Atoi(arg); err == nil {
+		entry, ok := b.history.Get(id)
+		return entry, ok && entry.ChatID == chatID
+	}
+	for _, entry := range b.history.Recent(chatID, 0) {
+		if entry.Trace == arg {
+			return entry, true
+		}
+	}
+	return history.Job{}, false
+}
+
+func (b *Bot) handleJobs(chatID int64, trace string) {
+	if b.history == nil {
+		b.reply(chatID, "未启用任务历史。", trace)
+		return
+	}
+	entries := b.history.Recent(chatID, recentJobs)
+	if len(entries) == 0 {
+		b.reply(chatID, "暂无任务记录。", trace)
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString("最近的任务：\n")
+	for _, entry := range entries {
+		sb.WriteString(fmt.Sprintf("#%d %s %s · %s · %s\n",
+			entry.ID, jobStatusLabel(entry.Status), entry.Start.Format("01-02 15:04"),
+			formatElapsed(entry.Duration()), previewPrompt(entry.Prompt, 40)))
+	}
+	sb.WriteString("\n/job <编号> 查看详情，/job <编号> output 重新发送答复，/rerun <编号> 重新执行")
+	b.reply(chatID, sb.String(), trace)
+}
+
+func (b *Bot) handleJob(chatID int64, args []string, trace string) {
+	if b.history == nil {
+		b.reply(chatID, "未启用任务历史。", trace)
+		return
+	}
+	if len(args) == 0 {
+		b.reply(chatID, "用法: /job <编号|trace> [output]", trace)
+		return
+	}
+	entry, ok := b.findJob(chatID, args[0])
+	if !ok {
+		b.reply(chatID, fmt.Sprintf("未找到任务 %s。", args[0]), trace)
+		return
+	}
+	if len(args) > 1 && strings.EqualFold(args[1], "output") {
+		b.resendOutput(chatID, entry, trace)
+		return
+	}
+	b.reply(chatID, formatJob(entry), trace)
+}
+
+func (b *Bot) resendOutput(chatID int64, entry history.Job, trace string) {
+	if entry.OutputBytes == 0 {
+		b.reply(chatID, fmt.Sprintf("任务 #%d 没有保存的答复。", entry.ID), trace)
+		return
+	}
+	output, err := b.history.Output(entry.ID)
+	if err != nil {
+		if b.logger != nil {
+			b.logger.Errorf("job output read failed: %s job_id=%d err=%v", trace, entry.ID, err)
+		}
+		b.reply(chatID, fmt.Sprintf("读取任务 #%d 的答复失败。", entry.ID), trace)
+		return
+	}
+	if _, err := b.sendReply(chatID, 0, output); err != nil && b.logger != nil {
+		b.logger.Errorf("telegram sendMessage failed: %s err=%v", trace, err)
+	}
+}
+
+func (b *Bot) handleRerun(msg *Message, args []string, trace string) {
+	chatID := msg.Chat.ID
+	if b.history == nil {
+		b.reply(chatID, "未启用任务历史。", trace)
+		return
+	}
+	if len(args) == 0 {
+		b.reply(chatID, "用法: /rerun <编号|trace>", trace)
+		return
+	}
+	entry, ok := b.findJob(chatID, args[0])
+	if !ok {
+		b.reply(chatID, fmt.Sprintf("未找到任务 %s。", args[0]), trace)
+		return
+	}
+	queued := &job{
+		chatID:    chatID,
+		userID:    senderID(msg),
+		messageID: msg.MessageID,
+		text:      entry.Prompt,
+		trace:     trace,
+		header:    fmt.Sprintf("🔁 重新执行任务 #%d", entry.ID),
+	}
+	if !b.enqueueJob(queued) {
+		b.reply(chatID, "队列已满，请稍后再试。", trace)
+		return
+	}
+	if b.logger != nil {
+		b.logger.Infof("job rerun: %s job_id=%d", trace, entry.ID)
+	}
+	b.sendQueuedStatus(queued)
+}
+
+func formatJob(entry history.Job) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("任务 #%d（%s）\n", entry.ID, entry.Trace))
+	sb.WriteString(fmt.Sprintf("状态：%s\n", jobStatusLabel(entry.Status)))
+	if entry.Backend != "" {
+		sb.WriteString(fmt.Sprintf("后端：%s\n", entry.Backend))
+	}
+	sb.WriteString(fmt.Sprintf("开始：%s\n", entry.Start.Format("2006-01-02 15:04:05")))
+	if !entry.End.IsZero() {
+		sb.WriteString(fmt.Sprintf("结束：%s（用时 %s）\n", entry.End.Format("2006-01-02 15:04:05"), formatElapsed(entry.Duration())))
+	}
+	if entry.Status == history.StatusFailed {
+		sb.WriteString(fmt.Sprintf("退出码：%d\n", entry.ExitCode))
+	}
+	if entry.ErrorClass != "" {
+		sb.WriteString(fmt.Sprintf("错误类型：%s\n", entry.ErrorClass))
+	}
+	if entry.Attempts > 1 {
+		sb.WriteString(fmt.Sprintf("尝试次数：%d\n", entry.Attempts))
+	}
+	if entry.Note != "" {
+		sb.WriteString(fmt.Sprintf("说明：%s\n", entry.Note))
+	}
+	output := workspace.FormatBytes(int64(entry.OutputBytes))
+	if entry.Truncated {
+		output += fmt.Sprintf("（已截断，/log %s 获取完整日志）", entry.Trace)
+	}
+	sb.WriteString(fmt.Sprintf("答复大小：%s\n", output))
+	sb.WriteString("\n提示词：\n")
+	sb.WriteString(previewPrompt(entry.Prompt, 500))
+	return sb.String()
+}
+
+func jobStatusLabel(status string) string {
+	switch status {
+	case history.StatusRunning:
+		return "⏳ 运行中"
+	case history.StatusOK:
+		return "✅ 完成"
+	case history.StatusFailed:
+		return "❌ 失败"
+	case history.StatusCanceled:
+		return "⏹ 已取消"
+	case history.StatusRejected:
+		return "🚫 未执行"
+	case history.StatusInterrupted:
+		return "⚠️ 中断"
+	}
+	return status
+}
+
+// previewPrompt shortens a prompt to limit bytes without splitting
+// characters.
+func previewPrompt(prompt string, limit int) string {
+	return strings.ToValidUTF8(truncateText(prompt, limit), "")
+}
@@ -0,0 +1,71 @@
+package telegram
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"enoch/internal/codex"
+	"enoch/internal/history"
+)
+
+func TestRerunQueuesStoredPrompt(t *testing.T) {
+	bot, sent := newRecordingBot("ask")
+	store, err := history.Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("open history: %v", err)
+	}
+	bot.history = store
+	entry, _ := store.Start(history.Job{ChatID: 1, Trace: "update_id=1", Prompt: "summarize the logs"})
+	entry.Status = history.StatusFailed
+	if err := store.Finish(entry, ""); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+
+	other := &Message{MessageID: 5, Chat: Chat{ID: 2}}
+	bot.handleRerun(other, []string{"1"}, "update_id=5")
+	if bot.queue.len() != 0 {
+		t.Fatalf("jobs of other chats must not be rerun")
+	}
+
+	msg := &Message{MessageID: 6, Chat: Chat{ID: 1}, From: &User{ID: 42}}
+	bot.handleRerun(msg, []string{"#1"}, "update_id=6")
+	queued := bot.queue.pop()
+	if queued == nil || queued.text != "summarize the logs" || queued.userID != 42 || queued.trace != "update_id=6" {
+		t.Fatalf("unexpected queued job: %+v", queued)
+	}
+
+	bot.handleJob(1, []string{"update_id=1"}, "update_id=7")
+	last := (*sent)[len(*sent)-1].payload["text"].(string)
+	if !strings.Contains(last, "任务 #1") || !strings.Contains(last, "summarize the logs") {
+		t.Fatalf("unexpected job details: %q", last)
+	}
+}
+
+func TestLogOnlyForOwningChat(t *testing.T) {
+	bot, sent := newRecordingBot("ask")
+	dir := t.TempDir()
+	bot.config.CodexOutputDir = dir
+	store, err := history.Open(filepath.Join(dir, "history"), 0)
+	if err != nil {
+		t.Fatalf("open history: %v", err)
+	}
+	bot.history = store
+	if _, err := store.Start(history.Job{ChatID: 1, Trace: "update_id=3", Prompt: "secret"}); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if err := os.WriteFile(codex.LogPath(dir, "update_id=3", ""), []byte("full output"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	bot.handleLog(2, []string{"3"}, "update_id=4")
+	if len(*sent) != 1 || (*sent)[0].method != "sendMessage" || !strings.Contains((*sent)[0].payload["text"].(string), "未找到") {
+		t.Fatalf("expected other chats to be refused, got %+v", *sent)
+	}
+
+	bot.handleLog(1, []string{"3"}, "update_id=5")
+	if len(*sent) != 2 || (*sent)[1].method != "sendDocument" {
+		t.Fatalf("expected the log to be sent to its chat, got %+v", *sent)
+	}
+}
@@ -0,0 +1,199 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+
+	"enoch/internal/agent"
+	"enoch/internal/config"
+	"enoch/internal/settings"
+)
+
+// chatOption is a per-chat run setting that users can change from Telegram
+// within the admin allowlist.
+type chatOption struct {
+	label   string
+	allowed []string
+	set     func(*settings.Chat, string)
+}
+
+func (b *Bot) policy() settings.Policy {
+	return settings.Policy{
+		Models:    b.cfg().CodexAllowedModels,
+		Efforts:   b.cfg().CodexAllowedEfforts,
+		Sandboxes: b.cfg().CodexAllowedSandboxes,
+		Approvals: b.cfg().CodexAllowedApprovals,
+		Profiles:  b.cfg().CodexAllowedProfiles,
+		ExtraArgs: b.cfg().CodexAllowedExtraArgs,
+		EnvNames:  b.cfg().CodexEnvChatAllow,
+	}
+}
+
+// envFor returns the chat's extra environment variables.
+func (b *Bot) envFor(chatID int64) map[string]string {
+	if b.chats == nil {
+		return nil
+	}
+	return b.policy().Env(b.chats.Get(chatID))
+}
+
+// overridesFor returns the chat's effective run settings.
+func (b *Bot) overridesFor(chatID int64) agent.Overrides {
+	if b.chats == nil {
+		return agent.Overrides{}
+	}
+	return b.policy().Overrides(b.chats.Get(chatID))
+}
+
+func (b *Bot) handleModel(chatID int64, args []string, trace string) {
+	policy := b.policy()
+	if len(args) == 0 {
+		b.reply(chatID, b.formatChatSettings(chatID)+"\n用法: /model <模型> | /model effort <强度> | /model default", trace)
+		return
+	}
+	if strings.EqualFold(args[0], "effort") {
+		if len(args) < 2 {
+			b.reply(chatID, "用法: /model effort <强度> | /model effort default", trace)
+			return
+		}
+		b.setChatOption(chatID, chatOption{
+			label:   "推理强度",
+			allowed: policy.Efforts,
+			set:     func(c *settings.Chat, v string) { c.ReasoningEffort = v },
+		}, args[1], trace)
+		return
+	}
+	b.setChatOption(chatID, chatOption{
+		label:   "模型",
+		allowed: policy.Models,
+		set:     func(c *settings.Chat, v string) { c.Model = v },
+	}, args[0], trace)
+}
+
+func (b *Bot) handleSandbox(chatID int64, args []string, trace string) {
+	policy := b.policy()
+	if len(args) == 0 {
+		b.reply(chatID, b.formatChatSettings(chatID)+"\n用法: /sandbox <模式> | /sandbox approval <策略> | /sandbox default", trace)
+		return
+	}
+	if strings.EqualFold(args[0], "approval") {
+		if len(args) < 2 {
+			b.reply(chatID, "用法: /sandbox approval <策略> | /sandbox approval default", trace)
+			return
+		}
+		b.setChatOption(chatID, chatOption{
+			label:   "审批策略",
+			allowed: policy.Approvals,
+			set:     func(c *settings.Chat, v string) { c.Approval = v },
+		}, args[1], trace)
+		return
+	}
+	b.setChatOption(chatID, chatOption{
+		label:   "沙箱模式",
+		allowed: policy.Sandboxes,
+		set:     func(c *settings.Chat, v string) { c.Sandbox = v },
+	}, args[0], trace)
+}
+
+func (b *Bot) handleProfile(chatID int64, args []string, trace string) {
+	policy := b.policy()
+	if len(args) == 0 {
+		b.reply(chatID, b.formatChatSettings(chatID)+"\n用法: /profile <名称> | /profile args <参数> | /profile args clear | /profile reset", trace)
+		return
+	}
+	switch strings.ToLower(args[0]) {
+	case "reset":
+		b.updateChat(chatID, trace, "已恢复默认设置。", func(c *settings.Chat) {
+			backend := c.Backend
+			*c = settings.Chat{Backend: backend}
+		})
+		return
+	case "args":
+		if len(args) < 2 {
+			b.reply(chatID, "用法: /profile args <参数> | /profile args clear", trace)
+			return
+		}
+		if strings.EqualFold(args[1], "clear") || strings.EqualFold(args[1], "default") {
+			b.updateChat(chatID, trace, "已清除额外参数。", func(c *settings.Chat) { c.ExtraArgs = nil })
+			return
+		}
+		if len(policy.ExtraArgs) == 0 {
+			b.reply(chatID, "管理员未开放额外参数设置。", trace)
+			return
+		}
+		extra, err := config.SplitArgs(strings.Join(args[1:], " "))
+		if err == nil {
+			err = settings.CheckExtraArgs(extra, policy.ExtraArgs)
+		}
+		if err != nil {
+			b.reply(chatID, fmt.Sprintf("参数无效：%v\n允许的参数：%s", err, strings.Join(policy.ExtraArgs, ", ")), trace)
+			return
+		}
+		b.updateChat(chatID, trace, "已设置额外参数："+strings.Join(extra, " "), func(c *settings.Chat) { c.ExtraArgs = extra })
+		return
+	}
+	b.setChatOption(chatID, chatOption{
+		label:   "Profile",
+		allowed: policy.Profiles,
+		set:     func(c *settings.Chat, v string) { c.Profile = v },
+	}, args[0], trace)
+}
+
+// setChatOption validates value against the allowlist and stores it;
+// "default" clears the setting.
+func (b *Bot) setChatOption(chatID int64, opt chatOption, value, trace string) {
+	value = strings.TrimSpace(value)
+	if strings.EqualFold(value, "default") || strings.EqualFold(value, "reset") {
+		b.updateChat(chatID, trace, fmt.Sprintf("%s已恢复默认。", opt.label), func(c *settings.Chat) { opt.set(c, "") })
+		return
+	}
+	if len(opt.allowed) == 0 {
+		b.reply(chatID, fmt.Sprintf("管理员未开放%s设置。", opt.label), trace)
+		return
+	}
+	if !settings.Allowed(opt.allowed, value) {
+		b.reply(chatID, fmt.Sprintf("不允许的%s：%s\n可选：%s", opt.label, value, strings.Join(opt.allowed, ", ")), trace)
+		return
+	}
+	b.updateChat(chatID, trace, fmt.Sprintf("已设置%s：%s", opt.label, value), func(c *settings.Chat) { opt.set(c, value) })
+}
+
+// updateChat persists a settings change and confirms it with done.
+func (b *Bot) updateChat(chatID int64, trace, done string, fn func(*settings.Chat)) {
+	if b.chats == nil {
+		b.reply(chatID, "未启用 chat 设置。", trace)
+		return
+	}
+	chat, err := b.chats.Update(chatID, fn)
+	if err != nil {
+		if b.logger != nil {
+			b.logger.Errorf("chat settings save failed: %s err=%v", trace, err)
+		}
+		b.reply(chatID, "保存设置失败，请稍后重试。", trace)
+		return
+	}
+	if b.logger != nil {
+		b.logger.Infof("chat settings changed: %s chat_id=%d settings=%+v", trace, chatID, chat)
+	}
+	b.reply(chatID, done, trace)
+}
+
+func (b *Bot) formatChatSettings(chatID int64) string {
+	o := b.overridesFor(chatID)
+	orDefault := func(value string) string {
+		if value == "" {
+			return "默认"
+		}
+		return value
+	}
+	var sb strings.Builder
+	sb.WriteString("当前设置:\n")
+	sb.WriteString(fmt.Sprintf("后端：%s\n", b.agentFor(chatID).Name()))
+	sb.WriteString(fmt.Sprintf("模型：%s\n", orDefault(o.Model)))
+	sb.WriteString(fmt.Sprintf("推理强度：%s\n", orDefault(o.ReasoningEffort)))
+	sb.WriteString(fmt.Sprintf("沙箱模式：%s\n", orDefault(o.Sandbox)))
+	sb.WriteString(fmt.Sprintf("审批策略：%s\n", orDefault(o.Approval)))
+	sb.WriteString(fmt.Sprintf("Profile：%s\n", orDefault(o.Profile)))
+	sb.WriteString(fmt.Sprintf("额外参数：%s", orDefault(strings.Join(o.ExtraArgs, " "))))
+	return sb.String()
+}
@@ -0,0 +1,57 @@
+package telegram
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"enoch/internal/settings"
+)
+
+func TestHandleModelValidatesAllowlist(t *testing.T) {
+	bot, sent := newRecordingBot("ask")
+	store, err := settings.Open(filepath.Join(t.TempDir(), "chats.json"))
+	if err != nil {
+		t.Fatalf("open settings: %v", err)
+	}
+	bot.chats = store
+	bot.config.CodexAllowedModels = []string{"o3"}
+
+	bot.handleModel(1, []string{"gpt-huge"}, "update_id=1")
+	if got := store.Get(1).Model; got != "" {
+		t.Fatalf("disallowed model should not be stored, got %q", got)
+	}
+	last := (*sent)[len(*sent)-1].payload["text"].(string)
+	if !strings.Contains(last, "o3") {
+		t.Fatalf("expected allowed models in reply, got %q", last)
+	}
+
+	bot.handleModel(1, []string{"o3"}, "update_id=2")
+	if got := bot.overridesFor(1).Model; got != "o3" {
+		t.Fatalf("expected model override, got %q", got)
+	}
+
+	bot.handleModel(1, []string{"default"}, "update_id=3")
+	if got := store.Get(1).Model; got != "" {
+		t.Fatalf("expected model cleared, got %q", got)
+	}
+}
+
+func TestHandleEnvNeedsAdminAndValidNames(t *testing.T) {
+	bot, sent := newRecordingBot("ask")
+	store, err := settings.Open(filepath.Join(t.TempDir(), "chats.json"))
+	if err != nil {
+		t.Fatalf("open settings: %v", err)
+	}
+	bot.chats = store
+
+	bot.handleEnv(1, 7, nil, "update_id=1")
+	if len(*sent) != 1 || !strings.Contains((*sent)[0].payload["text"].(string), "只有管理员") {
+		t.Fatalf("expected the listing to be refused, got %+v", *sent)
+	}
+
+	bot.handleEnv(1, 7, []string{"unset", "BAD-NAME"}, "update_id=2")
+	if !strings.Contains((*sent)[1].payload["text"].(string), "变量名无效") {
+		t.Fatalf("expected an invalid name to be rejected, got %+v", (*sent)[1])
+	}
+}
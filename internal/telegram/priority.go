@@ -0,0 +1,179 @@
+package telegram
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// messagePriority picks the queue lane for a message: admins and prompts
+// starting with "!" go first. The "!" is stripped from the prompt.
+func (b *Bot) messagePriority(msg *Message) (string, priority) {
+	text := msg.Text
+	prio := priorityNormal
+	if trimmed := strings.TrimSpace(text); strings.HasPrefix(trimmed, "!") && len(trimmed) > 1 {
+		text = strings.TrimSpace(trimmed[1:])
+		prio = priorityHigh
+	}
+	if b.isAdmin(senderID(msg)) {
+		prio = priorityHigh
+	}
+	return text, prio
+}
+
+// isAdmin reports whether userID is listed in TELEGRAM_ADMIN_IDS.
+func (b *Bot) isAdmin(userID int64) bool {
+	if userID == 0 {
+		return false
+	}
+	for _, id := range b.cfg().TelegramAdminIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// requireAdmin reports whether userID is an admin and otherwise tells the
+// chat that only admins may do what action describes. Without
+// TELEGRAM_ADMIN_IDS nobody is an admin.
+func (b *Bot) requireAdmin(chatID, userID int64, action, trace string) bool {
+	if b.isAdmin(userID) {
+		return true
+	}
+	text := fmt.Sprintf("只有管理员可以%s。", action)
+	if len(b.cfg().TelegramAdminIDs) == 0 {
+		text += "（未配置 TELEGRAM_ADMIN_IDS）"
+	}
+	b.reply(chatID, text, trace)
+	return false
+}
+
+const queueUsage = "用法: /queue 查看队列；/queue top <位置> 移到队首；/queue drop <位置> 移出队列"
+
+func (b *Bot) handleQueue(msg *Message, args []string, trace string) {
+	chatID := msg.Chat.ID
+	if len(args) == 0 {
+		if !b.isAdmin(senderID(msg)) {
+			// Other chats' prompts are only shown to admins.
+			text := strings.TrimSpace(b.queueByChat(chatID))
+			if text == "" {
+				text = "队列为空。"
+			}
+			b.reply(chatID, text, trace)
+			return
+		}
+		b.reply(chatID, b.formatQueue(), trace)
+		return
+	}
+	action := strings.ToLower(args[0])
+	if action != "top" && action != "drop" {
+		b.reply(chatID, queueUsage, trace)
+		return
+	}
+	if !b.requireAdmin(chatID, senderID(msg), "调整队列", trace) {
+		return
+	}
+	if len(args) < 2 {
+		b.reply(chatID, queueUsage, trace)
+		return
+	}
+	position, err := strconv.Atoi(args[1])
+	queued := b.queue.snapshot()
+	if err != nil || position < 1 || position > len(queued) {
+		b.reply(chatID, fmt.Sprintf("无效的位置：%s（当前队列共 %d 个任务）。", args[1], len(queued)), trace)
+		return
+	}
+	target := queued[position-1]
+
+	switch action {
+	case "top":
+		if !b.queue.promote(target) {
+			b.reply(chatID, "该任务已开始执行或已移出队列。", trace)
+			return
+		}
+		if b.logger != nil {
+			b.logger.Infof("queue reordered: %s job=%s from=%d", trace, target.trace, position)
+		}
+		b.refreshQueuedStatus()
+		b.reply(chatID, fmt.Sprintf("已将 %s 移到队首。", target.trace), trace)
+	case "drop":
+		if !b.queue.remove(target) {
+			b.reply(chatID, "该任务已开始执行或已移出队列。", trace)
+			return
+		}
+		b.stateMu.Lock()
+		target.status = jobDone
+		b.stateMu.Unlock()
+		if b.logger != nil {
+			b.logger.Infof("queue dropped: %s job=%s position=%d", trace, target.trace, position)
+		}
+		if !b.updateStatus(target, "已被管理员移出队列。") && target.chatID != chatID {
+			b.reply(target.chatID, "一个排队中的任务已被管理员移出队列。", trace)
+		}
+		b.refreshQueuedStatus()
+		b.reply(chatID, fmt.Sprintf("已移出 %s。", target.trace), trace)
+	}
+}
+
+// formatQueue lists every waiting job with its position and lane.
+func (b *Bot) formatQueue() string {
+	queued := b.queue.entries()
+	if len(queued) == 0 {
+		return "队列为空。"
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("队列中共 %d 个任务：\n", len(queued)))
+	for i, e := range queued {
+		sb.WriteString(fmt.Sprintf("%d. [%s] chat %d · %s · %s\n", i+1, e.priority, e.job.chatID, e.job.trace, b.queuedPreview(e.job)))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// queueByChat describes the queue for /status: the chat's own jobs with
+// their positions and a count for every other chat.
+func (b *Bot) queueByChat(chatID int64) string {
+	queued := b.queue.entries()
+	if len(queued) == 0 {
+		return ""
+	}
+	var own []string
+	others := map[int64]int{}
+	for i, e := range queued {
+		if e.job.chatID == chatID {
+			own = append(own, fmt.Sprintf("- 第 %d 位 [%s] %s", i+1, e.priority, b.queuedPreview(e.job)))
+		} else {
+			others[e.job.chatID]++
+		}
+	}
+	var sb strings.Builder
+	if len(own) > 0 {
+		sb.WriteString("\n本 chat 排队中：\n")
+		sb.WriteString(strings.Join(own, "\n"))
+	}
+	if len(others) > 0 {
+		ids := make([]int64, 0, len(others))
+		for id := range others {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, k int) bool { return ids[i] < ids[k] })
+		sb.WriteString("\n其他 chat：")
+		for i, id := range ids {
+			if i > 0 {
+				sb.WriteString("，")
+			}
+			sb.WriteString(fmt.Sprintf("%d（%d 个）", id, others[id]))
+		}
+	}
+	return sb.String()
+}
+
+// queuedPreview returns the start of a waiting job's prompt, which edits
+// may change under stateMu.
+func (b *Bot) queuedPreview(j *job) string {
+	b.stateMu.Lock()
+	text := j.text
+	b.stateMu.Unlock()
+	return previewPrompt(text, 40)
+}
@@ -0,0 +1,196 @@
+package telegram
+
+import "sync"
+
+// priority orders the queue lanes; higher lanes run first and each lane is
+// FIFO. The zero value is the normal lane.
+type priority int
+
+const (
+	// priorityLow is used for scheduled runs.
+	priorityLow    priority = -1
+	priorityNormal priority = 0
+	// priorityHigh is used for admin messages and "!"-prefixed prompts.
+	priorityHigh priority = 1
+)
+
+func (p priority) String() string {
+	switch p {
+	case priorityLow:
+		return "低"
+	case priorityHigh:
+		return "高"
+	}
+	return "普通"
+}
+
+// jobQueue is a bounded priority queue of pending jobs. Unlike a channel it
+// lets the bot inspect and modify jobs that are still waiting.
+type jobQueue struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	items    []*job
+	capacity int
+	closed   bool
+}
+
+func newJobQueue(capacity int) *jobQueue {
+	q := &jobQueue{capacity: capacity}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push adds the job behind the others of its lane, returning false when the
+// queue is full.
+func (q *jobQueue) push(j *job) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed || q.capacity > 0 && len(q.items) >= q.capacity {
+		return false
+	}
+	q.insertLocked(j)
+	q.cond.Signal()
+	return true
+}
+
+func (q *jobQueue) insertLocked(j *job) {
+	i := len(q.items)
+	for i > 0 && q.items[i-1].priority < j.priority {
+		i--
+	}
+	q.items = append(q.items, nil)
+	copy(q.items[i+1:], q.items[i:])
+	q.items[i] = j
+}
+
+// promote moves a waiting job to the front of the queue, raising it to the
+// high lane so later high-priority jobs queue behind it.
+func (q *jobQueue) promote(j *job) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, item := range q.items {
+		if item == j {
+			copy(q.items[1:i+1], q.items[:i])
+			q.items[0] = j
+			j.priority = priorityHigh
+			return true
+		}
+	}
+	return false
+}
+
+// reprioritize moves a waiting job to the end of the given lane, reporting
+// whether it changed lanes.
+func (q *jobQueue) reprioritize(j *job, prio priority) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, item := range q.items {
+		if item == j && j.priority != prio {
+			q.items = append(q.items[:i], q.items[i+1:]...)
+			j.priority = prio
+			q.insertLocked(j)
+			return true
+		}
+	}
+	return false
+}
+
+// pop blocks until a job is available and removes it from the queue. It
+// returns nil once the queue is closed.
+func (q *jobQueue) pop() *job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.items) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return nil
+	}
+	j := q.items[0]
+	q.items[0] = nil
+	q.items = q.items[1:]
+	return j
+}
+
+// requeue puts a popped job that did not run back at the front, even after
+// the queue is closed.
+func (q *jobQueue) requeue(j *job) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.items = append([]*job{j}, q.items...)
+}
+
+// close makes push fail and pop return nil; the waiting jobs stay in the
+// queue for drain.
+func (q *jobQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+// drain removes and returns the waiting jobs in the order they would run.
+func (q *jobQueue) drain() []*job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := q.items
+	q.items = nil
+	return out
+}
+
+// remove drops a job that has not started yet.
+func (q *jobQueue) remove(j *job) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, item := range q.items {
+		if item == j {
+			q.items = append(q.items[:i], q.items[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// snapshot returns the queued jobs in the order they will run.
+func (q *jobQueue) snapshot() []*job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make([]*job, len(q.items))
+	copy(out, q.items)
+	return out
+}
+
+// queueEntry is a waiting job with its lane at the time of the snapshot.
+type queueEntry struct {
+	job      *job
+	priority priority
+}
+
+// entries is snapshot with the lanes, which may change under the queue lock.
+func (q *jobQueue) entries() []queueEntry {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make([]queueEntry, len(q.items))
+	for i, item := range q.items {
+		out[i] = queueEntry{job: item, priority: item.priority}
+	}
+	return out
+}
+
+// position returns the 1-based place of j in the queue, or 0 if absent.
+func (q *jobQueue) position(j *job) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, item := range q.items {
+		if item == j {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func (q *jobQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
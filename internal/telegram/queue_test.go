@@ -0,0 +1,111 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJobQueueFIFOAndCapacity(t *testing.T) {
+	q := newJobQueue(2)
+	first := &job{trace: "a"}
+	second := &job{trace: "b"}
+	if !q.push(first) || !q.push(second) {
+		t.Fatalf("expected pushes to succeed")
+	}
+	if q.push(&job{trace: "c"}) {
+		t.Fatalf("expected push beyond capacity to fail")
+	}
+	if q.len() != 2 {
+		t.Fatalf("unexpected len: %d", q.len())
+	}
+	if got := q.pop(); got != first {
+		t.Fatalf("expected first job, got %q", got.trace)
+	}
+	if !q.remove(second) {
+		t.Fatalf("expected remove to succeed")
+	}
+	if q.remove(second) {
+		t.Fatalf("expected second remove to fail")
+	}
+	if q.len() != 0 {
+		t.Fatalf("expected empty queue, got %d", q.len())
+	}
+}
+
+func TestJobQueuePriorityLanes(t *testing.T) {
+	q := newJobQueue(0)
+	scheduled := &job{trace: "scheduled", priority: priorityLow}
+	normal := &job{trace: "normal"}
+	urgent := &job{trace: "urgent", priority: priorityHigh}
+	later := &job{trace: "later"}
+	for _, j := range []*job{scheduled, normal, urgent, later} {
+		q.push(j)
+	}
+	if got := q.position(scheduled); got != 4 {
+		t.Fatalf("expected scheduled job last, got position %d", got)
+	}
+	if !q.promote(later) {
+		t.Fatalf("expected promote to succeed")
+	}
+	q.push(&job{trace: "urgent2", priority: priorityHigh})
+
+	var order []string
+	for q.len() > 0 {
+		order = append(order, q.pop().trace)
+	}
+	want := []string{"later", "urgent", "urgent2", "normal", "scheduled"}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("expected order %v, got %v", want, order)
+		}
+	}
+}
+
+func TestJobQueueClose(t *testing.T) {
+	q := newJobQueue(0)
+	waiting := &job{trace: "waiting"}
+	q.push(waiting)
+	if q.pop() != waiting {
+		t.Fatalf("expected the waiting job")
+	}
+
+	popped := make(chan *job)
+	go func() { popped <- q.pop() }()
+	q.close()
+	if got := <-popped; got != nil {
+		t.Fatalf("expected pop to return nil after close, got %q", got.trace)
+	}
+	if q.push(&job{trace: "late"}) {
+		t.Fatalf("expected push to fail after close")
+	}
+	q.requeue(waiting)
+	if drained := q.drain(); len(drained) != 1 || drained[0] != waiting {
+		t.Fatalf("expected the requeued job to be drained, got %v", drained)
+	}
+	if q.len() != 0 {
+		t.Fatalf("expected empty queue after drain, got %d", q.len())
+	}
+}
+
+func TestQueueCommandsNeedConfiguredAdmin(t *testing.T) {
+	bot, sent := newRecordingBot("ask")
+	if !bot.enqueueJob(&job{chatID: 2, text: "other chat secret", trace: "update_id=1"}) {
+		t.Fatalf("enqueue failed")
+	}
+	member := &Message{Chat: Chat{ID: 1}, From: &User{ID: 7}}
+
+	bot.handleQueue(member, []string{"drop", "1"}, "update_id=2")
+	if bot.queue.len() != 1 || !strings.Contains((*sent)[0].payload["text"].(string), "TELEGRAM_ADMIN_IDS") {
+		t.Fatalf("expected drop to be refused without admins, got %+v", *sent)
+	}
+	bot.handleQueue(member, nil, "update_id=3")
+	if text := (*sent)[1].payload["text"].(string); strings.Contains(text, "secret") {
+		t.Fatalf("other chats' prompts must not be shown: %q", text)
+	}
+
+	bot.config.TelegramAdminIDs = []int64{7}
+	bot.handleQueue(member, []string{"drop", "1"}, "update_id=4")
+	if bot.queue.len() != 0 {
+		t.Fatalf("expected an admin to drop the job")
+	}
+}
@@ -0,0 +1,58 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+
+	"enoch/internal/config"
+)
+
+// cfg returns the current configuration.
+func (b *Bot) cfg() config.Config {
+	b.configMu.RLock()
+	defer b.configMu.RUnlock()
+	return b.config
+}
+
+// UpdateConfig swaps in a reloaded configuration. Jobs already running keep
+// the settings they started with.
+func (b *Bot) UpdateConfig(cfg config.Config) {
+	b.configMu.Lock()
+	b.config = cfg
+	b.configMu.Unlock()
+	if b.redactor != nil {
+		b.redactor.Update(cfg.Secrets(), cfg.RedactPattern)
+	}
+}
+
+// SetReloader installs the function /reload uses to reload the config. It
+// returns the changed settings.
+func (b *Bot) SetReloader(reload func() ([]string, error)) {
+	b.configMu.Lock()
+	b.reload = reload
+	b.configMu.Unlock()
+}
+
+func (b *Bot) handleReload(msg *Message, trace string) {
+	chatID := msg.Chat.ID
+	if !b.requireAdmin(chatID, senderID(msg), "重新加载配置", trace) {
+		return
+	}
+	b.configMu.RLock()
+	reload := b.reload
+	b.configMu.RUnlock()
+	if reload == nil {
+		b.reply(chatID, "未启用配置重新加载。", trace)
+		return
+	}
+	changed, err := reload()
+	if err != nil {
+		b.reply(chatID, fmt.Sprintf("配置未重新加载：%v", err), trace)
+		return
+	}
+	if len(changed) == 0 {
+		b.reply(chatID, "配置已重新加载，没有变化。", trace)
+		return
+	}
+	b.reply(chatID, "配置已重新加载，变更项：\n- "+strings.Join(changed, "\n- "), trace)
+}
@@ -0,0 +1,132 @@
+package telegram
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"enoch/internal/scheduler"
+)
+
+const remindUsage = "用法:\n/remind in 2h 提醒内容\n/remind tomorrow 9:00 提醒内容\n/remind 2026-02-04 18:00 提醒内容\n/remind list | delete <id>"
+
+func isRemindSubcommand(name string) bool {
+	switch strings.ToLower(name) {
+	case "list", "delete":
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *Bot) handleRemindAdd(chatID int64, args, trace string) {
+	if b.scheduler == nil {
+		b.reply(chatID, "提醒未启用。", trace)
+		return
+	}
+	if args == "" {
+		b.reply(chatID, remindUsage, trace)
+		return
+	}
+	at, text, err := scheduler.ParseWhen(args, time.Now())
+	if err != nil || strings.TrimSpace(text) == "" {
+		if b.logger != nil && err != nil {
+			b.logger.Warnf("remind parse failed: %s err=%v", trace, err)
+		}
+		b.reply(chatID, remindUsage, trace)
+		return
+	}
+	entry, err := b.scheduler.AddReminder(chatID, at, text)
+	if err != nil {
+		if b.logger != nil {
+			b.logger.Warnf("remind add failed: %s err=%v", trace, err)
+		}
+		b.reply(chatID, fmt.Sprintf("无法创建提醒：%v", err), trace)
+		return
+	}
+	if b.logger != nil {
+		b.logger.Infof("remind added: %s schedule_id=%d chat_id=%d at=%s", trace, entry.ID, chatID, entry.Spec)
+	}
+
+	ack := fmt.Sprintf("已设置提醒 #%d：%s", entry.ID, formatScheduleTime(entry.NextRun))
+	if b.cfg().TelegramRemindTodo {
+		todo := fmt.Sprintf("%s (提醒 %s)", entry.Prompt, formatScheduleTime(entry.NextRun))
+		if _, err := b.memory.AddTodoOn(entry.NextRun, todo); err != nil {
+			if b.logger != nil {
+				b.logger.Errorf("memory todo failed: %s err=%v", trace, err)
+			}
+		} else {
+			ack += fmt.Sprintf("\n已记录到 %s 的 TODOs。", entry.NextRun.Format("2006-01-02"))
+		}
+	}
+	b.reply(chatID, ack, trace)
+}
+
+func (b *Bot) handleRemindList(chatID int64, trace string) {
+	if b.scheduler == nil {
+		b.reply(chatID, "提醒未启用。", trace)
+		return
+	}
+	entries := b.scheduler.List(chatID, scheduler.KindReminder)
+	if len(entries) == 0 {
+		b.reply(chatID, "暂无提醒。", trace)
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString("提醒:\n")
+	for _, entry := range entries {
+		sb.WriteString(fmt.Sprintf("#%d %s | %s\n", entry.ID, formatScheduleTime(entry.NextRun), truncateText(entry.Prompt, 80)))
+	}
+	if err := b.sendTextOrDocument(chatID, "reminders.txt", strings.TrimRight(sb.String(), "\n")); err != nil && b.logger != nil {
+		b.logger.Errorf("telegram sendMessage failed: %s err=%v", trace, err)
+	}
+}
+
+func (b *Bot) handleRemindDelete(chatID int64, args []string, trace string) {
+	if b.scheduler == nil {
+		b.reply(chatID, "提醒未启用。", trace)
+		return
+	}
+	if len(args) != 1 {
+		b.reply(chatID, remindUsage, trace)
+		return
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+	if err != nil {
+		b.reply(chatID, remindUsage, trace)
+		return
+	}
+	if err := b.scheduler.Remove(chatID, id, scheduler.KindReminder); err != nil {
+		if b.logger != nil {
+			b.logger.Warnf("remind delete failed: %s schedule_id=%d err=%v", trace, id, err)
+		}
+		b.reply(chatID, fmt.Sprintf("未找到提醒 #%d。", id), trace)
+		return
+	}
+	b.reply(chatID, fmt.Sprintf("已删除提醒 #%d。", id), trace)
+}
+
+// deliverReminder sends a due reminder and reports whether it was sent; the
+// scheduler keeps unsent reminders and retries them.
+func (b *Bot) deliverReminder(entry scheduler.Entry, trace string) bool {
+	// NextRun moves on with every retry; the spec keeps the original time.
+	due := entry.NextRun
+	if at, err := time.ParseInLocation("2006-01-02 15:04", entry.Spec, time.Local); err == nil {
+		due = at
+	}
+	text := fmt.Sprintf("⏰ 提醒 #%d：%s", entry.ID, entry.Prompt)
+	if late := time.Since(due); late > time.Minute {
+		text += fmt.Sprintf("\n(原定 %s)", formatScheduleTime(due))
+	}
+	if err := b.sendMessage(entry.ChatID, text); err != nil {
+		if b.logger != nil {
+			b.logger.Errorf("telegram reminder failed: %s chat_id=%d err=%v", trace, entry.ChatID, err)
+		}
+		return false
+	}
+	if b.logger != nil {
+		b.logger.Infof("telegram reminder sent: %s chat_id=%d", trace, entry.ChatID)
+	}
+	return true
+}
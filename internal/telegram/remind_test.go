@@ -0,0 +1,49 @@
+package telegram
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"enoch/internal/scheduler"
+)
+
+func TestRemindDeleteOnlyRemovesReminders(t *testing.T) {
+	bot, sent := newRecordingBot("ask")
+	sched, err := scheduler.New(filepath.Join(t.TempDir(), "schedules.json"), nil)
+	if err != nil {
+		t.Fatalf("scheduler: %v", err)
+	}
+	bot.scheduler = sched
+	prompt, err := sched.Add(1, "0 9 * * *", "summarize")
+	if err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	reminder, err := sched.AddReminder(1, time.Now().Add(time.Hour), "call the bank")
+	if err != nil {
+		t.Fatalf("add reminder: %v", err)
+	}
+
+	bot.handleRemindDelete(1, []string{"#1"}, "update_id=1")
+	if len(sched.List(1, "")) != 2 || !strings.Contains((*sent)[0].payload["text"].(string), "未找到") {
+		t.Fatalf("expected scheduled prompt #%d to be kept, got %+v", prompt.ID, *sent)
+	}
+	bot.handleRemindDelete(1, []string{"2"}, "update_id=2")
+	if got := sched.List(1, ""); len(got) != 1 || got[0].ID != prompt.ID {
+		t.Fatalf("expected reminder #%d to be removed, got %+v", reminder.ID, got)
+	}
+}
+
+func TestDeliverReminderReportsFailure(t *testing.T) {
+	bot, _ := newRecordingBot("ask")
+	bot.client = &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("network down")
+	})}
+	entry := scheduler.Entry{ID: 3, ChatID: 1, Kind: scheduler.KindReminder, Spec: "2026-02-04 09:00", Prompt: "call the bank", Once: true}
+	if bot.fireSchedule(entry) {
+		t.Fatalf("expected an undelivered reminder to be reported")
+	}
+}
@@ -0,0 +1,72 @@
+package telegram
+
+import (
+	"strconv"
+	"strings"
+)
+
+// maxRememberedAnswers bounds how many sent answers are kept so that a reply
+// to a long (chunked or file) answer can still quote its full text.
+const maxRememberedAnswers = 128
+
+// maxQuotedRunes caps how much of a quoted answer is fed back to the agent.
+const maxQuotedRunes = 8000
+
+// rememberAnswer links the message ids an answer was delivered in to its text.
+func (b *Bot) rememberAnswer(chatID int64, messageIDs []int, text string) {
+	if len(messageIDs) == 0 {
+		return
+	}
+	b.contextMu.Lock()
+	defer b.contextMu.Unlock()
+	for _, id := range messageIDs {
+		if id == 0 {
+			continue
+		}
+		key := messageKey{chatID: chatID, messageID: id}
+		b.answers[key] = text
+		b.answerOrder = append(b.answerOrder, key)
+	}
+	for len(b.answerOrder) > maxRememberedAnswers {
+		delete(b.answers, b.answerOrder[0])
+		b.answerOrder = b.answerOrder[1:]
+	}
+}
+
+// quotedAnswer returns the answer msg replies to, or "" when it is not a
+// reply to one of this bot's recorded answers. Other bots' messages and this
+// bot's acknowledgments and status messages are not quoted. The recorded
+// text is used because long answers are split across messages or sent as
+// files.
+func (b *Bot) quotedAnswer(msg *Message) string {
+	parent := msg.ReplyToMessage
+	if parent == nil || parent.From == nil || parent.From.ID != b.botID() {
+		return ""
+	}
+	b.contextMu.Lock()
+	text, ok := b.answers[messageKey{chatID: msg.Chat.ID, messageID: parent.MessageID}]
+	b.contextMu.Unlock()
+	if !ok {
+		return ""
+	}
+	runes := []rune(text)
+	if len(runes) > maxQuotedRunes {
+		text = string(runes[:maxQuotedRunes]) + "..."
+	}
+	return text
+}
+
+// botID returns this bot's user id, which is the part of the token before
+// the colon (the id getMe reports).
+func (b *Bot) botID() int64 {
+	token := b.cfg().TelegramBotToken
+	idx := strings.Index(token, ":")
+	if idx <= 0 {
+		return 0
+	}
+	id, err := strconv.ParseInt(token[:idx], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
@@ -0,0 +1,57 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+
+	"enoch/internal/config"
+)
+
+func TestQuotedAnswerOnlyQuotesOwnRecordedAnswers(t *testing.T) {
+	bot := &Bot{config: config.Config{TelegramBotToken: "42:secret"}, answers: map[messageKey]string{}}
+	bot.rememberAnswer(1, []int{10, 11}, "full answer")
+
+	reply := &Message{
+		Chat:           Chat{ID: 1},
+		ReplyToMessage: &Message{MessageID: 11, Text: "chunk", From: &User{ID: 42, IsBot: true}},
+	}
+	if got := bot.quotedAnswer(reply); got != "full answer" {
+		t.Fatalf("unexpected quote: %q", got)
+	}
+
+	reply.ReplyToMessage.MessageID = 12
+	if got := bot.quotedAnswer(reply); got != "" {
+		t.Fatalf("acknowledgments and status messages should not quote, got %q", got)
+	}
+
+	reply.ReplyToMessage.MessageID = 11
+	reply.ReplyToMessage.From = &User{ID: 7, IsBot: true}
+	if got := bot.quotedAnswer(reply); got != "" {
+		t.Fatalf("replies to other bots should not quote, got %q", got)
+	}
+}
+
+func TestBuildPromptIncludesQuote(t *testing.T) {
+	bot := &Bot{config: config.Config{}, context: map[int64][]contextEntry{}}
+	if got := bot.buildPrompt(1, "hi", ""); got != "hi" {
+		t.Fatalf("unexpected prompt: %q", got)
+	}
+	got := bot.buildPrompt(1, "why?", "because")
+	if !strings.Contains(got, "replying to this earlier answer:\nbecause") || !strings.HasSuffix(got, "User: why?") {
+		t.Fatalf("unexpected prompt: %q", got)
+	}
+}
+
+func TestSendReplyThreadsToSource(t *testing.T) {
+	bot, sent := newRecordingBot("ask")
+	ids, err := bot.sendReply(1, 42, "answer")
+	if err != nil {
+		t.Fatalf("sendReply: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != 99 {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+	if got := (*sent)[0].payload["reply_to_message_id"]; got != float64(42) {
+		t.Fatalf("expected reply_to_message_id 42, got %v", got)
+	}
+}
@@ -0,0 +1,166 @@
+package telegram
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"enoch/internal/scheduler"
+)
+
+const scheduleUsage = "用法:\n/schedule \"0 9 * * 1-5\" 提示内容\n/schedule 2026-02-04 09:00 提示内容\n/schedule list | pause <id> | resume <id> | delete <id>"
+
+func isScheduleSubcommand(name string) bool {
+	switch strings.ToLower(name) {
+	case "list", "pause", "resume", "delete":
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *Bot) startScheduler() {
+	if b.scheduler == nil {
+		return
+	}
+	b.schedOnce.Do(func() {
+		go b.scheduler.Run(b.fireSchedule)
+	})
+}
+
+// fireSchedule pushes a due entry through the normal job queue and reports
+// whether it was queued. Traces use a schedule_id= prefix so scheduled runs
+// are distinguishable from updates. Reminders bypass the queue and are sent
+// straight to the chat.
+func (b *Bot) fireSchedule(entry scheduler.Entry) bool {
+	trace := fmt.Sprintf("schedule_id=%d", entry.ID)
+	if entry.EntryKind() == scheduler.KindReminder {
+		return b.deliverReminder(entry, trace)
+	}
+	queued := b.enqueueJob(&job{
+		chatID:   entry.ChatID,
+		text:     entry.Prompt,
+		trace:    trace,
+		header:   fmt.Sprintf("[定时任务 #%d]", entry.ID),
+		priority: priorityLow,
+	})
+	if !queued && b.logger != nil {
+		reason := "queue_full"
+		if b.isStopping() {
+			reason = "shutdown"
+		}
+		b.logger.Warnf("schedule skipped: %s chat_id=%d reason=%s", trace, entry.ChatID, reason)
+	}
+	return queued
+}
+
+func (b *Bot) handleScheduleAdd(chatID int64, args, trace string) {
+	if b.scheduler == nil {
+		b.reply(chatID, "定时任务未启用。", trace)
+		return
+	}
+	if args == "" {
+		b.reply(chatID, scheduleUsage, trace)
+		return
+	}
+	spec, prompt, err := scheduler.SplitSpec(args, time.Now())
+	if err != nil || prompt == "" {
+		b.reply(chatID, scheduleUsage, trace)
+		return
+	}
+	entry, err := b.scheduler.Add(chatID, spec, prompt)
+	if err != nil {
+		if b.logger != nil {
+			b.logger.Warnf("schedule add failed: %s spec=%q err=%v", trace, spec, err)
+		}
+		b.reply(chatID, fmt.Sprintf("无法创建定时任务：%v", err), trace)
+		return
+	}
+	if b.logger != nil {
+		b.logger.Infof("schedule added: %s schedule_id=%d chat_id=%d spec=%q", trace, entry.ID, chatID, entry.Spec)
+	}
+	b.reply(chatID, fmt.Sprintf("已创建定时任务 #%d，下次执行：%s", entry.ID, formatScheduleTime(entry.NextRun)), trace)
+}
+
+func (b *Bot) handleScheduleList(chatID int64, trace string) {
+	if b.scheduler == nil {
+		b.reply(chatID, "定时任务未启用。", trace)
+		return
+	}
+	entries := b.scheduler.List(chatID, scheduler.KindPrompt)
+	if len(entries) == 0 {
+		b.reply(chatID, "暂无定时任务。", trace)
+		return
+	}
+	if err := b.sendTextOrDocument(chatID, "schedules.txt", formatSchedules(entries)); err != nil && b.logger != nil {
+		b.logger.Errorf("telegram sendMessage failed: %s err=%v", trace, err)
+	}
+}
+
+func (b *Bot) handleScheduleUpdate(chatID int64, cmd string, args []string, trace string) {
+	if b.scheduler == nil {
+		b.reply(chatID, "定时任务未启用。", trace)
+		return
+	}
+	if len(args) != 1 {
+		b.reply(chatID, scheduleUsage, trace)
+		return
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+	if err != nil {
+		b.reply(chatID, scheduleUsage, trace)
+		return
+	}
+
+	var ack string
+	switch cmd {
+	case "/schedule_pause":
+		_, err = b.scheduler.SetPaused(chatID, id, true)
+		ack = fmt.Sprintf("已暂停定时任务 #%d。", id)
+	case "/schedule_resume":
+		var entry scheduler.Entry
+		entry, err = b.scheduler.SetPaused(chatID, id, false)
+		ack = fmt.Sprintf("已恢复定时任务 #%d，下次执行：%s", id, formatScheduleTime(entry.NextRun))
+	default:
+		err = b.scheduler.Remove(chatID, id, "")
+		ack = fmt.Sprintf("已删除 #%d。", id)
+	}
+	if err != nil {
+		if b.logger != nil {
+			b.logger.Warnf("schedule update failed: %s cmd=%s schedule_id=%d err=%v", trace, cmd, id, err)
+		}
+		b.reply(chatID, fmt.Sprintf("未找到 #%d。", id), trace)
+		return
+	}
+	b.reply(chatID, ack, trace)
+}
+
+// reply sends a short text and logs delivery failures against the trace.
+func (b *Bot) reply(chatID int64, text, trace string) {
+	if err := b.sendMessage(chatID, text); err != nil && b.logger != nil {
+		b.logger.Errorf("telegram sendMessage failed: %s err=%v", trace, err)
+	}
+}
+
+func formatSchedules(entries []scheduler.Entry) string {
+	var sb strings.Builder
+	sb.WriteString("定时任务:\n")
+	for _, entry := range entries {
+		state := "下次 " + formatScheduleTime(entry.NextRun)
+		if entry.Paused {
+			state = "已暂停"
+		}
+		line := fmt.Sprintf("#%d [%s] %s | %s", entry.ID, entry.Spec, state, truncateText(entry.Prompt, 80))
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func formatScheduleTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02 15:04")
+}
@@ -0,0 +1,21 @@
+package telegram
+
+import (
+	"net/http"
+	"time"
+
+	"enoch/internal/config"
+)
+
+// Send delivers text to a chat without starting the bot, for `enoch send`.
+// Long text is split or attached as a file like any reply.
+func Send(cfg config.Config, chatID int64, text string) error {
+	b := &Bot{
+		config:   cfg,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		baseURL:  apiBase + cfg.TelegramBotToken,
+		redactor: cfg.Redactor(),
+	}
+	_, err := b.sendReply(chatID, 0, text)
+	return err
+}
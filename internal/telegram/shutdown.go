@@ -0,0 +1,210 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrJobCanceled is returned by Shutdown when the running job did not finish
+// in time and was canceled.
+var ErrJobCanceled = errors.New("running job canceled at shutdown")
+
+// workerStopTimeout bounds the wait for a canceled job to wind down.
+const workerStopTimeout = 30 * time.Second
+
+// savedJob is a queued job persisted across restarts.
+type savedJob struct {
+	ChatID    int64  `json:"chat_id"`
+	UserID    int64  `json:"user_id,omitempty"`
+	MessageID int    `json:"message_id,omitempty"`
+	Text      string `json:"text"`
+	Trace     string `json:"trace"`
+	Header    string `json:"header,omitempty"`
+	Priority  int    `json:"priority,omitempty"`
+	Quoted    string `json:"quoted,omitempty"`
+	StatusID  int    `json:"status_id,omitempty"`
+}
+
+// Shutdown stops the worker. The running job may finish until ctx ends,
+// after which it is canceled. Jobs still queued are saved to the data
+// directory and resumed by the next Run; their chats are told so. Call it
+// after Run has returned.
+func (b *Bot) Shutdown(ctx context.Context) error {
+	b.stateMu.Lock()
+	b.stopping = true
+	done := b.workerDone
+	b.stateMu.Unlock()
+	b.queue.close()
+
+	var result error
+	if done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			if b.cancelCurrent() {
+				result = ErrJobCanceled
+			}
+			select {
+			case <-done:
+			case <-time.After(workerStopTimeout):
+				if b.logger != nil {
+					b.logger.Warnf("shutdown worker still running after cancel: timeout=%s", workerStopTimeout)
+				}
+			}
+		}
+	}
+
+	if err := b.saveQueue(b.queue.drain()); err != nil {
+		return err
+	}
+	return result
+}
+
+func (b *Bot) isStopping() bool {
+	b.stateMu.Lock()
+	defer b.stateMu.Unlock()
+	return b.stopping
+}
+
+// cancelCurrent cancels the running job, if any.
+func (b *Bot) cancelCurrent() bool {
+	b.stateMu.Lock()
+	current := b.current
+	var cancel context.CancelFunc
+	if current != nil {
+		cancel = current.cancel
+	}
+	b.stateMu.Unlock()
+	if cancel == nil {
+		return false
+	}
+	if b.logger != nil {
+		b.logger.Warnf("shutdown canceling job: %s", current.trace)
+	}
+	cancel()
+	return true
+}
+
+// notifyStatus shows text in the job's status message, or replies to the
+// prompt when there is none.
+func (b *Bot) notifyStatus(j *job, text string) {
+	if b.updateStatus(j, text) || j.messageID == 0 {
+		return
+	}
+	if _, err := b.sendMessageWithOptions(j.chatID, text, messageOptions{replyTo: j.messageID}); err != nil && b.logger != nil {
+		b.logger.Errorf("telegram sendMessage failed: %s err=%v", j.trace, err)
+	}
+}
+
+func (b *Bot) queuePath() string {
+	return filepath.Join(b.cfg().DataDir, "queue.json")
+}
+
+// saveQueue persists the queued jobs and tells their chats. An empty queue
+// removes the file.
+func (b *Bot) saveQueue(jobs []*job) error {
+	path := b.queuePath()
+	if len(jobs) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	saved := make([]savedJob, len(jobs))
+	b.stateMu.Lock()
+	for i, j := range jobs {
+		saved[i] = savedJob{
+			ChatID:    j.chatID,
+			UserID:    j.userID,
+			MessageID: j.messageID,
+			Text:      j.text,
+			Trace:     j.trace,
+			Header:    j.header,
+			Priority:  int(j.priority),
+			Quoted:    j.quoted,
+			StatusID:  j.statusID,
+		}
+	}
+	b.stateMu.Unlock()
+
+	err := writeJSON(path, saved)
+	if b.logger != nil {
+		if err != nil {
+			b.logger.Errorf("shutdown queue save failed: count=%d err=%v", len(jobs), err)
+		} else {
+			b.logger.Infof("shutdown queue saved: count=%d path=%s", len(jobs), path)
+		}
+	}
+	text := "机器人正在重启，任务已保存，重启后继续排队。"
+	if err != nil {
+		text = "机器人正在关闭，排队中的任务未能保存，请稍后重新发送。"
+	}
+	for _, j := range jobs {
+		b.notifyStatus(j, text)
+	}
+	return err
+}
+
+// restoreQueue re-enqueues the jobs saved by the last Shutdown.
+func (b *Bot) restoreQueue() {
+	path := b.queuePath()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) && b.logger != nil {
+			b.logger.Errorf("queue restore failed: err=%v", err)
+		}
+		return
+	}
+	var saved []savedJob
+	if err := json.Unmarshal(data, &saved); err != nil {
+		if b.logger != nil {
+			b.logger.Errorf("queue restore failed: path=%s err=%v", path, err)
+		}
+		return
+	}
+	restored := 0
+	for _, s := range saved {
+		j := &job{
+			chatID:    s.ChatID,
+			userID:    s.UserID,
+			messageID: s.MessageID,
+			text:      s.Text,
+			trace:     s.Trace,
+			header:    s.Header,
+			priority:  priority(s.Priority),
+			quoted:    s.Quoted,
+			statusID:  s.StatusID,
+		}
+		if !b.enqueueJob(j) {
+			b.notifyStatus(j, "队列已满，重启前排队的任务未能恢复，请重新发送。")
+			continue
+		}
+		restored++
+		b.updateStatus(j, b.queuedStatusText(b.queue.position(j)))
+	}
+	if err := os.Remove(path); err != nil && b.logger != nil {
+		b.logger.Warnf("queue restore cleanup failed: err=%v", err)
+	}
+	if b.logger != nil {
+		b.logger.Infof("queue restored: count=%d dropped=%d", restored, len(saved)-restored)
+	}
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,132 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShutdownSavesAndRestoresQueue(t *testing.T) {
+	dir := t.TempDir()
+	bot, sent := newRecordingBot("ask")
+	bot.config.DataDir = dir
+	acked := &job{chatID: 1, messageID: 7, text: "first", trace: "update_id=1", statusID: 50}
+	scheduled := &job{chatID: 2, text: "report", trace: "schedule_id=3", header: "[定时任务 #3]", priority: priorityLow}
+	bot.enqueueJob(acked)
+	bot.enqueueJob(scheduled)
+
+	if err := bot.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "queue.json")); err != nil {
+		t.Fatalf("expected saved queue: %v", err)
+	}
+	if len(*sent) != 1 || (*sent)[0].method != "editMessageText" || !strings.Contains((*sent)[0].payload["text"].(string), "任务已保存") {
+		t.Fatalf("expected the acknowledged job to be told, got %+v", *sent)
+	}
+
+	restarted, _ := newRecordingBot("ask")
+	restarted.config.DataDir = dir
+	restarted.restoreQueue()
+	queued := restarted.queue.snapshot()
+	if len(queued) != 2 {
+		t.Fatalf("expected 2 restored jobs, got %d", len(queued))
+	}
+	if queued[0].text != "first" || queued[0].statusID != 50 || queued[1].header != "[定时任务 #3]" || queued[1].priority != priorityLow {
+		t.Fatalf("restored jobs mismatch: %+v %+v", queued[0], queued[1])
+	}
+	if _, err := os.Stat(filepath.Join(dir, "queue.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected saved queue to be removed, got %v", err)
+	}
+}
+
+func TestShutdownCancelsRunningJobAfterGrace(t *testing.T) {
+	bot, _ := newRecordingBot("ask")
+	bot.config.DataDir = t.TempDir()
+	bot.workerDone = make(chan struct{})
+	running := &job{chatID: 1, trace: "update_id=1"}
+	bot.startJob(running, func() { close(bot.workerDone) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := bot.Shutdown(ctx); !errors.Is(err, ErrJobCanceled) {
+		t.Fatalf("expected ErrJobCanceled, got %v", err)
+	}
+	if !bot.isStopping() {
+		t.Fatalf("expected the bot to be stopping")
+	}
+}
+
+func TestShutdownWaitsForRunningJob(t *testing.T) {
+	bot, _ := newRecordingBot("ask")
+	bot.config.DataDir = t.TempDir()
+	bot.workerDone = make(chan struct{})
+	bot.startJob(&job{chatID: 1, trace: "update_id=1"}, func() { t.Errorf("job must not be canceled") })
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(bot.workerDone)
+	}()
+	if err := bot.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+}
+
+func TestRunConfirmsOffsetWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var mu sync.Mutex
+	var polls []map[string]interface{}
+	bot, _ := newRecordingBot("ask")
+	bot.config.DataDir = t.TempDir()
+	bot.config.TelegramPollInterval = time.Millisecond
+	bot.client = &http.Client{
+		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			payload := map[string]interface{}{}
+			body, _ := io.ReadAll(r.Body)
+			_ = json.Unmarshal(body, &payload)
+			mu.Lock()
+			polls = append(polls, payload)
+			n := len(polls)
+			mu.Unlock()
+			result := `[]`
+			switch n {
+			case 1:
+				result = `[{"update_id":41}]`
+			case 2:
+				// Stop while the second long poll is pending.
+				cancel()
+				<-r.Context().Done()
+				return nil, r.Context().Err()
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"ok":true,"result":` + result + `}`)),
+				Header:     make(http.Header),
+			}, nil
+		}),
+	}
+
+	if err := bot.Run(ctx); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if err := bot.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(polls) != 3 {
+		t.Fatalf("expected a confirming getUpdates call, got %+v", polls)
+	}
+	if last := polls[2]; last["offset"] != float64(42) || last["timeout"] != float64(0) {
+		t.Fatalf("expected offset 42 confirmed without waiting, got %+v", last)
+	}
+}
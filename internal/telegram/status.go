@@ -0,0 +1,151 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// minStatusInterval keeps live status edits well below Telegram rate limits.
+const minStatusInterval = 5 * time.Second
+
+// sendQueuedStatus acknowledges a queued job as a reply to its source message
+// and remembers the acknowledgment so later states can be edited into it.
+func (b *Bot) sendQueuedStatus(j *job) {
+	text := b.queuedStatusText(b.queue.position(j))
+	id, err := b.sendMessageWithOptions(j.chatID, text, messageOptions{replyTo: j.messageID})
+	if err != nil {
+		if b.logger != nil {
+			b.logger.Errorf("telegram sendMessage failed: %s err=%v", j.trace, err)
+		}
+		return
+	}
+
+	b.stateMu.Lock()
+	j.statusID = id
+	started := j.status != jobQueued
+	b.stateMu.Unlock()
+	if started {
+		// The worker picked the job up before the acknowledgment existed.
+		b.updateStatus(j, "处理中…")
+	}
+}
+
+func (b *Bot) queuedStatusText(position int) string {
+	text := "已加入队列，请稍候。"
+	if position > 1 {
+		text = fmt.Sprintf("已加入队列，前面还有 %d 个任务。", position-1)
+	}
+	if b.isPaused() {
+		text = "已暂停处理，任务已排队。"
+		if position > 0 {
+			text = fmt.Sprintf("已暂停处理，任务已排队（第 %d 位）。", position)
+		}
+	}
+	return text
+}
+
+// refreshQueuedStatus re-renders the queue position of every waiting job.
+func (b *Bot) refreshQueuedStatus() {
+	for i, j := range b.queue.snapshot() {
+		b.updateStatus(j, b.queuedStatusText(i+1))
+	}
+}
+
+// updateStatus edits the job's status message, reporting whether one exists.
+func (b *Bot) updateStatus(j *job, text string) bool {
+	b.stateMu.Lock()
+	id := j.statusID
+	b.stateMu.Unlock()
+	if id == 0 {
+		return false
+	}
+	if err := b.editMessageText(j.chatID, id, text); err != nil {
+		// Telegram rejects edits that do not change the text; that is harmless.
+		if strings.Contains(err.Error(), "message is not modified") {
+			return true
+		}
+		if b.logger != nil {
+			b.logger.Warnf("telegram status update failed: %s err=%v", j.trace, err)
+		}
+		return true
+	}
+	if b.logger != nil {
+		b.logger.Debugf("telegram status updated: %s text=%q", j.trace, text)
+	}
+	return true
+}
+
+// startStatusLoop shows the running state with elapsed time until stopped.
+// CODEX_PROGRESS_INTERVAL controls the refresh rate (0 disables refreshes).
+func (b *Bot) startStatusLoop(j *job, start time.Time) func() {
+	b.updateStatus(j, "处理中…")
+
+	interval := b.cfg().CodexProgressInterval
+	if interval <= 0 {
+		return func() {}
+	}
+	if interval < minStatusInterval {
+		interval = minStatusInterval
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				b.updateStatus(j, b.runningStatusText(j, time.Since(start)))
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+func (b *Bot) runningStatusText(j *job, elapsed time.Duration) string {
+	b.stateMu.Lock()
+	last := j.lastOutput
+	attempt, maxAttempts := j.attempt, j.maxAttempts
+	b.stateMu.Unlock()
+	text := fmt.Sprintf("处理中 · 已用时 %s", formatElapsed(elapsed))
+	if attempt > 1 {
+		text = fmt.Sprintf("处理中（第 %d/%d 次尝试） · 已用时 %s", attempt, maxAttempts, formatElapsed(elapsed))
+	}
+	if last != "" {
+		text += "\n> " + truncateText(last, 200)
+	}
+	return text
+}
+
+// completeStatus settles the status message once the answer is delivered.
+// Successful jobs are deleted or collapsed per TELEGRAM_STATUS_CLEANUP;
+// failures stay visible.
+func (b *Bot) completeStatus(j *job, ok bool, duration time.Duration) {
+	if !ok {
+		b.updateStatus(j, fmt.Sprintf("❌ 处理失败 · 用时 %s", formatElapsed(duration)))
+		return
+	}
+	if b.cfg().TelegramStatusCleanup == "collapse" {
+		b.updateStatus(j, fmt.Sprintf("✅ 已完成 · 用时 %s", formatElapsed(duration)))
+		return
+	}
+
+	b.stateMu.Lock()
+	id := j.statusID
+	j.statusID = 0
+	b.stateMu.Unlock()
+	if id == 0 {
+		return
+	}
+	if err := b.deleteMessage(j.chatID, id); err != nil && b.logger != nil {
+		b.logger.Warnf("telegram deleteMessage failed: %s err=%v", j.trace, err)
+	}
+}
+
+func formatElapsed(d time.Duration) string {
+	return d.Truncate(time.Second).String()
+}
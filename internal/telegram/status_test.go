@@ -0,0 +1,43 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatusMessageLifecycle(t *testing.T) {
+	bot, sent := newRecordingBot("ask")
+	first := &job{chatID: 1, messageID: 5, trace: "update_id=1"}
+	second := &job{chatID: 1, messageID: 6, trace: "update_id=2"}
+	bot.enqueueJob(first)
+	bot.enqueueJob(second)
+	bot.sendQueuedStatus(first)
+	bot.sendQueuedStatus(second)
+
+	if got := (*sent)[1].payload["text"]; got != "已加入队列，前面还有 1 个任务。" {
+		t.Fatalf("unexpected queued text: %v", got)
+	}
+	if first.statusID != 99 {
+		t.Fatalf("expected status id to be stored, got %d", first.statusID)
+	}
+
+	bot.queue.pop()
+	bot.refreshQueuedStatus()
+	last := (*sent)[len(*sent)-1]
+	if last.method != "editMessageText" || last.payload["text"] != "已加入队列，请稍候。" {
+		t.Fatalf("expected position refresh, got %+v", last)
+	}
+
+	bot.completeStatus(first, true, 3*time.Second)
+	last = (*sent)[len(*sent)-1]
+	if last.method != "deleteMessage" || first.statusID != 0 {
+		t.Fatalf("expected status deletion, got %+v", last)
+	}
+
+	bot.config.TelegramStatusCleanup = "collapse"
+	bot.completeStatus(second, true, 3*time.Second)
+	last = (*sent)[len(*sent)-1]
+	if last.method != "editMessageText" || last.payload["text"] != "✅ 已完成 · 用时 3s" {
+		t.Fatalf("expected collapsed status, got %+v", last)
+	}
+}
@@ -0,0 +1,129 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+
+	"enoch/internal/agent"
+	"enoch/internal/usage"
+)
+
+// budgetExceeded reports which daily budget the job's chat or user has used
+// up, or "" when none is.
+func (b *Bot) budgetExceeded(j *job) string {
+	if b.usage == nil {
+		return ""
+	}
+	today := usage.StartOfDay(b.usage.Now())
+	if limit := b.cfg().UsageChatBudget; limit > 0 {
+		if used := b.usage.Sum(usage.Filter{ChatID: j.chatID, Since: today}).Total; used >= int64(limit) {
+			return fmt.Sprintf("本 chat 今日已用 %s tokens，超过预算 %s", formatTokens(used), formatTokens(int64(limit)))
+		}
+	}
+	if limit := b.cfg().UsageUserBudget; limit > 0 && j.userID != 0 {
+		if used := b.usage.Sum(usage.Filter{UserID: j.userID, Since: today}).Total; used >= int64(limit) {
+			return fmt.Sprintf("你今日已用 %s tokens，超过预算 %s", formatTokens(used), formatTokens(int64(limit)))
+		}
+	}
+	return ""
+}
+
+// checkBudget refuses the job when a budget is exceeded in block mode.
+func (b *Bot) checkBudget(j *job) bool {
+	if b.cfg().UsageBudgetMode != "block" {
+		return true
+	}
+	reason := b.budgetExceeded(j)
+	if reason == "" {
+		return true
+	}
+	if b.logger != nil {
+		b.logger.Warnf("usage budget blocked: %s chat_id=%d user_id=%d", j.trace, j.chatID, j.userID)
+	}
+	text := "❌ " + reason + "，任务未执行。"
+	if !b.updateStatus(j, text) {
+		if _, err := b.sendMessageWithOptions(j.chatID, text, messageOptions{replyTo: j.messageID}); err != nil && b.logger != nil {
+			b.logger.Errorf("telegram sendMessage failed: %s err=%v", j.trace, err)
+		}
+	}
+	return false
+}
+
+// recordUsage stores the job's token usage and, in warn mode, tells the
+// chat once a budget is exceeded.
+func (b *Bot) recordUsage(j *job, backend string, u agent.Usage) {
+	if b.usage == nil || u.Empty() {
+		return
+	}
+	err := b.usage.Add(usage.Record{
+		ChatID:  j.chatID,
+		UserID:  j.userID,
+		Trace:   j.trace,
+		Backend: backend,
+		Input:   u.Input,
+		Output:  u.Output,
+		Total:   u.Total,
+	})
+	if err != nil {
+		if b.logger != nil {
+			b.logger.Errorf("usage record failed: %s err=%v", j.trace, err)
+		}
+		return
+	}
+	if b.logger != nil {
+		b.logger.Infof("usage recorded: %s input=%d output=%d total=%d", j.trace, u.Input, u.Output, u.Total)
+	}
+	if b.cfg().UsageBudgetMode == "warn" {
+		if reason := b.budgetExceeded(j); reason != "" {
+			b.reply(j.chatID, "⚠️ "+reason+"。", j.trace)
+		}
+	}
+}
+
+func (b *Bot) handleUsage(chatID, userID int64, trace string) {
+	if b.usage == nil {
+		b.reply(chatID, "未启用用量统计。", trace)
+		return
+	}
+	now := b.usage.Now()
+	today, week := usage.StartOfDay(now), usage.StartOfWeek(now)
+
+	var sb strings.Builder
+	sb.WriteString("用量统计（tokens）:\n")
+	sb.WriteString("本 chat\n")
+	sb.WriteString(b.usageLine("今日", usage.Filter{ChatID: chatID, Since: today}, b.cfg().UsageChatBudget))
+	sb.WriteString(b.usageLine("本周", usage.Filter{ChatID: chatID, Since: week}, 0))
+	if userID != 0 {
+		sb.WriteString("你（所有 chat）\n")
+		sb.WriteString(b.usageLine("今日", usage.Filter{UserID: userID, Since: today}, b.cfg().UsageUserBudget))
+		sb.WriteString(b.usageLine("本周", usage.Filter{UserID: userID, Since: week}, 0))
+	}
+	b.reply(chatID, strings.TrimSpace(sb.String()), trace)
+}
+
+func (b *Bot) usageLine(label string, f usage.Filter, budget int) string {
+	t := b.usage.Sum(f)
+	line := fmt.Sprintf("- %s：%s（输入 %s / 输出 %s，%d 个任务）", label, formatTokens(t.Total), formatTokens(t.Input), formatTokens(t.Output), t.Jobs)
+	if cost := b.usageCost(t); cost > 0 {
+		line += fmt.Sprintf(" ≈ $%.4f", cost)
+	}
+	if budget > 0 {
+		line += fmt.Sprintf("，预算 %s", formatTokens(int64(budget)))
+	}
+	return line + "\n"
+}
+
+// usageCost applies USAGE_PRICE_INPUT/OUTPUT (USD per million tokens).
+func (b *Bot) usageCost(t usage.Totals) float64 {
+	return (float64(t.Input)*b.cfg().UsagePriceInput + float64(t.Output)*b.cfg().UsagePriceOutput) / 1e6
+}
+
+func formatTokens(n int64) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.2fM", float64(n)/1e6)
+	case n >= 10_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1e3)
+	}
+	return fmt.Sprintf("%d", n)
+}
@@ -0,0 +1,85 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"enoch/internal/workspace"
+)
+
+// acquireWorkspace prepares the job's working directory. On failure the
+// user is told why and ok is false.
+func (b *Bot) acquireWorkspace(j *job) (string, func(), bool) {
+	if b.workspaces == nil {
+		return "", func() {}, true
+	}
+	dir, release, err := b.workspaces.Acquire(j.chatID, j.trace)
+	if err == nil {
+		return dir, release, true
+	}
+
+	if b.logger != nil {
+		b.logger.Errorf("workspace acquire failed: %s err=%v", j.trace, err)
+	}
+	text := "❌ 准备工作区失败，请稍后重试。"
+	switch {
+	case errors.Is(err, workspace.ErrQuota):
+		text = fmt.Sprintf("❌ 工作区超出配额（%v），请使用 /workspace reset 清理后重试。", err)
+	case errors.Is(err, workspace.ErrRemoving):
+		text = "❌ 工作区正在重置，请稍后重试。"
+	}
+	if !b.updateStatus(j, text) {
+		if _, err := b.sendMessageWithOptions(j.chatID, text, messageOptions{replyTo: j.messageID}); err != nil && b.logger != nil {
+			b.logger.Errorf("telegram sendMessage failed: %s err=%v", j.trace, err)
+		}
+	}
+	return "", nil, false
+}
+
+func (b *Bot) handleWorkspace(chatID int64, args []string, trace string) {
+	if b.workspaces == nil || b.workspaces.Mode() == workspace.ModeShared {
+		b.reply(chatID, fmt.Sprintf("所有任务共用工作目录：%s", b.cfg().CodexWorkdir), trace)
+		return
+	}
+	if len(args) == 0 {
+		b.reply(chatID, formatWorkspace(b.workspaces.Inspect(chatID))+"\n用法: /workspace reset 清空本 chat 的工作区", trace)
+		return
+	}
+	if !strings.EqualFold(args[0], "reset") {
+		b.reply(chatID, "用法: /workspace | /workspace reset", trace)
+		return
+	}
+	if err := b.workspaces.Reset(chatID); err != nil {
+		if b.logger != nil {
+			b.logger.Warnf("workspace reset failed: %s chat_id=%d err=%v", trace, chatID, err)
+		}
+		b.reply(chatID, fmt.Sprintf("重置失败：%v", err), trace)
+		return
+	}
+	if b.logger != nil {
+		b.logger.Infof("workspace reset: %s chat_id=%d", trace, chatID)
+	}
+	b.reply(chatID, "工作区已重置。", trace)
+}
+
+func formatWorkspace(info workspace.Info) string {
+	var sb strings.Builder
+	mode := "每个 chat 独立"
+	if info.Mode == workspace.ModeJob {
+		mode = "每个任务独立"
+	}
+	sb.WriteString(fmt.Sprintf("工作区（%s）：%s\n", mode, info.Path))
+	if !info.Exists {
+		sb.WriteString("尚未创建（首个任务执行时创建）")
+		return sb.String()
+	}
+	sb.WriteString(fmt.Sprintf("大小：%s（%d 个文件）", workspace.FormatBytes(info.Bytes), info.Files))
+	if info.Mode == workspace.ModeJob {
+		sb.WriteString(fmt.Sprintf("\n保留的任务目录：%d", info.Jobs))
+	}
+	if info.Worktree {
+		sb.WriteString("\n类型：git worktree")
+	}
+	return sb.String()
+}
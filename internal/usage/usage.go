@@ -0,0 +1,223 @@
+package usage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// retention bounds how far back records are kept.
+const retention = 35 * 24 * time.Hour
+
+// pruneInterval is how often Add drops expired records.
+const pruneInterval = time.Hour
+
+// Record is the token usage of one finished job.
+type Record struct {
+	Time    time.Time `json:"time"`
+	ChatID  int64     `json:"chat_id"`
+	UserID  int64     `json:"user_id,omitempty"`
+	Trace   string    `json:"trace"`
+	Backend string    `json:"backend"`
+	Input   int64     `json:"input_tokens"`
+	Output  int64     `json:"output_tokens"`
+	Total   int64     `json:"total_tokens"`
+}
+
+// Filter selects records. Zero ids match any chat or user.
+type Filter struct {
+	ChatID int64
+	UserID int64
+	Since  time.Time
+}
+
+// Totals sums the matching records.
+type Totals struct {
+	Jobs   int
+	Input  int64
+	Output int64
+	Total  int64
+}
+
+// Store keeps usage records in an append-only JSON lines file.
+type Store struct {
+	Now func() time.Time
+
+	path    string
+	mu      sync.Mutex
+	records []Record
+	// pruned is when expired records were last dropped; stale counts the
+	// dropped records still in the file.
+	pruned time.Time
+	stale  int
+}
+
+// Open loads the records at path (a missing file is not an error). Expired
+// records are dropped from the file.
+func Open(path string) (*Store, error) {
+	s := &Store{Now: time.Now, path: path}
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	cutoff := time.Now().Add(-retention)
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("parse %s:%d: %w", path, line, err)
+		}
+		if record.Time.After(cutoff) {
+			s.records = append(s.records, record)
+		} else {
+			s.stale++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	file.Close()
+	s.pruned = time.Now()
+	if s.stale > 0 {
+		if err := s.compactLocked(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Add appends a record, filling in the time when unset.
+func (s *Store) Add(record Record) error {
+	if record.Time.IsZero() {
+		record.Time = s.Now()
+	}
+	if record.Total == 0 {
+		record.Total = record.Input + record.Output
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	s.records = append(s.records, record)
+	s.pruneLocked()
+	return nil
+}
+
+// pruneLocked drops expired records from memory at most once per
+// pruneInterval, and rewrites the file once it holds as many dropped records
+// as live ones.
+func (s *Store) pruneLocked() {
+	now := s.Now()
+	if now.Sub(s.pruned) < pruneInterval {
+		return
+	}
+	s.pruned = now
+	cutoff := now.Add(-retention)
+	kept := s.records[:0]
+	for _, r := range s.records {
+		if r.Time.After(cutoff) {
+			kept = append(kept, r)
+		} else {
+			s.stale++
+		}
+	}
+	for i := len(kept); i < len(s.records); i++ {
+		s.records[i] = Record{}
+	}
+	s.records = kept
+	if s.stale > 0 && s.stale >= len(s.records) {
+		// A failed rewrite only leaves the file larger; the next prune
+		// tries again.
+		_ = s.compactLocked()
+	}
+}
+
+// compactLocked rewrites the file with the live records only.
+func (s *Store) compactLocked() error {
+	var buf bytes.Buffer
+	for _, r := range s.records {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	s.stale = 0
+	return nil
+}
+
+// Sum totals the records matching f.
+func (s *Store) Sum(f Filter) Totals {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var t Totals
+	for _, r := range s.records {
+		if f.ChatID != 0 && r.ChatID != f.ChatID {
+			continue
+		}
+		if f.UserID != 0 && r.UserID != f.UserID {
+			continue
+		}
+		if r.Time.Before(f.Since) {
+			continue
+		}
+		t.Jobs++
+		t.Input += r.Input
+		t.Output += r.Output
+		t.Total += r.Total
+	}
+	return t
+}
+
+// StartOfDay returns local midnight of t.
+func StartOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// StartOfWeek returns local midnight of the Monday of t's week.
+func StartOfWeek(t time.Time) time.Time {
+	day := StartOfDay(t)
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
@@ -0,0 +1,94 @@
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStoreSumsAndPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	now := time.Now()
+	store.Now = func() time.Time { return now }
+
+	records := []Record{
+		{ChatID: 1, UserID: 10, Input: 100, Output: 50},
+		{ChatID: 1, UserID: 11, Total: 30},
+		{ChatID: 2, UserID: 10, Input: 5, Output: 5, Time: now.Add(-48 * time.Hour)},
+	}
+	for _, record := range records {
+		if err := store.Add(record); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+	}
+
+	if got := store.Sum(Filter{ChatID: 1}); got.Jobs != 2 || got.Total != 180 || got.Input != 100 {
+		t.Fatalf("unexpected chat totals: %+v", got)
+	}
+	if got := store.Sum(Filter{UserID: 10, Since: StartOfDay(now)}); got.Jobs != 1 || got.Total != 150 {
+		t.Fatalf("unexpected user totals today: %+v", got)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if got := reopened.Sum(Filter{UserID: 10}); got.Jobs != 2 || got.Total != 160 {
+		t.Fatalf("unexpected totals after reopen: %+v", got)
+	}
+}
+
+func TestStoreDropsExpiredRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	now := time.Now()
+	old := `{"time":"` + now.Add(-40*24*time.Hour).Format(time.RFC3339) + `","chat_id":1,"total_tokens":7}`
+	fresh := `{"time":"` + now.Add(-time.Hour).Format(time.RFC3339) + `","chat_id":1,"total_tokens":5}`
+	if err := os.WriteFile(path, []byte(old+"\n"+fresh+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lines := func() int {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Count(string(data), "\n")
+	}
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if got := lines(); got != 1 {
+		t.Fatalf("expected the expired record removed from the file, got %d lines", got)
+	}
+
+	// Weeks later every earlier record has expired.
+	later := now.Add(retention)
+	store.Now = func() time.Time { return later }
+	if err := store.Add(Record{ChatID: 1, Total: 3}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if got := store.Sum(Filter{ChatID: 1}); got.Jobs != 1 || got.Total != 3 {
+		t.Fatalf("expected only the new record, got %+v", got)
+	}
+	if got := lines(); got != 1 {
+		t.Fatalf("expected the file compacted, got %d lines", got)
+	}
+}
+
+func TestStartOfWeek(t *testing.T) {
+	sunday := time.Date(2024, 3, 10, 15, 4, 5, 0, time.UTC)
+	want := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	if got := StartOfWeek(sunday); !got.Equal(want) {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+	if got := StartOfWeek(want.Add(time.Hour)); !got.Equal(want) {
+		t.Fatalf("expected Monday to map to itself, got %s", got)
+	}
+}
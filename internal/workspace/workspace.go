@@ -0,0 +1,402 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"enoch/internal/logging"
+)
+
+const (
+	// ModeShared runs every job in the configured workdir.
+	ModeShared = "shared"
+	// ModeChat gives each chat its own persistent directory.
+	ModeChat = "chat"
+	// ModeJob gives each job a fresh directory under its chat.
+	ModeJob = "job"
+)
+
+var (
+	// ErrQuota is returned when a chat's workspace exceeds the size quota.
+	ErrQuota = errors.New("workspace quota exceeded")
+	// ErrRemoving is returned by Acquire while the workspace is being reset
+	// or pruned.
+	ErrRemoving = errors.New("workspace is being removed")
+)
+
+// Options configures the workspace manager.
+type Options struct {
+	Mode string
+	// Root holds the chat and job directories.
+	Root string
+	// Repo, when set, is a git repository; workspaces are created as
+	// detached worktrees of its HEAD.
+	Repo string
+	// SkillsDir is linked into each workspace as skills/ and .codex/skills.
+	SkillsDir string
+	// KeepJobs keeps job directories after the job finishes (job mode).
+	KeepJobs bool
+	// MaxAge removes workspaces unused for longer than this (0 disables).
+	MaxAge time.Duration
+	// QuotaBytes rejects new jobs once a chat's workspace is larger (0
+	// disables).
+	QuotaBytes int64
+}
+
+// Manager hands out working directories for jobs.
+type Manager struct {
+	opts   Options
+	logger *logging.Logger
+	Now    func() time.Time
+
+	mu    sync.Mutex
+	seq   int
+	inUse map[string]int
+	// removing holds the directories being deleted by Reset or prune.
+	removing map[string]bool
+	lastGC   time.Time
+	gcEvery  time.Duration
+}
+
+// Info describes a chat's workspace for /workspace.
+type Info struct {
+	Mode     string
+	Path     string
+	Exists   bool
+	Worktree bool
+	Bytes    int64
+	Files    int
+	Jobs     int
+}
+
+func New(opts Options, logger *logging.Logger) *Manager {
+	if opts.Mode == "" {
+		opts.Mode = ModeShared
+	}
+	return &Manager{
+		opts:     opts,
+		logger:   logger,
+		Now:      time.Now,
+		inUse:    map[string]int{},
+		removing: map[string]bool{},
+		gcEvery:  time.Minute,
+	}
+}
+
+// Mode returns the configured workspace mode.
+func (m *Manager) Mode() string {
+	return m.opts.Mode
+}
+
+// Acquire prepares the directory for a job in chatID. It returns "" in
+// shared mode (the backend's own workdir applies). release must be called
+// when the job ends.
+func (m *Manager) Acquire(chatID int64, trace string) (string, func(), error) {
+	if m.opts.Mode == ModeShared {
+		return "", func() {}, nil
+	}
+	m.prune()
+
+	chatDir := m.chatDir(chatID)
+	if m.opts.QuotaBytes > 0 {
+		if size, _, err := dirSize(chatDir); err == nil && size > m.opts.QuotaBytes {
+			return "", nil, fmt.Errorf("%w: %s used, limit %s", ErrQuota, FormatBytes(size), FormatBytes(m.opts.QuotaBytes))
+		}
+	}
+
+	// Register the directory before creating it, so Reset and prune leave
+	// it alone from here on.
+	m.mu.Lock()
+	if m.removing[chatDir] {
+		m.mu.Unlock()
+		return "", nil, ErrRemoving
+	}
+	dir := chatDir
+	if m.opts.Mode == ModeJob {
+		m.seq++
+		dir = filepath.Join(chatDir, fmt.Sprintf("job-%s-%d", m.Now().Format("20060102-150405"), m.seq))
+	}
+	m.inUse[dir]++
+	m.mu.Unlock()
+	unregister := func() {
+		m.mu.Lock()
+		m.inUse[dir]--
+		if m.inUse[dir] <= 0 {
+			delete(m.inUse, dir)
+		}
+		m.mu.Unlock()
+	}
+
+	if err := m.prepare(dir); err != nil {
+		unregister()
+		return "", nil, err
+	}
+	now := m.Now()
+	_ = os.Chtimes(dir, now, now)
+	if m.logger != nil {
+		m.logger.Debugf("workspace acquired: %s dir=%s", trace, dir)
+	}
+
+	release := func() {
+		unregister()
+		if m.opts.Mode == ModeJob && !m.opts.KeepJobs {
+			if err := m.remove(dir); err != nil && m.logger != nil {
+				m.logger.Warnf("workspace cleanup failed: %s dir=%s err=%v", trace, dir, err)
+			}
+		}
+	}
+	return dir, release, nil
+}
+
+// Inspect reports the chat's workspace.
+func (m *Manager) Inspect(chatID int64) Info {
+	info := Info{Mode: m.opts.Mode, Path: m.chatDir(chatID)}
+	if m.opts.Mode == ModeShared {
+		return info
+	}
+	if _, err := os.Stat(info.Path); err != nil {
+		return info
+	}
+	info.Exists = true
+	info.Bytes, info.Files, _ = dirSize(info.Path)
+	if m.opts.Mode == ModeJob {
+		entries, _ := os.ReadDir(info.Path)
+		for _, entry := range entries {
+			if entry.IsDir() && strings.HasPrefix(entry.Name(), "job-") {
+				info.Jobs++
+			}
+		}
+	} else {
+		_, err := os.Lstat(filepath.Join(info.Path, ".git"))
+		info.Worktree = err == nil && m.opts.Repo != ""
+	}
+	return info
+}
+
+// Reset removes the chat's workspace. It fails while a job is using it, and
+// jobs cannot acquire it until the removal is done.
+func (m *Manager) Reset(chatID int64) error {
+	if m.opts.Mode == ModeShared {
+		return fmt.Errorf("shared workspace cannot be reset")
+	}
+	chatDir := m.chatDir(chatID)
+	m.mu.Lock()
+	if m.removing[chatDir] {
+		m.mu.Unlock()
+		return ErrRemoving
+	}
+	for dir := range m.inUse {
+		if dir == chatDir || strings.HasPrefix(dir, chatDir+string(filepath.Separator)) {
+			m.mu.Unlock()
+			return fmt.Errorf("workspace is in use")
+		}
+	}
+	m.removing[chatDir] = true
+	m.mu.Unlock()
+	defer m.endRemoval(chatDir)
+
+	if m.opts.Mode == ModeJob {
+		entries, _ := os.ReadDir(chatDir)
+		for _, entry := range entries {
+			if err := m.remove(filepath.Join(chatDir, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return m.remove(chatDir)
+}
+
+func (m *Manager) endRemoval(dir string) {
+	m.mu.Lock()
+	delete(m.removing, dir)
+	m.mu.Unlock()
+}
+
+func (m *Manager) chatDir(chatID int64) string {
+	return filepath.Join(m.opts.Root, "chat-"+strconv.FormatInt(chatID, 10))
+}
+
+// prepare creates dir (as a git worktree when a repo is configured) and
+// links the project skills into it.
+func (m *Manager) prepare(dir string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+			return err
+		}
+		if m.opts.Repo != "" {
+			if err := git(m.opts.Repo, "worktree", "add", "--detach", absPath(dir)); err != nil {
+				return fmt.Errorf("create worktree: %w", err)
+			}
+		} else if err := os.Mkdir(dir, 0o755); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	return m.linkSkills(dir)
+}
+
+func (m *Manager) linkSkills(dir string) error {
+	if m.opts.SkillsDir == "" {
+		return nil
+	}
+	target := absPath(m.opts.SkillsDir)
+	if _, err := os.Stat(target); err != nil {
+		return nil
+	}
+	for _, link := range []string{"skills", filepath.Join(".codex", "skills")} {
+		path := filepath.Join(dir, link)
+		if _, err := os.Lstat(path); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.Symlink(target, path); err != nil {
+			return fmt.Errorf("link skills: %w", err)
+		}
+	}
+	return nil
+}
+
+// remove deletes a workspace directory, unregistering it from the repo
+// when it is a worktree.
+func (m *Manager) remove(dir string) error {
+	if m.opts.Repo != "" {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			if err := git(m.opts.Repo, "worktree", "remove", "--force", absPath(dir)); err == nil {
+				return nil
+			}
+		}
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if m.opts.Repo != "" {
+		_ = git(m.opts.Repo, "worktree", "prune")
+	}
+	return nil
+}
+
+// prune removes workspaces unused for longer than MaxAge. It runs at most
+// once per gcEvery.
+func (m *Manager) prune() {
+	if m.opts.MaxAge <= 0 {
+		return
+	}
+	now := m.Now()
+	m.mu.Lock()
+	if now.Sub(m.lastGC) < m.gcEvery {
+		m.mu.Unlock()
+		return
+	}
+	m.lastGC = now
+	m.mu.Unlock()
+
+	chats, err := os.ReadDir(m.opts.Root)
+	if err != nil {
+		return
+	}
+	for _, chat := range chats {
+		if !chat.IsDir() || !strings.HasPrefix(chat.Name(), "chat-") {
+			continue
+		}
+		chatDir := filepath.Join(m.opts.Root, chat.Name())
+		candidates := []string{chatDir}
+		if m.opts.Mode == ModeJob {
+			candidates = nil
+			jobs, _ := os.ReadDir(chatDir)
+			for _, job := range jobs {
+				if job.IsDir() && strings.HasPrefix(job.Name(), "job-") {
+					candidates = append(candidates, filepath.Join(chatDir, job.Name()))
+				}
+			}
+		}
+		for _, dir := range candidates {
+			m.pruneDir(dir, now)
+		}
+	}
+}
+
+func (m *Manager) pruneDir(dir string, now time.Time) {
+	info, err := os.Stat(dir)
+	if err != nil || now.Sub(info.ModTime()) < m.opts.MaxAge {
+		return
+	}
+	m.mu.Lock()
+	if m.inUse[dir] > 0 || m.removing[dir] {
+		m.mu.Unlock()
+		return
+	}
+	m.removing[dir] = true
+	m.mu.Unlock()
+	defer m.endRemoval(dir)
+	if err := m.remove(dir); err != nil {
+		if m.logger != nil {
+			m.logger.Warnf("workspace prune failed: dir=%s err=%v", dir, err)
+		}
+		return
+	}
+	if m.logger != nil {
+		m.logger.Infof("workspace pruned: dir=%s idle=%s", dir, now.Sub(info.ModTime()).Truncate(time.Second))
+	}
+}
+
+// dirSize sums regular file sizes under root without following symlinks.
+func dirSize(root string) (int64, int, error) {
+	var size int64
+	var files int
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+			files++
+		}
+		return nil
+	})
+	return size, files, err
+}
+
+func git(repo string, args ...string) error {
+	cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// FormatBytes renders a size for humans (e.g. 1.5 MB).
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,144 @@
+package workspace
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func newSkills(t *testing.T) string {
+	t.Helper()
+	skills := filepath.Join(t.TempDir(), "skills")
+	if err := os.MkdirAll(filepath.Join(skills, "system"), 0o755); err != nil {
+		t.Fatalf("mkdir skills: %v", err)
+	}
+	return skills
+}
+
+func TestChatModeReusesDirectoryWithSkills(t *testing.T) {
+	root := t.TempDir()
+	m := New(Options{Mode: ModeChat, Root: root, SkillsDir: newSkills(t)}, nil)
+
+	dir, release, err := m.Acquire(7, "update_id=1")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	release()
+	if dir != filepath.Join(root, "chat-7") {
+		t.Fatalf("unexpected dir: %s", dir)
+	}
+	for _, link := range []string{"skills/system", ".codex/skills/system"} {
+		if _, err := os.Stat(filepath.Join(dir, link)); err != nil {
+			t.Fatalf("expected %s in workspace: %v", link, err)
+		}
+	}
+
+	again, release, err := m.Acquire(7, "update_id=2")
+	if err != nil {
+		t.Fatalf("acquire again: %v", err)
+	}
+	release()
+	if again != dir {
+		t.Fatalf("expected the same chat workspace, got %s", again)
+	}
+}
+
+func TestJobModeCleansUpAfterRelease(t *testing.T) {
+	m := New(Options{Mode: ModeJob, Root: t.TempDir()}, nil)
+	dir, release, err := m.Acquire(7, "update_id=1")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("expected job dir to exist: %v", err)
+	}
+	release()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("expected job dir removed, stat err=%v", err)
+	}
+}
+
+func TestQuotaAndReset(t *testing.T) {
+	m := New(Options{Mode: ModeChat, Root: t.TempDir(), QuotaBytes: 10}, nil)
+	dir, release, err := m.Acquire(7, "update_id=1")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if err := m.Reset(7); err == nil {
+		t.Fatalf("expected reset to fail while in use")
+	}
+	release()
+	if err := os.WriteFile(filepath.Join(dir, "big.txt"), make([]byte, 64), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if _, _, err := m.Acquire(7, "update_id=2"); !errors.Is(err, ErrQuota) {
+		t.Fatalf("expected quota error, got %v", err)
+	}
+	if info := m.Inspect(7); info.Bytes != 64 || info.Files != 1 {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+
+	if err := m.Reset(7); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if _, release, err := m.Acquire(7, "update_id=3"); err != nil {
+		t.Fatalf("expected acquire after reset, got %v", err)
+	} else {
+		release()
+	}
+}
+
+func TestAcquireRefusedDuringReset(t *testing.T) {
+	m := New(Options{Mode: ModeChat, Root: t.TempDir()}, nil)
+	chatDir := m.chatDir(7)
+	m.removing[chatDir] = true
+	if _, _, err := m.Acquire(7, "update_id=1"); !errors.Is(err, ErrRemoving) {
+		t.Fatalf("expected acquire to be refused during a reset, got %v", err)
+	}
+	if err := m.Reset(7); !errors.Is(err, ErrRemoving) {
+		t.Fatalf("expected a second reset to be refused, got %v", err)
+	}
+	if len(m.inUse) != 0 {
+		t.Fatalf("refused acquire must not register the directory: %v", m.inUse)
+	}
+	m.endRemoval(chatDir)
+	_, release, err := m.Acquire(7, "update_id=2")
+	if err != nil {
+		t.Fatalf("acquire after reset: %v", err)
+	}
+	release()
+}
+
+func TestWorktreeFromRepo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	repo := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		if err := git(repo, args...); err != nil {
+			t.Fatalf("setup repo: %v", err)
+		}
+	}
+
+	m := New(Options{Mode: ModeChat, Root: t.TempDir(), Repo: repo}, nil)
+	_, release, err := m.Acquire(7, "update_id=1")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	release()
+	if info := m.Inspect(7); !info.Worktree {
+		t.Fatalf("expected worktree, got %+v", info)
+	}
+	if err := m.Reset(7); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if info := m.Inspect(7); info.Exists {
+		t.Fatalf("expected workspace removed, got %+v", info)
+	}
+}